@@ -0,0 +1,59 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/yubo/golib/labels"
+)
+
+// Pagination is the common input of the list calls, e.g.
+//
+//	GET /users?offset=20&limit=10&sort=-created_at,name&query=status=active
+//
+// it can be embedded in the input struct of the list, and converted into
+// the orm query by orm.Query.WithPagination
+type Pagination struct {
+	Offset int64 `param:"query" description:"the offset of the items"`
+	Limit  int64 `param:"query" description:"the max number of the items"`
+	// Sort is the fields separated by ',', the '-' prefix means descending
+	Sort string `param:"query" description:"the sort fields, e.g. -created_at,name"`
+	// Query is the label selector of the items, e.g. "status=active,age>18"
+	Query string `param:"query" description:"the selector of the items"`
+}
+
+var sortFieldRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// OrderBy returns the order fields of the Sort, e.g. "-created_at,name" ->
+// ["created_at desc", "name"], the fields are the columns
+func (p *Pagination) OrderBy() ([]string, error) {
+	var ret []string
+	for _, field := range strings.Split(p.Sort, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+
+		order := ""
+		switch field[0] {
+		case '-':
+			field, order = field[1:], " desc"
+		case '+':
+			field = field[1:]
+		}
+
+		if !sortFieldRegexp.MatchString(field) {
+			return nil, fmt.Errorf("invalid sort field %q", field)
+		}
+		ret = append(ret, field+order)
+	}
+	return ret, nil
+}
+
+// Selector returns the selector of the Query, nil if it's empty
+func (p *Pagination) Selector() (labels.Selector, error) {
+	if strings.TrimSpace(p.Query) == "" {
+		return nil, nil
+	}
+	return labels.Parse(p.Query)
+}
@@ -0,0 +1,91 @@
+package configer
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Param is the metadata of the registered config, e.g. for the shell
+// completion of the flags and the --set paths
+type Param struct {
+	Path        string
+	Flag        string
+	Shorthand   string
+	Env         string
+	Type        string
+	Default     interface{}
+	Description string
+	Deprecated  string
+	Candidates  []string
+}
+
+// Params returns the registered configs sorted by the path
+func (p *Options) Params() []Param {
+	params := p.sortedParams()
+	ret := make([]Param, 0, len(params))
+	for _, f := range params {
+		param := Param{
+			Path:        f.configPath,
+			Flag:        f.flag,
+			Shorthand:   f.shothand,
+			Env:         p.envName(f),
+			Default:     f.defaultValue,
+			Description: f.description,
+			Deprecated:  f.deprecated,
+			Candidates:  f.completionCandidates(),
+		}
+		if f.typ != nil {
+			param.Type = f.typ.String()
+		}
+		ret = append(ret, param)
+	}
+	return ret
+}
+
+// completionCandidates returns the candidates tag, or the oneof of the
+// validate tag, or the values of the bool
+func (f *param) completionCandidates() []string {
+	switch {
+	case len(f.candidates) > 0:
+		return f.candidates
+	case len(f.enum) > 0:
+		return f.enum
+	case f.typ != nil && f.typ.Kind() == reflect.Bool:
+		return []string{"true", "false"}
+	}
+	return nil
+}
+
+// CompleteSet returns the completions of the --set value, the config
+// paths with '=', or the candidates of the path if toComplete has '=',
+// e.g. "sys.http.p" -> "sys.http.port=", "sys.mode=d" -> "sys.mode=debug".
+// The completed pairs before the last ',' are kept.
+func (p *Options) CompleteSet(toComplete string) []string {
+	prefix := ""
+	if i := strings.LastIndexByte(toComplete, ','); i >= 0 {
+		prefix, toComplete = toComplete[:i+1], toComplete[i+1:]
+	}
+
+	var ret []string
+	if i := strings.IndexByte(toComplete, '='); i >= 0 {
+		path, value := toComplete[:i], toComplete[i+1:]
+		for _, f := range p.params {
+			if f.configPath != path {
+				continue
+			}
+			for _, c := range f.completionCandidates() {
+				if strings.HasPrefix(c, value) {
+					ret = append(ret, prefix+path+"="+c)
+				}
+			}
+		}
+		return ret
+	}
+
+	for _, f := range p.sortedParams() {
+		if strings.HasPrefix(f.configPath, toComplete) {
+			ret = append(ret, prefix+f.configPath+"=")
+		}
+	}
+	return ret
+}
@@ -5,12 +5,17 @@ package configer
 // def < env < config < valueFile < value < flag
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/spf13/cast"
 	"github.com/spf13/pflag"
+	"github.com/yubo/golib/util"
 	"github.com/yubo/golib/util/strvals"
 	"github.com/yubo/golib/util/template"
 	"k8s.io/klog/v2"
@@ -25,12 +30,23 @@ func SetOptions(allowEnv, allowEmptyEnv bool, maxDepth int, fs *pflag.FlagSet) {
 	GlobalOptions.SetOptions(allowEnv, allowEmptyEnv, maxDepth, fs)
 }
 
+// SetNameMapper sets the mapper to derive the config path from the field name
+// if the json tag is not set, e.g. util.NewNameMapper(util.LowerCamelCase)
+func SetNameMapper(m util.NameMapper) {
+	GlobalOptions.nameMapper = m
+}
+
 type Configer struct {
 	*Options
 
+	mu       sync.RWMutex // guards data, which is replaced by the reload
+	writeMu  sync.Mutex   // serializes Set, Reload and Restore
 	data     map[string]interface{}
 	path     []string
 	prepared bool
+	watchers []func(changed []string)
+
+	deprecatedKeys []string // the deprecated paths used by the last load
 }
 
 // must called after pflag parse
@@ -77,39 +93,73 @@ func (p *Configer) Prepare() (err error) {
 		return nil
 	}
 
-	base := map[string]interface{}{}
+	base, err := p.load()
+	if err != nil {
+		return err
+	}
+
+	p.data = base
+	p.prepared = true
+	return nil
+}
+
+// load merges the values of all the sources by the priority
+func (p *Configer) load() (base map[string]interface{}, err error) {
+	base = map[string]interface{}{}
+	var used []string
+	defer func() {
+		if err == nil {
+			p.warnDeprecated(used)
+		}
+	}()
 
 	// init base from flag default
 	p.mergeDefaultValues(base)
 
+	// the envs bound by the prefix
+	p.mergePrefixEnvValues(base)
+
 	// base with path
 	for path, b := range p.pathsBase {
 		if base, err = yaml2ValuesWithPath(base, path, []byte(b)); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
+	// the directories are expanded to the files
+	valueFiles, err := expandValueFiles(p.valueFiles)
+	if err != nil {
+		return nil, err
+	}
+
 	// configFile & valueFile --values
-	for _, filePath := range p.valueFiles {
+	for _, filePath := range valueFiles {
 		m := map[string]interface{}{}
 
-		bytes, err := template.ParseTemplateFile(nil, filePath)
+		// the values of the previous sources can be referred by the template
+		bytes, err := readValueFile(filePath, templateData(base))
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		if err := unmarshalValues(filePath, bytes, &m); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %s", filePath, err)
 		}
 
-		if err := yaml.Unmarshal(bytes, &m); err != nil {
-			return fmt.Errorf("failed to parse %s: %s", filePath, err)
+		if err := p.decryptValueFile(filePath, m); err != nil {
+			return nil, err
 		}
+		used = append(used, p.moveDeprecated(m, false)...)
+
 		// Merge with the previous map
-		base = mergeValues(base, m)
+		base = mergeValuesWithStrategies("", base, m, p.mergeStrategies)
 		klog.V(1).InfoS("config load", "filePath", filePath)
 	}
 
 	// User specified a value via --set
 	for _, value := range p.values {
 		if err := strvals.ParseInto(value, base); err != nil {
-			return fmt.Errorf("failed parsing --set data: %s", err)
+			return nil, fmt.Errorf("failed parsing --set data: %s", err)
 		}
 		klog.V(1).InfoS("config load", "value", value)
 	}
@@ -117,7 +167,7 @@ func (p *Configer) Prepare() (err error) {
 	// User specified a value via --set-string
 	for _, value := range p.stringValues {
 		if err := strvals.ParseIntoString(value, base); err != nil {
-			return fmt.Errorf("failed parsing --set-string data: %s", err)
+			return nil, fmt.Errorf("failed parsing --set-string data: %s", err)
 		}
 		klog.V(1).InfoS("config load", "filepath(string)", value)
 	}
@@ -129,7 +179,7 @@ func (p *Configer) Prepare() (err error) {
 			return string(bytes), err
 		}
 		if err := strvals.ParseIntoFile(value, base, reader); err != nil {
-			return fmt.Errorf("failed parsing --set-file data: %s", err)
+			return nil, fmt.Errorf("failed parsing --set-file data: %s", err)
 		}
 		klog.V(1).InfoS("config load", "set-file", value)
 	}
@@ -140,13 +190,53 @@ func (p *Configer) Prepare() (err error) {
 
 	for path, b := range p.pathsOverride {
 		if base, err = yaml2ValuesWithPath(base, path, []byte(b)); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	p.data = base
-	p.prepared = true
-	return nil
+	// the deprecated paths set by the flags, envs and --set
+	used = append(used, p.moveDeprecated(base, true)...)
+
+	return base, nil
+}
+
+// readValueFile reads the local file or the remote ValueSource, the
+// template of the content is executed with the data
+func readValueFile(file string, data interface{}) ([]byte, error) {
+	source, err := getValueSource(file)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return template.ParseTemplateFile(data, file)
+	}
+
+	b, err := source.Read(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return template.ParseTemplateText(data, string(b))
+}
+
+// templateData returns the data of the template of the value files, e.g.
+//
+//	hostname: {{ .Env.HOSTNAME }}
+//	addr: 127.0.0.1:{{ .Values.sys.port }}
+//
+// the .Values are the values merged before the file, e.g. the defaults
+// and the previous value files, like the values of helm
+func templateData(values map[string]interface{}) map[string]interface{} {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	return map[string]interface{}{
+		"Env":    env,
+		"Values": values,
+	}
 }
 
 func (p *Configer) ValueFiles() []string {
@@ -172,13 +262,33 @@ func (p *Configer) GetConfiger(path string) *Configer {
 	}
 }
 
+// Set merges the value into a copy of the data and replaces the data
+// with it, the data being read by the getters is never changed in place.
+// The OnChange callbacks are called with the changed paths
 func (p *Configer) Set(path string, v interface{}) error {
+	p.writeMu.Lock()
+	changed, err := p.set(path, v)
+	p.writeMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	p.notify(changed)
+	return nil
+}
+
+func (p *Configer) set(path string, v interface{}) ([]string, error) {
+	data := copyValues(map[string]interface{}(p.getData())).(map[string]interface{})
+
 	if path == "" {
 		b, err := yaml.Marshal(v)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		return yaml.Unmarshal(b, p.data)
+		if err := yaml.Unmarshal(b, data); err != nil {
+			return nil, err
+		}
+		return p.swap(data), nil
 	}
 
 	ps := strings.Split(path, ".")
@@ -188,17 +298,15 @@ func (p *Configer) Set(path string, v interface{}) error {
 		src = map[string]interface{}{ps[i]: src}
 	}
 
-	p.data = mergeValues(p.data, src)
-
-	return nil
+	return p.swap(mergeValues(data, src)), nil
 }
 
 func (p *Configer) GetRaw(path string) interface{} {
 	if path == "" {
-		return Values(p.data)
+		return p.getData()
 	}
 
-	v, err := Values(p.data).PathValue(path)
+	v, err := p.getData().PathValue(path)
 	if err != nil {
 		klog.V(5).InfoS("get pathValue err, ignored", "path", path, "v", v, "err", err)
 		return nil
@@ -206,27 +314,37 @@ func (p *Configer) GetRaw(path string) interface{} {
 	return v
 }
 
+// getData returns the current data, which may be replaced by the reload
+func (p *Configer) getData() Values {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return Values(p.data)
+}
+
+// The getters below look up the path, e.g. "servers[0].port", and
+// convert the value to the type by cast, e.g. "8080" is 8080 by GetInt
+
 func (p *Configer) GetString(path string) string {
-	v, err := Values(p.data).PathValue(path)
+	v, err := p.getData().PathValue(path)
 	if err != nil {
 		return ""
 	}
 
-	s, ok := v.(string)
-	if !ok {
+	s, err := cast.ToStringE(v)
+	if err != nil {
 		return ""
 	}
 	return s
 }
 
 func (p *Configer) GetBool(path string) (bool, error) {
-	v, err := Values(p.data).PathValue(path)
+	v, err := p.getData().PathValue(path)
 	if err != nil {
 		return false, err
 	}
 
-	b, ok := v.(bool)
-	if !ok {
+	b, err := cast.ToBoolE(v)
+	if err != nil {
 		return false, fmt.Errorf("%v is not bool", path)
 	}
 	return b, nil
@@ -241,13 +359,13 @@ func (p *Configer) GetBoolDef(path string, def bool) bool {
 }
 
 func (p *Configer) GetFloat64(path string) (float64, error) {
-	v, err := Values(p.data).PathValue(path)
+	v, err := p.getData().PathValue(path)
 	if err != nil {
 		return 0, err
 	}
 
-	f, ok := v.(float64)
-	if !ok {
+	f, err := cast.ToFloat64E(v)
+	if err != nil {
 		return 0, fmt.Errorf("%v is not number", path)
 	}
 
@@ -297,12 +415,74 @@ func (p *Configer) GetIntDef(path string, def int) int {
 	return v
 }
 
+// GetDuration returns the duration of the path, the string is parsed
+// by time.ParseDuration, e.g. "5s", the number is nanoseconds
+func (p *Configer) GetDuration(path string) (time.Duration, error) {
+	v, err := p.getData().PathValue(path)
+	if err != nil {
+		return 0, err
+	}
+
+	d, err := cast.ToDurationE(v)
+	if err != nil {
+		return 0, fmt.Errorf("%v is not duration", path)
+	}
+	return d, nil
+}
+
+func (p *Configer) GetDurationDef(path string, def time.Duration) time.Duration {
+	v, err := p.GetDuration(path)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func (p *Configer) GetStringSlice(path string) ([]string, error) {
+	v, err := p.getData().PathValue(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := cast.ToStringSliceE(v)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not string slice", path)
+	}
+	return s, nil
+}
+
+func (p *Configer) GetStringMap(path string) (map[string]interface{}, error) {
+	v, err := p.getData().PathValue(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := cast.ToStringMapE(v)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not map", path)
+	}
+	return m, nil
+}
+
+func (p *Configer) GetStringMapString(path string) (map[string]string, error) {
+	v, err := p.getData().PathValue(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := cast.ToStringMapStringE(v)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not string map", path)
+	}
+	return m, nil
+}
+
 type validator interface {
 	Validate() error
 }
 
 func (p *Configer) IsSet(path string) bool {
-	_, err := Values(p.data).PathValue(path)
+	_, err := p.getData().PathValue(path)
 	return err == nil
 }
 
@@ -312,6 +492,11 @@ func (p *Configer) Read(path string, into interface{}) error {
 	}
 
 	if v := p.GetRaw(path); v != nil {
+		v, err := resolveSecrets(path, v)
+		if err != nil {
+			return err
+		}
+
 		data, err := yaml.Marshal(v)
 		//klog.V(5).InfoS("marshal", "v", v, "data", string(data), "err", err)
 		if err != nil {
@@ -328,10 +513,9 @@ func (p *Configer) Read(path string, into interface{}) error {
 		}
 	}
 
-	if v, ok := into.(validator); ok {
-		if err := v.Validate(); err != nil {
-			return err
-		}
+	// the validate tags and the Validate() of the structs
+	if err := p.validate(path, into); err != nil {
+		return err
 	}
 
 	if klog.V(10).Enabled() {
@@ -342,7 +526,7 @@ func (p *Configer) Read(path string, into interface{}) error {
 }
 
 func (p *Configer) String() string {
-	buf, err := yaml.Marshal(p.data)
+	buf, err := yaml.Marshal(p.getData())
 	if err != nil {
 		return err.Error()
 	}
@@ -374,12 +558,23 @@ func yaml2ValuesWithPath(into map[string]interface{}, path string, data []byte)
 
 // Merges source and into map, preferring values from the source map ( src > into)
 func mergeValues(into map[string]interface{}, src map[string]interface{}) map[string]interface{} {
+	return mergeValuesWithStrategies("", into, src, nil)
+}
+
+// mergeValuesWithStrategies merges src into the map of the path, the
+// lists are merged by the strategies of the paths or the $patch directives
+func mergeValuesWithStrategies(path string, into, src map[string]interface{}, strategies map[string]MergeStrategy) map[string]interface{} {
 	for k, v := range src {
-		// If the key doesn't exist already, then just set the key to that value
-		if _, ok := into[k]; !ok {
-			into[k] = v
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+
+		if list, ok := v.([]interface{}); ok {
+			into[k] = mergeList(p, into[k], list, strategies)
 			continue
 		}
+
 		nextMap, ok := v.(map[string]interface{})
 		// If it isn't another map, overwrite the value
 		if !ok {
@@ -387,13 +582,12 @@ func mergeValues(into map[string]interface{}, src map[string]interface{}) map[st
 			continue
 		}
 		intoMap, isMap := into[k].(map[string]interface{})
-		// If the source map has a map for this key, prefer it
+		// If the key doesn't exist already, or the source map has a map for this key, prefer it
 		if !isMap {
-			into[k] = v
-			continue
+			intoMap = map[string]interface{}{}
 		}
 		// If we got to this point, it is a map in both, so merge them
-		into[k] = mergeValues(intoMap, nextMap)
+		into[k] = mergeValuesWithStrategies(p, intoMap, nextMap, strategies)
 	}
 	return into
 }
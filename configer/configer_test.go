@@ -1,17 +1,31 @@
 package configer
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/yubo/golib/util"
+	utilnet "github.com/yubo/golib/util/net"
+	"sigs.k8s.io/yaml"
 )
 
 // get config  ParseConfigFile(values, config.yml)
@@ -461,3 +475,864 @@ func teardown() {
 	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 	GlobalOptions = newOptions()
 }
+
+func TestConfigerNameMapper(t *testing.T) {
+	type Foo struct {
+		MaxIdleConns int `default:"10"`
+		UserID       string
+	}
+
+	teardown()
+	defer teardown()
+	SetNameMapper(util.NewNameMapper(util.LowerCamelCase))
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	err := AddConfigs(fs, "foo", &Foo{})
+	assert.NoError(t, err)
+
+	cf, err := New(WithOverrideYaml("foo", "userID: test"))
+	assert.NoError(t, err)
+
+	var foo Foo
+	assert.NoError(t, cf.Read("foo", &foo))
+	assert.Equal(t, Foo{MaxIdleConns: 10, UserID: "test"}, foo)
+	assert.Equal(t, 10, cf.GetRaw("foo.maxIdleConns"))
+}
+
+func TestConfigerWatch(t *testing.T) {
+	dir := createTestDir([]templateFile{
+		{"conf.yml", "a: 1\nb:\n  c: 2\n  d: 3\n"},
+	})
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "conf.yml")
+
+	conf, err := New(WithValueFile(file))
+	assert.NoError(t, err)
+
+	changes := make(chan []string, 10)
+	conf.OnChange(func(changed []string) {
+		changes <- changed
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, conf.Watch(ctx))
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("a: 1\nb:\n  c: 4\ne: 5\n"), 0644))
+
+	select {
+	case changed := <-changes:
+		assert.Equal(t, []string{"b.c", "b.d", "e"}, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the change")
+	}
+	assert.Equal(t, 4, conf.GetIntDef("b.c", 0))
+
+	// the data is kept on the parse error
+	assert.NoError(t, ioutil.WriteFile(file, []byte("a: ["), 0644))
+	assert.Error(t, conf.Reload())
+	assert.Equal(t, 5, conf.GetIntDef("e", 0))
+}
+
+func TestValueSource(t *testing.T) {
+	var mu sync.Mutex
+	index, value := 1, "b: 2\n"
+	changed := make(chan struct{}, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a: 1\n"))
+	})
+	mux.HandleFunc("/v3/kv/range", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Key string }
+		json.NewDecoder(r.Body).Decode(&req)
+		key, _ := base64.StdEncoding.DecodeString(req.Key)
+		assert.Equal(t, "/config/app", string(key))
+		fmt.Fprintf(w, `{"kvs":[{"value":%q}]}`, base64.StdEncoding.EncodeToString([]byte("c: 3\n")))
+	})
+	mux.HandleFunc("/v1/kv/config/app", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("index") != "" {
+			// the blocking query
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("X-Consul-Index", strconv.Itoa(index))
+		w.Write([]byte(value))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	host := strings.TrimPrefix(ts.URL, "http://")
+	conf, err := New(WithValueFile(
+		ts.URL+"/app.yaml",
+		"etcd://"+host+"/config/app",
+		"consul://"+host+"/config/app",
+	))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, conf.GetIntDef("a", 0))
+	assert.Equal(t, 2, conf.GetIntDef("b", 0))
+	assert.Equal(t, 3, conf.GetIntDef("c", 0))
+
+	changes := make(chan []string, 10)
+	conf.OnChange(func(paths []string) { changes <- paths })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, conf.Watch(ctx))
+
+	mu.Lock()
+	index, value = 2, "b: 4\n"
+	mu.Unlock()
+	changed <- struct{}{}
+
+	select {
+	case paths := <-changes:
+		assert.Equal(t, []string{"b"}, paths)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the change")
+	}
+	assert.Equal(t, 4, conf.GetIntDef("b", 0))
+
+	_, err = New(WithValueFile(ts.URL + "/404.yaml"))
+	assert.Error(t, err)
+}
+
+type validateServer struct {
+	Addr string `json:"addr" validate:"required,hostport"`
+}
+
+func (p validateServer) Validate() error {
+	if strings.HasPrefix(p.Addr, "0.0.0.0") {
+		return fmt.Errorf("can't listen on all the interfaces")
+	}
+	return nil
+}
+
+func TestReadValidate(t *testing.T) {
+	type config struct {
+		Name    string           `json:"name" validate:"required,max=8"`
+		Mode    string           `json:"mode" validate:"oneof=debug release"`
+		Workers int              `json:"workers" validate:"min=1,max=64"`
+		Timeout time.Duration    `json:"timeout" validate:"min=1s"`
+		Hook    string           `json:"hook" validate:"url"`
+		Servers []validateServer `json:"servers"`
+	}
+
+	conf, err := New(WithDefaultYaml("app", `
+name: test
+mode: release
+workers: 4
+timeout: 2000000000
+hook: https://example.com/hook
+servers:
+- addr: 127.0.0.1:80
+`))
+	assert.NoError(t, err)
+	assert.NoError(t, conf.Read("app", &config{}))
+
+	conf, err = New(WithDefaultYaml("app", `
+name: too-long-name
+mode: test
+workers: 100
+timeout: 1000
+hook: example.com
+servers:
+- addr: 127.0.0.1:80
+- addr: 127.0.0.1
+- addr: 0.0.0.0:80
+`))
+	assert.NoError(t, err)
+
+	err = conf.Read("app", &config{})
+	assert.Error(t, err)
+	for _, s := range []string{
+		"app.name: length 13 is greater than the max 8",
+		`app.mode: "test" is not one of [debug release]`,
+		"app.workers: 100 is greater than the max 64",
+		"app.timeout: 1µs is out of the range, min 1s",
+		`app.hook: "example.com" is not a valid url`,
+		`app.servers[1].addr: "127.0.0.1" is not a valid host:port`,
+		"app.servers[2]: can't listen on all the interfaces",
+	} {
+		assert.Contains(t, err.Error(), s)
+	}
+
+	err = conf.Read("none", &config{})
+	assert.Contains(t, err.Error(), "name: is required")
+}
+
+func TestReadSecret(t *testing.T) {
+	dir := createTestDir([]templateFile{{"db_pass", "file-pass\n"}})
+	defer os.RemoveAll(dir)
+
+	os.Setenv("TEST_CONFIGER_DSN", "env-dsn")
+	defer os.Unsetenv("TEST_CONFIGER_DSN")
+
+	RegisterSecretProvider("vault", SecretProviderFunc(func(ref *url.URL) (string, error) {
+		return ref.Path + "#" + ref.Fragment, nil
+	}))
+
+	type config struct {
+		Dsn      string   `json:"dsn"`
+		Password string   `json:"password"`
+		Tokens   []string `json:"tokens"`
+		Url      string   `json:"url"`
+	}
+
+	conf, err := New(WithDefaultYaml("db", fmt.Sprintf(`
+dsn: env://TEST_CONFIGER_DSN
+password: file://%s/db_pass
+tokens:
+- secret://vault/app/db#token
+url: https://example.com
+`, dir)))
+	assert.NoError(t, err)
+
+	var c config
+	assert.NoError(t, conf.Read("db", &c))
+	assert.Equal(t, config{
+		Dsn:      "env-dsn",
+		Password: "file-pass",
+		Tokens:   []string{"/app/db#token"},
+		Url:      "https://example.com",
+	}, c)
+
+	// the references are kept in the data
+	assert.Equal(t, "env://TEST_CONFIGER_DSN", conf.GetString("db.dsn"))
+
+	conf, err = New(WithDefaultYaml("db", "dsn: secret://none/dsn"))
+	assert.NoError(t, err)
+	assert.Error(t, conf.Read("db", &c))
+}
+
+func TestValueFileFormat(t *testing.T) {
+	dir := createTestDir([]templateFile{
+		{"a.yaml", "a: 1\nsys:\n  name: yaml\n"},
+		{"b.json", `{"b": 2, "sys": {"port": 80}}`},
+		{"c.toml", "c = 3\n\n[sys]\nname = \"toml\"\n\n[[sys.servers]]\naddr = \"127.0.0.1:80\"\n"},
+	})
+	defer os.RemoveAll(dir)
+
+	conf, err := New(WithValueFile(
+		filepath.Join(dir, "a.yaml"),
+		filepath.Join(dir, "b.json"),
+		filepath.Join(dir, "c.toml"),
+	))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, conf.GetIntDef("a", 0))
+	assert.Equal(t, 2, conf.GetIntDef("b", 0))
+	assert.Equal(t, 3, conf.GetIntDef("c", 0))
+	assert.Equal(t, 80, conf.GetIntDef("sys.port", 0))
+	assert.Equal(t, "toml", conf.GetString("sys.name"))
+
+	var sys struct {
+		Servers []struct {
+			Addr string `json:"addr"`
+		} `json:"servers"`
+	}
+	assert.NoError(t, conf.Read("sys", &sys))
+	assert.Equal(t, "127.0.0.1:80", sys.Servers[0].Addr)
+
+	assert.Equal(t, "toml", valueFileFormat("https://example.com/app.TOML?v=1"))
+}
+
+func TestTemplateValues(t *testing.T) {
+	os.Setenv("TEST_HOSTNAME", "node-1")
+	defer os.Unsetenv("TEST_HOSTNAME")
+
+	dir := createTestDir([]templateFile{
+		{"base.yaml", "sys:\n  port: 8080\n"},
+		{"app.yaml", "sys:\n  host: '{{ .Env.TEST_HOSTNAME }}'\n  addr: '127.0.0.1:{{ .Values.sys.port }}'\n"},
+	})
+	defer os.RemoveAll(dir)
+
+	conf, err := New(WithValueFile(
+		filepath.Join(dir, "base.yaml"),
+		filepath.Join(dir, "app.yaml"),
+	))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "node-1", conf.GetString("sys.host"))
+	assert.Equal(t, "127.0.0.1:8080", conf.GetString("sys.addr"))
+}
+
+func TestGenerate(t *testing.T) {
+	type Http struct {
+		Addr    string        `json:"addr" flag:"http-addr" env:"HTTP_ADDR" default:":8080" description:"the address to listen on"`
+		Timeout time.Duration `json:"timeout" default:"5s" description:"the timeout | of the request"`
+	}
+	type Config struct {
+		Http  Http     `json:"http"`
+		Debug bool     `json:"debug" flag:"debug,d"`
+		Tags  []string `json:"tags"`
+	}
+
+	teardown()
+	defer teardown()
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	SetOptions(true, false, 5, fs)
+	assert.NoError(t, AddConfigs(fs, "app", &Config{}))
+	cf, err := New()
+	assert.NoError(t, err)
+
+	b, err := cf.GenerateYaml()
+	assert.NoError(t, err)
+	assert.Equal(t, `app:
+  # flag: --debug, -d
+  debug: false
+  http:
+    # the address to listen on
+    # env: HTTP_ADDR, flag: --http-addr
+    addr: ":8080"
+    # the timeout | of the request
+    timeout: 5000000000
+  tags: null
+`, string(b))
+
+	// the sample is a valid config
+	cf, err = New(WithDefaultYaml("", string(b)))
+	assert.NoError(t, err)
+	assert.Equal(t, ":8080", cf.GetString("app.http.addr"))
+
+	assert.Equal(t, "| Path | Type | Default | Env | Flag | Description |\n"+
+		"| ---- | ---- | ------- | --- | ---- | ----------- |\n"+
+		"| app.debug | bool |  |  | `--debug, -d` |  |\n"+
+		"| app.http.addr | string | `:8080` | `HTTP_ADDR` | `--http-addr` | the address to listen on |\n"+
+		"| app.http.timeout | time.Duration | `5s` |  |  | the timeout \\| of the request |\n"+
+		"| app.tags | []string |  |  |  |  |\n",
+		string(cf.GenerateMarkdown()))
+}
+
+func TestAddConfigsTypes(t *testing.T) {
+	type Server struct {
+		Addr   string `json:"addr"`
+		Weight int    `json:"weight"`
+	}
+	type Config struct {
+		Retries []time.Duration  `json:"retries" flag:"retries" default:"1s,2s"`
+		IP      net.IP           `json:"ip" default:"127.0.0.1"`
+		Listen  utilnet.HostPort `json:"listen" flag:"listen" default:":80"`
+		Buffer  util.ByteSize    `json:"buffer" env:"TEST_BUFFER" default:"1Ki"`
+		Cache   util.ByteSize    `json:"cache" flag:"cache"`
+		Servers []Server         `json:"servers" default:"[{addr: a, weight: 1}]"`
+		Peers   []*Server        `json:"peers" flag:"peers"`
+	}
+
+	teardown()
+	defer teardown()
+
+	os.Setenv("TEST_BUFFER", "512Mi")
+	defer os.Unsetenv("TEST_BUFFER")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	SetOptions(true, true, 5, fs)
+	assert.NoError(t, AddConfigs(fs, "app", &Config{}))
+	assert.NoError(t, fs.Parse([]string{
+		"--listen=127.0.0.1:8080",
+		"--cache=1.5G",
+		`--peers=[{"addr": "b"}]`,
+	}))
+
+	cf, err := New()
+	assert.NoError(t, err)
+
+	var c Config
+	assert.NoError(t, cf.Read("app", &c))
+	assert.Equal(t, Config{
+		Retries: []time.Duration{time.Second, 2 * time.Second},
+		IP:      net.ParseIP("127.0.0.1"),
+		Listen:  "127.0.0.1:8080",
+		Buffer:  512 * util.MB,
+		Cache:   util.ByteSize(1.5 * float64(util.GB)),
+		Servers: []Server{{Addr: "a", Weight: 1}},
+		Peers:   []*Server{{Addr: "b"}},
+	}, c)
+
+	assert.Equal(t, 8080, c.Listen.Port())
+
+	teardown()
+	fs = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	SetOptions(true, false, 5, fs)
+	assert.Error(t, AddConfigs(fs, "app", &struct {
+		Listen utilnet.HostPort `json:"listen" default:"127.0.0.1"`
+	}{}))
+}
+
+func TestDeprecated(t *testing.T) {
+	type Http struct {
+		Port int    `json:"port" default:"80"`
+		Host string `json:"host"`
+	}
+	type Sys struct {
+		Http    Http   `json:"http"`
+		Port    int    `json:"port" flag:"port" default:"8000" deprecated:"use sys.http.port"`
+		Timeout string `json:"timeout" deprecated:"it will be removed"`
+	}
+
+	teardown()
+	defer teardown()
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	SetOptions(true, false, 5, fs)
+	assert.NoError(t, AddConfigs(fs, "sys", &Sys{}))
+
+	dir := createTestDir([]templateFile{
+		{"a.yaml", "sys:\n  port: 8080\n  hostname: a\n  timeout: 1s\n"},
+		{"b.yaml", "sys:\n  http:\n    port: 9090\n  port: 8081\n"},
+	})
+	defer os.RemoveAll(dir)
+
+	// the default of the deprecated config is ignored
+	cf, err := New(WithAlias("sys.hostname", "sys.http.host"))
+	assert.NoError(t, err)
+	assert.Equal(t, 80, cf.GetIntDef("sys.http.port", 0))
+	assert.Empty(t, cf.DeprecatedKeys())
+
+	cf, err = New(WithAlias("sys.hostname", "sys.http.host"), WithValueFile(filepath.Join(dir, "a.yaml")))
+	assert.NoError(t, err)
+
+	var sys Sys
+	assert.NoError(t, cf.Read("sys", &sys))
+	assert.Equal(t, Sys{Http: Http{Port: 8080, Host: "a"}, Timeout: "1s"}, sys)
+	assert.Equal(t, []string{"sys.hostname", "sys.port", "sys.timeout"}, cf.DeprecatedKeys())
+
+	// the new path of the same file takes precedence
+	cf, err = New(WithValueFile(filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")))
+	assert.NoError(t, err)
+	assert.Equal(t, 9090, cf.GetIntDef("sys.http.port", 0))
+
+	// the flag of the deprecated config
+	assert.NoError(t, fs.Parse([]string{"--port=7070"}))
+	cf, err = New(WithValueFile(filepath.Join(dir, "b.yaml")))
+	assert.NoError(t, err)
+	assert.Equal(t, 7070, cf.GetIntDef("sys.http.port", 0))
+	assert.Equal(t, []string{"sys.port"}, cf.DeprecatedKeys())
+}
+
+func TestEncryptValues(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	data, err := EncryptValueFile([]byte("db:\n  user: root\n  password: secret\n  port: 3306\n  hosts: [a, b]\n"), key, "db.password", "db.port", "db.hosts")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "user: root")
+	assert.NotContains(t, string(data), "secret")
+	assert.Contains(t, string(data), "ENC[AES256_GCM,")
+
+	dir := createTestDir([]templateFile{{"values.yaml", string(data)}})
+	defer os.RemoveAll(dir)
+
+	// the key is required
+	os.Unsetenv(DefaultKeyEnv)
+	_, err = New(WithValueFile(filepath.Join(dir, "values.yaml")))
+	assert.Error(t, err)
+
+	os.Setenv(DefaultKeyEnv, base64.StdEncoding.EncodeToString(key))
+	defer os.Unsetenv(DefaultKeyEnv)
+
+	cf, err := New(WithValueFile(filepath.Join(dir, "values.yaml")))
+	assert.NoError(t, err)
+	assert.Equal(t, "root", cf.GetString("db.user"))
+	assert.Equal(t, "secret", cf.GetString("db.password"))
+	assert.Equal(t, 3306, cf.GetIntDef("db.port", 0))
+	assert.Equal(t, "b", cf.GetString("db.hosts[1]"))
+
+	// the encrypted value can't be moved to another key
+	values := map[string]interface{}{}
+	assert.NoError(t, yaml.Unmarshal(data, &values))
+	db := values["db"].(map[string]interface{})
+	db["user"] = db["password"]
+	assert.Error(t, DecryptValues(values, key))
+
+	// the wrong key
+	_, err = DecryptValueFile(data, []byte("fedcba9876543210fedcba9876543210"))
+	assert.Error(t, err)
+}
+
+func TestMergeStrategy(t *testing.T) {
+	dir := createTestDir([]templateFile{
+		{"base.yaml", `
+servers:
+  - name: a
+    port: 80
+  - name: b
+    port: 81
+tags: [a, b]
+hosts: [a, b]
+`},
+		{"override.yaml", `
+servers:
+  - name: b
+    port: 8081
+  - name: c
+    port: 82
+tags: [c]
+hosts:
+  - $patch: append
+  - c
+`},
+		{"patch.yaml", `
+servers:
+  - $patch: merge
+  - name: a
+    $patch: delete
+  - name: d
+`},
+	})
+	defer os.RemoveAll(dir)
+
+	base, override, patch := filepath.Join(dir, "base.yaml"), filepath.Join(dir, "override.yaml"), filepath.Join(dir, "patch.yaml")
+
+	// replace by default
+	cf, err := New(WithValueFile(base, override))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"c"}, cf.GetRaw("tags"))
+	assert.Equal(t, []interface{}{"a", "b", "c"}, cf.GetRaw("hosts"))
+	assert.Equal(t, "c", cf.GetString("servers[1].name"))
+	assert.False(t, cf.IsSet("servers[2]"))
+
+	cf, err = New(WithValueFile(base, override, patch),
+		WithMergeStrategy("servers", MergeByKey("name")),
+		WithMergeStrategy("tags", MergeAppend))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b", "c"}, cf.GetRaw("tags"))
+
+	var c struct {
+		Servers []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"servers"`
+	}
+	assert.NoError(t, cf.Read("", &c))
+	assert.Len(t, c.Servers, 3)
+	assert.Equal(t, "b", c.Servers[0].Name)
+	assert.Equal(t, 8081, c.Servers[0].Port)
+	assert.Equal(t, "c", c.Servers[1].Name)
+	assert.Equal(t, "d", c.Servers[2].Name)
+}
+
+func TestCompletion(t *testing.T) {
+	type Config struct {
+		Mode   string `json:"mode" flag:"mode,m" validate:"oneof=debug release"`
+		Level  string `json:"level" flag:"level" candidates:"info,warn,error"`
+		Debug  bool   `json:"debug"`
+		Listen string `json:"listen" default:":80"`
+	}
+
+	teardown()
+	defer teardown()
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	SetOptions(true, false, 5, fs)
+	assert.NoError(t, AddConfigs(fs, "app", &Config{}))
+
+	params := GlobalOptions.Params()
+	assert.Len(t, params, 4)
+	assert.Equal(t, Param{Path: "app.debug", Type: "bool", Candidates: []string{"true", "false"}}, params[0])
+	assert.Equal(t, []string{"info", "warn", "error"}, params[1].Candidates)
+	assert.Equal(t, ":80", params[2].Default)
+	assert.Equal(t, "m", params[3].Shorthand)
+	assert.Equal(t, []string{"debug", "release"}, params[3].Candidates)
+
+	assert.Equal(t, []string{"app.level=", "app.listen="}, GlobalOptions.CompleteSet("app.l"))
+	assert.Equal(t, []string{"app.mode=release"}, GlobalOptions.CompleteSet("app.mode=r"))
+	assert.Equal(t, []string{"app.debug=true,app.mode="}, GlobalOptions.CompleteSet("app.debug=true,app.m"))
+	assert.Empty(t, GlobalOptions.CompleteSet("foo"))
+}
+
+func TestSnapshot(t *testing.T) {
+	values := map[string]interface{}{
+		"sys": map[string]interface{}{
+			"port":  8080,
+			"hosts": []interface{}{"a", "b"},
+		},
+	}
+	cf := NewTestConfiger(values)
+	assert.Equal(t, 8080, cf.GetIntDef("sys.port", 0))
+
+	// the values are copied
+	values["sys"].(map[string]interface{})["port"] = 80
+	assert.Equal(t, 8080, cf.GetIntDef("sys.port", 0))
+
+	var changed []string
+	cf.OnChange(func(paths []string) { changed = paths })
+
+	snapshot := cf.Snapshot()
+	assert.NoError(t, cf.Set("sys.port", 9090))
+	assert.NoError(t, cf.Set("sys.hosts", []interface{}{"c"}))
+	assert.Equal(t, 9090, cf.GetIntDef("sys.port", 0))
+
+	cf.Restore(snapshot)
+	assert.Equal(t, 8080, cf.GetIntDef("sys.port", 0))
+	assert.Equal(t, "b", cf.GetString("sys.hosts[1]"))
+	assert.Equal(t, []string{"sys.hosts", "sys.port"}, changed)
+
+	var sys struct {
+		Port int `json:"port"`
+	}
+	assert.NoError(t, cf.Read("sys", &sys))
+	assert.Equal(t, 8080, sys.Port)
+}
+
+func TestConcurrentSet(t *testing.T) {
+	cf := NewTestConfiger(nil)
+
+	var changes int
+	cf.OnChange(func(paths []string) {
+		// the callback can read the configer
+		assert.NotEmpty(t, cf.GetString("a.b"))
+		changes++
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 100; i++ {
+			assert.NoError(t, cf.Set("a.b", i))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			cf.GetString("a.b")
+			cf.GetRaw("a")
+		}
+	}()
+	wg.Wait()
+
+	assert.Equal(t, "100", cf.GetString("a.b"))
+	assert.Equal(t, 100, changes)
+}
+
+func TestValueDir(t *testing.T) {
+	dir := createTestDir([]templateFile{
+		{"base.yaml", "a: base\nb: base\n---\n# the second document\nb: doc2\nc: doc2\n---\n"},
+	})
+	defer os.RemoveAll(dir)
+
+	confd := filepath.Join(dir, "conf.d")
+	assert.NoError(t, os.Mkdir(confd, 0755))
+	assert.NoError(t, os.Mkdir(filepath.Join(confd, "sub.yaml"), 0755))
+	for name, content := range map[string]string{
+		"20-b.yml":    "c: 20-b\nd: 20-b\n",
+		"10-a.yaml":   "c: 10-a\n",
+		"30-c.json":   `{"e": "30-c"}`,
+		".hidden.yml": "d: hidden\n",
+		"README.md":   "e: readme\n",
+	} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(confd, name), []byte(content), 0644))
+	}
+
+	cf, err := New(WithValueFile(filepath.Join(dir, "base.yaml"), confd))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "base", cf.GetString("a"))
+	assert.Equal(t, "doc2", cf.GetString("b"))
+	assert.Equal(t, "20-b", cf.GetString("c"))
+	assert.Equal(t, "20-b", cf.GetString("d"))
+	assert.Equal(t, "30-c", cf.GetString("e"))
+}
+
+func TestJSONSchema(t *testing.T) {
+	type Http struct {
+		Addr    string        `json:"addr" default:":8080" description:"the address to listen on"`
+		Mode    string        `json:"mode" validate:"oneof=debug release"`
+		Timeout time.Duration `json:"timeout" default:"5s"`
+	}
+	type Config struct {
+		Http    Http              `json:"http"`
+		Workers int               `json:"workers" validate:"oneof=1 2 4"`
+		Tags    []string          `json:"tags"`
+		Labels  map[string]string `json:"labels"`
+	}
+
+	teardown()
+	defer teardown()
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	SetOptions(true, false, 5, fs)
+	assert.NoError(t, AddConfigs(fs, "app", &Config{}))
+	cf, err := New()
+	assert.NoError(t, err)
+
+	b, err := cf.JSONSchema()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "app": {
+      "type": "object",
+      "properties": {
+        "http": {
+          "type": "object",
+          "properties": {
+            "addr": {"type": "string", "default": ":8080", "description": "the address to listen on"},
+            "mode": {"type": "string", "enum": ["debug", "release"]},
+            "timeout": {"type": ["string", "integer"], "default": "5s"}
+          }
+        },
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+        "tags": {"type": "array", "items": {"type": "string"}},
+        "workers": {"type": "integer", "enum": [1, 2, 4]}
+      }
+    }
+  }
+}`, string(b))
+}
+
+func TestTypedGetters(t *testing.T) {
+	conf, err := New(WithDefaultYaml("", `
+name: test
+port: "8080"
+debug: "true"
+timeout: 5s
+interval: 1000
+servers:
+- host: a
+  port: 80
+  tags: [web, db]
+- host: b
+  port: 81
+matrix: [[1, 2], [3, 4]]
+labels:
+  app: test
+  tier: 1
+`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "test", conf.GetString("name"))
+	assert.Equal(t, "80", conf.GetString("servers[0].port"))
+	assert.Equal(t, 8080, conf.GetIntDef("port", 0))
+	assert.Equal(t, 81, conf.GetIntDef("servers[1].port", 0))
+	assert.Equal(t, 4, conf.GetIntDef("matrix[1][1]", 0))
+	assert.True(t, conf.GetBoolDef("debug", false))
+	assert.Equal(t, 5*time.Second, conf.GetDurationDef("timeout", 0))
+	assert.Equal(t, time.Microsecond, conf.GetDurationDef("interval", 0))
+	assert.True(t, conf.IsSet("servers[1].host"))
+	assert.False(t, conf.IsSet("servers[2].host"))
+	assert.Nil(t, conf.GetRaw("servers[0]x"))
+
+	tags, err := conf.GetStringSlice("servers[0].tags")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web", "db"}, tags)
+
+	labels, err := conf.GetStringMapString("labels")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "test", "tier": "1"}, labels)
+
+	server, err := conf.GetStringMap("servers[1]")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", server["host"])
+
+	_, err = conf.GetDuration("servers")
+	assert.Error(t, err)
+	_, err = conf.GetInt("name")
+	assert.Error(t, err)
+}
+
+func TestEnvPrefix(t *testing.T) {
+	type Db struct {
+		Dsn     string        `json:"dsn"`
+		MaxConn int           `json:"max-conn" default:"10"`
+		Timeout time.Duration `json:"timeout"`
+		Tags    []string      `json:"tags"`
+	}
+	type Sys struct {
+		Db   Db  `json:"db"`
+		Port int `json:"port" env:"TEST_PORT" default:"80"`
+	}
+
+	teardown()
+	defer teardown()
+
+	for k, v := range map[string]string{
+		"MYAPP_SYS_DB_DSN":      "env-dsn",
+		"MYAPP_SYS_DB_MAX_CONN": "20",
+		"MYAPP_SYS_DB_TIMEOUT":  "5s",
+		"MYAPP_SYS_DB_TAGS":     "a,b",
+		"MYAPP_SYS_PORT":        "8080",
+	} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	SetOptions(true, false, 5, fs)
+	assert.NoError(t, AddConfigs(fs, "sys", &Sys{}))
+
+	dir := createTestDir([]templateFile{{"conf.yml", "sys:\n  db:\n    timeout: 1000\n"}})
+	defer os.RemoveAll(dir)
+
+	cf, err := New(WithEnvPrefix("myapp_"), WithValueFile(filepath.Join(dir, "conf.yml")))
+	assert.NoError(t, err)
+
+	var sys Sys
+	assert.NoError(t, cf.Read("sys", &sys))
+	assert.Equal(t, Sys{
+		Db: Db{
+			Dsn:     "env-dsn",
+			MaxConn: 20,
+			Timeout: time.Microsecond, // the value file overrides the env
+			Tags:    []string{"a", "b"},
+		},
+		Port: 80, // the env tag takes precedence over the prefix
+	}, sys)
+
+	assert.ElementsMatch(t, []string{"MYAPP_SYS_DB_DSN", "MYAPP_SYS_DB_MAX_CONN",
+		"MYAPP_SYS_DB_TIMEOUT", "MYAPP_SYS_DB_TAGS", "TEST_PORT"}, cf.Envs())
+}
+
+func TestDiff(t *testing.T) {
+	a, err := New(WithDefaultYaml("", `
+sys:
+  http:
+    port: 80
+  db:
+    dsn: mysql://old
+    pool: 10
+  log: info
+`))
+	assert.NoError(t, err)
+
+	b, err := New(WithDefaultYaml("", `
+sys:
+  http:
+    port: 8080
+  db:
+    dsn: mysql://new
+  cache:
+    size: 1
+    token: abc
+`))
+	assert.NoError(t, err)
+
+	diff := a.Diff(b)
+	assert.Equal(t, []DiffEntry{
+		{Path: "sys.cache.size", New: float64(1)},
+		{Path: "sys.cache.token", New: "******"},
+	}, diff.Added)
+	assert.Equal(t, []DiffEntry{
+		{Path: "sys.db.pool", Old: float64(10)},
+		{Path: "sys.log", Old: "info"},
+	}, diff.Removed)
+	assert.Equal(t, []DiffEntry{
+		{Path: "sys.db.dsn", Old: "******", New: "******"},
+		{Path: "sys.http.port", Old: float64(80), New: float64(8080)},
+	}, diff.Changed)
+
+	assert.True(t, diff.Affects("sys.http"))
+	assert.True(t, diff.Affects("sys"))
+	assert.True(t, diff.Affects("sys.http.port.x"))
+	assert.False(t, diff.Affects("sys.h"))
+	assert.False(t, diff.Affects("other"))
+
+	assert.True(t, a.Diff(a).Empty())
+}
@@ -0,0 +1,150 @@
+package configer
+
+import (
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// WithAlias marks the config path as deprecated, the value of the old
+// path is moved to the new path, e.g. the configs renamed by the other
+// packages which can't be tagged
+func WithAlias(old, new string) Option {
+	return func(o *Options) {
+		if o.aliases == nil {
+			o.aliases = map[string]string{}
+		}
+		o.aliases[old] = new
+	}
+}
+
+// deprecation is a deprecated config path, the value of the path is
+// moved to the path "to" if it's set
+type deprecation struct {
+	path    string
+	to      string
+	message string
+}
+
+// deprecatedTarget returns the new path of the deprecated tag, e.g.
+// `deprecated:"use sys.http.port"`, or "" if there is not
+func deprecatedTarget(message string) string {
+	fields := strings.Fields(message)
+	if len(fields) < 2 || fields[0] != "use" {
+		return ""
+	}
+	return strings.TrimRight(fields[1], ".,;")
+}
+
+// deprecations returns the deprecated paths of the `deprecated` tags and
+// WithAlias, sorted by the path
+func (p *Configer) deprecations() []deprecation {
+	var ret []deprecation
+	for _, f := range p.params {
+		if f.deprecated != "" {
+			ret = append(ret, deprecation{
+				path:    joinPath(append(p.path, f.configPath)...),
+				to:      deprecatedTarget(f.deprecated),
+				message: f.deprecated,
+			})
+		}
+	}
+	for old, new := range p.aliases {
+		ret = append(ret, deprecation{
+			path:    joinPath(append(p.path, old)...),
+			to:      joinPath(append(p.path, new)...),
+			message: "use " + new,
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].path < ret[j].path })
+	return ret
+}
+
+// moveDeprecated moves the values of the deprecated paths of m to the new
+// paths, the value of the new path is kept unless override is true.
+// It returns the deprecated paths which are set.
+func (p *Configer) moveDeprecated(m map[string]interface{}, override bool) (used []string) {
+	for _, d := range p.deprecations() {
+		v, err := Values(m).PathValue(d.path)
+		if err != nil {
+			continue
+		}
+		used = append(used, d.path)
+
+		if d.to == "" {
+			continue
+		}
+		deletePathValue(m, d.path)
+
+		if _, err := Values(m).PathValue(d.to); err == nil && !override {
+			continue
+		}
+		mergeValues(m, pathValueToTable(d.to, v))
+	}
+	return
+}
+
+// deletePathValue deletes the value of the path, and the empty parents
+func deletePathValue(m map[string]interface{}, path string) {
+	keys := parsePath(path)
+	if len(keys) == 0 {
+		return
+	}
+
+	if len(keys) > 1 {
+		child, ok := m[keys[0]].(map[string]interface{})
+		if !ok {
+			return
+		}
+		deletePathValue(child, joinPath(keys[1:]...))
+		if len(child) > 0 {
+			return
+		}
+	}
+	delete(m, keys[0])
+}
+
+// warnDeprecated logs the deprecated paths which are set, and keeps them
+// for DeprecatedKeys
+func (p *Configer) warnDeprecated(used []string) {
+	sort.Strings(used)
+	used = uniqStrings(used)
+
+	if len(used) > 0 {
+		deprecations := map[string]string{}
+		for _, d := range p.deprecations() {
+			deprecations[d.path] = d.message
+		}
+
+		messages := make([]string, len(used))
+		for i, path := range used {
+			messages[i] = path + ": " + deprecations[path]
+		}
+		klog.InfoS("deprecated configs are used", "keys", used, "messages", messages)
+	}
+
+	p.mu.Lock()
+	p.deprecatedKeys = used
+	p.mu.Unlock()
+}
+
+// DeprecatedKeys returns the deprecated config paths which are set by the
+// sources of the last load
+func (p *Configer) DeprecatedKeys() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.deprecatedKeys
+}
+
+func uniqStrings(s []string) []string {
+	ret := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,136 @@
+package configer
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SensitiveKeys are the words of the keys whose values are masked in the Diff
+var SensitiveKeys = []string{"password", "passwd", "secret", "token", "credential", "dsn", "key"}
+
+const maskedValue = "******"
+
+// DiffEntry is a changed value of the path, Old is nil if it's added,
+// New is nil if it's removed, the sensitive values are masked
+type DiffEntry struct {
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+// Diff is the difference between two configers, the paths are of the
+// leaf values, and sorted
+type Diff struct {
+	Added   []DiffEntry
+	Removed []DiffEntry
+	Changed []DiffEntry
+}
+
+// Empty returns true if there is no difference
+func (p *Diff) Empty() bool {
+	return p == nil || len(p.Added)+len(p.Removed)+len(p.Changed) == 0
+}
+
+// Paths returns the sorted paths of all the differences
+func (p *Diff) Paths() []string {
+	if p == nil {
+		return nil
+	}
+
+	ret := []string{}
+	for _, entries := range [][]DiffEntry{p.Added, p.Removed, p.Changed} {
+		for _, e := range entries {
+			ret = append(ret, e.Path)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Affects returns true if any value of the path or under the path is
+// changed, e.g. the modules skip the reload if their configs are not changed
+//
+//	if !diff.Affects("sys.http") {
+//		return nil
+//	}
+func (p *Diff) Affects(path string) bool {
+	for _, s := range p.Paths() {
+		if s == path || strings.HasPrefix(s, path+".") || strings.HasPrefix(s, path+"[") ||
+			strings.HasPrefix(path, s+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff returns the difference from p to the other, e.g. the old and the new configer of the reload
+func (p *Configer) Diff(other *Configer) *Diff {
+	d := &Diff{}
+	diffValues("", p.getData(), other.getData(), d)
+
+	for _, entries := range [][]DiffEntry{d.Added, d.Removed, d.Changed} {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	}
+	return d
+}
+
+func diffValues(prefix string, a, b map[string]interface{}, d *Diff) {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+
+	for k := range keys {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+
+		av, aok := a[k]
+		bv, bok := b[k]
+
+		am, amap := av.(map[string]interface{})
+		bm, bmap := bv.(map[string]interface{})
+		switch {
+		case amap && bmap:
+			diffValues(path, am, bm, d)
+		case !aok && bmap:
+			diffValues(path, map[string]interface{}{}, bm, d)
+		case !bok && amap:
+			diffValues(path, am, map[string]interface{}{}, d)
+		case !aok:
+			d.Added = append(d.Added, DiffEntry{Path: path, New: maskValue(k, bv)})
+		case !bok:
+			d.Removed = append(d.Removed, DiffEntry{Path: path, Old: maskValue(k, av)})
+		case !reflect.DeepEqual(av, bv):
+			d.Changed = append(d.Changed, DiffEntry{Path: path, Old: maskValue(k, av), New: maskValue(k, bv)})
+		}
+	}
+}
+
+// maskValue masks the value of the sensitive key, or the sensitive values of the map
+func maskValue(key string, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	key = strings.ToLower(key)
+	for _, s := range SensitiveKeys {
+		if strings.Contains(key, s) {
+			return maskedValue
+		}
+	}
+
+	if m, ok := v.(map[string]interface{}); ok {
+		ret := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			ret[k] = maskValue(k, v)
+		}
+		return ret
+	}
+	return v
+}
@@ -0,0 +1,264 @@
+package configer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultKeyEnv is the env of the base64 encoded AES key to decrypt the
+// encrypted values, 16, 24 or 32 bytes for AES-128, AES-192 or AES-256
+const DefaultKeyEnv = "CONFIGER_ENCRYPTION_KEY"
+
+// KeyProvider provides the key of the encrypted values, e.g. from the
+// env, or the data key decrypted by the KMS
+type KeyProvider interface {
+	Key() ([]byte, error)
+}
+
+// KeyProviderFunc is a function adapter of KeyProvider
+type KeyProviderFunc func() ([]byte, error)
+
+func (f KeyProviderFunc) Key() ([]byte, error) {
+	return f()
+}
+
+// EnvKey returns the KeyProvider of the base64 encoded key in the env
+func EnvKey(name string) KeyProvider {
+	return KeyProviderFunc(func() ([]byte, error) {
+		v, ok := os.LookupEnv(name)
+		if !ok || v == "" {
+			return nil, fmt.Errorf("env %s is not set", name)
+		}
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+	})
+}
+
+// WithEncryptionKey sets the key provider to decrypt the encrypted values
+// of the value files, default EnvKey(DefaultKeyEnv)
+func WithEncryptionKey(provider KeyProvider) Option {
+	return func(o *Options) {
+		o.keyProvider = provider
+	}
+}
+
+func (p *Options) encryptionKey() ([]byte, error) {
+	if p.keyProvider != nil {
+		return p.keyProvider.Key()
+	}
+	return EnvKey(DefaultKeyEnv).Key()
+}
+
+// ENC[AES256_GCM,data:<base64>,iv:<base64>]
+var encryptedValueRegexp = regexp.MustCompile(`^ENC\[AES(128|192|256)_GCM,data:([A-Za-z0-9+/=]*),iv:([A-Za-z0-9+/=]+)\]$`)
+
+func isEncryptedValue(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && encryptedValueRegexp.MatchString(s)
+}
+
+// EncryptValues encrypts the leaf values of the paths in place, all the
+// leaf values if the paths are not set, like sops. The keys are kept in
+// plaintext, and the path of the value is authenticated, so the encrypted
+// value can't be moved to another key.
+func EncryptValues(values map[string]interface{}, key []byte, paths ...string) error {
+	aead, err := newValueAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	return walkLeafValues("", values, func(path string, v interface{}) (interface{}, error) {
+		if isEncryptedValue(v) || !matchPaths(path, paths) {
+			return v, nil
+		}
+		return encryptValue(aead, len(key), path, v)
+	})
+}
+
+// DecryptValues decrypts the encrypted leaf values in place
+func DecryptValues(values map[string]interface{}, key []byte) error {
+	aead, err := newValueAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	return walkLeafValues("", values, func(path string, v interface{}) (interface{}, error) {
+		if !isEncryptedValue(v) {
+			return v, nil
+		}
+		return decryptValue(aead, path, v.(string))
+	})
+}
+
+// EncryptValueFile returns the yaml of the value file with the leaf
+// values of the paths encrypted, see EncryptValues
+func EncryptValueFile(data, key []byte, paths ...string) ([]byte, error) {
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	if err := EncryptValues(values, key, paths...); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(values)
+}
+
+// DecryptValueFile returns the yaml of the value file with the values decrypted
+func DecryptValueFile(data, key []byte) ([]byte, error) {
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	if err := DecryptValues(values, key); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(values)
+}
+
+// decryptValueFile decrypts the values of the file if there is any
+// encrypted value, the key is only required by the encrypted files
+func (p *Options) decryptValueFile(file string, values map[string]interface{}) error {
+	encrypted := false
+	walkLeafValues("", values, func(path string, v interface{}) (interface{}, error) {
+		encrypted = encrypted || isEncryptedValue(v)
+		return v, nil
+	})
+	if !encrypted {
+		return nil
+	}
+
+	key, err := p.encryptionKey()
+	if err != nil {
+		return fmt.Errorf("failed to get the key to decrypt %s: %s", file, err)
+	}
+	if err := DecryptValues(values, key); err != nil {
+		return fmt.Errorf("failed to decrypt %s: %s", file, err)
+	}
+	return nil
+}
+
+func newValueAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptValue(aead cipher.AEAD, keySize int, path string, v interface{}) (string, error) {
+	// the plaintext is the json of the value, to keep the type
+	plaintext, err := yaml.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", path, err)
+	}
+	if plaintext, err = yaml.YAMLToJSON(plaintext); err != nil {
+		return "", fmt.Errorf("%s: %s", path, err)
+	}
+
+	iv := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return "", err
+	}
+
+	data := aead.Seal(nil, iv, plaintext, []byte(path))
+	return fmt.Sprintf("ENC[AES%d_GCM,data:%s,iv:%s]", keySize*8,
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv)), nil
+}
+
+func decryptValue(aead cipher.AEAD, path, s string) (interface{}, error) {
+	m := encryptedValueRegexp.FindStringSubmatch(s)
+
+	data, err := base64.StdEncoding.DecodeString(m[2])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	iv, err := base64.StdEncoding.DecodeString(m[3])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if len(iv) != aead.NonceSize() {
+		return nil, fmt.Errorf("%s: invalid iv", path)
+	}
+
+	plaintext, err := aead.Open(nil, iv, data, []byte(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	var v interface{}
+	if err := yaml.Unmarshal(plaintext, &v); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return v, nil
+}
+
+// matchPaths returns true if the path is or is under one of the paths
+func matchPaths(path string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, s := range paths {
+		if path == s || strings.HasPrefix(path, s+".") || strings.HasPrefix(path, s+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// walkLeafValues calls fn with the leaf values of the maps and the lists,
+// and replaces the values with the returns
+func walkLeafValues(path string, v interface{}, fn func(path string, v interface{}) (interface{}, error)) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, v := range t {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			if isTable(v) {
+				if err := walkLeafValues(p, v, fn); err != nil {
+					return err
+				}
+				continue
+			}
+			rv, err := fn(p, v)
+			if err != nil {
+				return err
+			}
+			t[k] = rv
+		}
+	case []interface{}:
+		for i, v := range t {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			if isTable(v) {
+				if err := walkLeafValues(p, v, fn); err != nil {
+					return err
+				}
+				continue
+			}
+			rv, err := fn(p, v)
+			if err != nil {
+				return err
+			}
+			t[i] = rv
+		}
+	}
+	return nil
+}
+
+func isTable(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package configer
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/yubo/golib/util"
+	utilnet "github.com/yubo/golib/util/net"
+	"k8s.io/klog/v2"
+)
+
+// WithEnvPrefix binds the registered configs without the env tag to the
+// envs of the prefix and the config path, e.g. the prefix "MYAPP" binds
+// sys.db.dsn to MYAPP_SYS_DB_DSN, the explicit env tag takes precedence.
+// The envs override the defaults, and are overridden by the value files.
+func WithEnvPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.envPrefix = strings.TrimSuffix(strings.ToUpper(prefix), "_")
+	}
+}
+
+// envName returns the env of the param, the env tag or the one bound by the prefix
+func (p *Options) envName(f *param) string {
+	if f.envName != "" {
+		return f.envName
+	}
+	if p.envPrefix == "" || f.configPath == "" {
+		return ""
+	}
+	return p.envPrefix + "_" + strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToUpper(f.configPath))
+}
+
+// mergePrefixEnvValues merges the envs bound by the prefix, the envs of
+// the env tags have been the defaults, see GetTagOpts
+func (p *Configer) mergePrefixEnvValues(into map[string]interface{}) {
+	if !p.enableEnv || p.envPrefix == "" {
+		return
+	}
+
+	for _, f := range p.params {
+		if f.envName != "" {
+			continue
+		}
+
+		name := p.envName(f)
+		if name == "" {
+			continue
+		}
+
+		val, ok := p.getEnv(name)
+		if !ok {
+			continue
+		}
+
+		v, err := castEnvValue(f.typ, val)
+		if err != nil {
+			klog.InfoS("invalid env value, ignored", "env", name, "err", err)
+			continue
+		}
+
+		path := joinPath(append(p.path, f.configPath)...)
+		klog.V(7).InfoS("env", "path", path, "env", name, "value", v)
+		mergeValues(into, pathValueToTable(path, v))
+	}
+}
+
+func castEnvValue(typ reflect.Type, val string) (interface{}, error) {
+	if typ == nil {
+		return val, nil
+	}
+
+	switch reflect.Zero(typ).Interface().(type) {
+	case time.Duration:
+		return cast.ToDurationE(val)
+	case []time.Duration:
+		return cast.ToDurationSliceE(strings.Split(val, ","))
+	case net.IP:
+		if ip := net.ParseIP(val); ip != nil {
+			return ip, nil
+		}
+		return nil, fmt.Errorf("invalid ip %q", val)
+	case util.ByteSize:
+		return util.ParseByteSize(val)
+	case utilnet.HostPort:
+		return utilnet.ParseHostPort(val)
+	case []string:
+		return strings.Split(val, ","), nil
+	case []int:
+		return cast.ToIntSliceE(strings.Split(val, ","))
+	case map[string]string:
+		ret := map[string]string{}
+		for _, kv := range strings.Split(val, ",") {
+			if kv = strings.TrimSpace(kv); kv == "" {
+				continue
+			}
+			if i := strings.IndexByte(kv, '='); i > 0 {
+				ret[kv[:i]] = kv[i+1:]
+			} else {
+				ret[kv] = ""
+			}
+		}
+		return ret, nil
+	}
+
+	if isStructSlice(typ) {
+		v, err := newYamlValue(typ, val)
+		if err != nil {
+			return nil, err
+		}
+		return v.Get(), nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return cast.ToBoolE(val)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cast.ToInt64E(val)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cast.ToUint64E(val)
+	case reflect.Float32, reflect.Float64:
+		return cast.ToFloat64E(val)
+	}
+	return val, nil
+}
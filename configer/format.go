@@ -0,0 +1,119 @@
+package configer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"sigs.k8s.io/yaml"
+)
+
+// unmarshalValues parses the value file by the format of the extension,
+// .toml, .json, or yaml by default. The values of the toml are converted
+// to the same types as the yaml, e.g. the numbers are float64.
+func unmarshalValues(file string, data []byte, into *map[string]interface{}) error {
+	switch valueFileFormat(file) {
+	case "toml":
+		m := map[string]interface{}{}
+		if _, err := toml.Decode(string(data), &m); err != nil {
+			return err
+		}
+		b, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		return yaml.Unmarshal(b, into)
+	case "json":
+		return json.Unmarshal(data, into)
+	}
+
+	// the documents of the yaml are merged in order
+	docs := yamlDocumentSeparator.Split(string(data), -1)
+	if len(docs) == 1 {
+		return yaml.Unmarshal(data, into)
+	}
+
+	if *into == nil {
+		*into = map[string]interface{}{}
+	}
+	for i, doc := range docs {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		m := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(doc), &m); err != nil {
+			return fmt.Errorf("document %d: %s", i, err)
+		}
+		mergeValues(*into, m)
+	}
+	return nil
+}
+
+var yamlDocumentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// isValueFile returns true if the file is a value file of the directory
+func isValueFile(file string) bool {
+	if strings.HasPrefix(filepath.Base(file), ".") {
+		return false
+	}
+	switch valueFileFormat(file) {
+	case "yaml", "yml", "json", "toml":
+		return true
+	}
+	return false
+}
+
+// expandValueFiles replaces the directories with the value files of the
+// directories in lexical order, like the conf.d, the hidden files and
+// the subdirectories are skipped
+func expandValueFiles(files []string) ([]string, error) {
+	var ret []string
+	for _, file := range files {
+		if strings.Contains(file, "://") {
+			ret = append(ret, file)
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil || !info.IsDir() {
+			// the error is returned by the reading
+			ret = append(ret, file)
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(file)
+		if err != nil {
+			return nil, err
+		}
+		// ReadDir returns the entries sorted by the name
+		for _, entry := range entries {
+			name := filepath.Join(file, entry.Name())
+			if !isValueFile(name) {
+				continue
+			}
+			// the symlinks of the configmaps are followed
+			if info, err := os.Stat(name); err != nil || info.IsDir() {
+				continue
+			}
+			ret = append(ret, name)
+		}
+	}
+	return ret, nil
+}
+
+// valueFileFormat returns the extension of the local file or the path of the uri
+func valueFileFormat(file string) string {
+	if strings.Contains(file, "://") {
+		if u, err := url.Parse(file); err == nil {
+			file = u.Path
+		}
+	}
+	return strings.TrimPrefix(strings.ToLower(path.Ext(file)), ".")
+}
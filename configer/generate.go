@@ -0,0 +1,141 @@
+package configer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// sortedParams returns the params ordered by the config path, the
+// params of the same parent are adjacent
+func (p *Options) sortedParams() []*param {
+	params := make([]*param, 0, len(p.params))
+	for _, f := range p.params {
+		if f.configPath != "" {
+			params = append(params, f)
+		}
+	}
+
+	sort.SliceStable(params, func(i, j int) bool {
+		a, b := parsePath(params[i].configPath), parsePath(params[j].configPath)
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return params
+}
+
+// value returns the default value of the param, or the zero value of the type
+func (f *param) value() interface{} {
+	if f.defaultValue != nil {
+		return f.defaultValue
+	}
+	if f.typ != nil {
+		return reflect.Zero(f.typ).Interface()
+	}
+	return nil
+}
+
+func (f *param) flagName() string {
+	if f.flag == "" {
+		return ""
+	}
+	if f.shothand != "" {
+		return fmt.Sprintf("--%s, -%s", f.flag, f.shothand)
+	}
+	return "--" + f.flag
+}
+
+// GenerateYaml returns the sample config of the registered configs, with
+// the descriptions, env names and flags as the comments, e.g.
+//
+//	http:
+//	  # the address to listen on
+//	  # env: HTTP_ADDR, flag: --http-addr
+//	  addr: ":8080"
+//
+// the values are the defaults, or the zero values if not set
+func (p *Configer) GenerateYaml() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var last []string
+
+	for _, f := range p.sortedParams() {
+		path := parsePath(f.configPath)
+
+		// the common parents have been written
+		n := 0
+		for n < len(last) && n < len(path)-1 && last[n] == path[n] {
+			n++
+		}
+		for i := n; i < len(path)-1; i++ {
+			fmt.Fprintf(buf, "%s%s:\n", strings.Repeat("  ", i), path[i])
+		}
+		last = path
+
+		indent := strings.Repeat("  ", len(path)-1)
+		if f.description != "" {
+			fmt.Fprintf(buf, "%s# %s\n", indent, f.description)
+		}
+		if f.deprecated != "" {
+			fmt.Fprintf(buf, "%s# deprecated: %s\n", indent, f.deprecated)
+		}
+
+		var meta []string
+		if name := p.envName(f); name != "" {
+			meta = append(meta, "env: "+name)
+		}
+		if name := f.flagName(); name != "" {
+			meta = append(meta, "flag: "+name)
+		}
+		if len(meta) > 0 {
+			fmt.Fprintf(buf, "%s# %s\n", indent, strings.Join(meta, ", "))
+		}
+
+		// json is a valid flow style yaml
+		value, err := json.Marshal(f.value())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", f.configPath, err)
+		}
+		fmt.Fprintf(buf, "%s%s: %s\n", indent, path[len(path)-1], value)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// GenerateMarkdown returns the table of the registered configs, with the
+// paths, types, defaults, env names, flags and descriptions
+func (p *Configer) GenerateMarkdown() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("| Path | Type | Default | Env | Flag | Description |\n")
+	buf.WriteString("| ---- | ---- | ------- | --- | ---- | ----------- |\n")
+
+	escape := strings.NewReplacer("|", `\|`, "\n", " ").Replace
+	for _, f := range p.sortedParams() {
+		typ, def := "", ""
+		if f.typ != nil {
+			typ = f.typ.String()
+		}
+		if f.defaultValue != nil {
+			def = "`" + fmt.Sprint(f.defaultValue) + "`"
+		}
+
+		env, flag := "", ""
+		if name := p.envName(f); name != "" {
+			env = "`" + name + "`"
+		}
+		if name := f.flagName(); name != "" {
+			flag = "`" + name + "`"
+		}
+
+		fmt.Fprintf(buf, "| %s | %s | %s | %s | %s | %s |\n", escape(f.configPath),
+			escape(typ), escape(def), env, flag, escape(f.description))
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,166 @@
+package configer
+
+import (
+	"reflect"
+	"strings"
+)
+
+// MergeStrategy is how the list of the value file is merged into the list
+// of the previous sources, the default is MergeReplace
+type MergeStrategy string
+
+const (
+	// MergeReplace replaces the previous list
+	MergeReplace MergeStrategy = "replace"
+	// MergeAppend appends the elements to the previous list
+	MergeAppend MergeStrategy = "append"
+
+	patchDirective = "$patch"
+	patchKey       = "$key"
+	patchDelete    = "delete"
+	defaultKey     = "name"
+)
+
+// MergeByKey merges the maps of the same key into the elements of the
+// previous list, and appends the others
+func MergeByKey(key string) MergeStrategy {
+	return MergeStrategy("merge:" + key)
+}
+
+func (s MergeStrategy) parse() (name, key string) {
+	name = string(s)
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name, key = name[:i], name[i+1:]
+	}
+	if name == "merge" && key == "" {
+		key = defaultKey
+	}
+	return
+}
+
+// WithMergeStrategy sets the merge strategy of the list of the path, e.g.
+//
+//	WithMergeStrategy("sys.servers", MergeByKey("name"))
+//
+// the strategy can also be set inline by the first element of the list,
+// which is the $patch directive, e.g.
+//
+//	servers:
+//	  - $patch: merge  # or replace, append
+//	    $key: name     # the key of merge, default "name"
+//	  - name: b
+//	    port: 8081
+//	  - name: c
+//	    $patch: delete # delete the element of the key
+func WithMergeStrategy(path string, strategy MergeStrategy) Option {
+	return func(o *Options) {
+		if o.mergeStrategies == nil {
+			o.mergeStrategies = map[string]MergeStrategy{}
+		}
+		o.mergeStrategies[path] = strategy
+	}
+}
+
+// patchStrategy returns the strategy of the $patch directive of the
+// list, and the list without the directive
+func patchStrategy(list []interface{}) (MergeStrategy, []interface{}) {
+	if len(list) == 0 {
+		return "", list
+	}
+
+	m, ok := list[0].(map[string]interface{})
+	if !ok {
+		return "", list
+	}
+	patch, ok := m[patchDirective].(string)
+	if !ok || patch == patchDelete {
+		return "", list
+	}
+
+	if key, ok := m[patchKey].(string); ok && patch == "merge" {
+		return MergeByKey(key), list[1:]
+	}
+	return MergeStrategy(patch), list[1:]
+}
+
+// mergeList merges the list src into the previous value of the path
+func mergeList(path string, into interface{}, src []interface{}, strategies map[string]MergeStrategy) []interface{} {
+	strategy, src := patchStrategy(src)
+	if strategy == "" {
+		strategy = strategies[path]
+	}
+
+	prev, ok := into.([]interface{})
+	if !ok {
+		prev = nil
+	}
+
+	name, key := strategy.parse()
+	switch name {
+	case string(MergeAppend):
+		ret := make([]interface{}, 0, len(prev)+len(src))
+		ret = append(ret, prev...)
+		return append(ret, withoutDeleted(src)...)
+	case "merge":
+		return mergeListByKey(path, key, prev, src, strategies)
+	}
+	return withoutDeleted(src)
+}
+
+func mergeListByKey(path, key string, prev, src []interface{}, strategies map[string]MergeStrategy) []interface{} {
+	ret := make([]interface{}, len(prev))
+	copy(ret, prev)
+
+	for _, v := range src {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			ret = append(ret, v)
+			continue
+		}
+
+		i := indexByKey(ret, key, m[key])
+		if m[patchDirective] == patchDelete {
+			if i >= 0 {
+				ret = append(ret[:i], ret[i+1:]...)
+			}
+			continue
+		}
+		if i < 0 {
+			ret = append(ret, mergeValuesWithStrategies(path, map[string]interface{}{}, m, strategies))
+			continue
+		}
+
+		into := map[string]interface{}{}
+		if prevMap, ok := ret[i].(map[string]interface{}); ok {
+			for k, v := range prevMap {
+				into[k] = v
+			}
+		}
+		ret[i] = mergeValuesWithStrategies(path, into, m, strategies)
+	}
+	return ret
+}
+
+func indexByKey(list []interface{}, key string, value interface{}) int {
+	if value == nil {
+		return -1
+	}
+	for i, v := range list {
+		if m, ok := v.(map[string]interface{}); ok && reflect.DeepEqual(m[key], value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// withoutDeleted returns the list without the elements of `$patch: delete`
+func withoutDeleted(list []interface{}) []interface{} {
+	ret := make([]interface{}, 0, len(list))
+	for _, v := range list {
+		if m, ok := v.(map[string]interface{}); ok && m[patchDirective] == patchDelete {
+			continue
+		}
+		ret = append(ret, v)
+	}
+	return ret
+}
@@ -1,7 +1,10 @@
 package configer
 
 import (
+	"time"
+
 	"github.com/spf13/pflag"
+	"github.com/yubo/golib/util"
 	"sigs.k8s.io/yaml"
 )
 
@@ -26,7 +29,14 @@ type Options struct {
 	maxDepth      int
 	allowEmptyEnv bool
 	flagSet       *pflag.FlagSet
-	params        []*param // all of config fields
+	nameMapper    util.NameMapper   // derive the path from the field name if the json tag is not set
+	params        []*param          // all of config fields
+	pollInterval  time.Duration     // the interval to poll the value sources, see WithPollInterval
+	envPrefix     string            // the prefix of the envs bound to the config paths, see WithEnvPrefix
+	aliases       map[string]string // the deprecated paths to the new paths, see WithAlias
+	keyProvider   KeyProvider       // the key of the encrypted values, see WithEncryptionKey
+
+	mergeStrategies map[string]MergeStrategy // the merge strategies of the lists, see WithMergeStrategy
 }
 
 func (s *Options) SetOptions(enableEnv, allowEmptyEnv bool, maxDepth int, fs *pflag.FlagSet) {
@@ -81,6 +91,22 @@ func (in *Options) DeepCopy() (out *Options) {
 		copy(*out, *in)
 	}
 
+	if in.aliases != nil {
+		in, out := &in.aliases, &out.aliases
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+
+	if in.mergeStrategies != nil {
+		in, out := &in.mergeStrategies, &out.mergeStrategies
+		*out = make(map[string]MergeStrategy, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+
 	// skip in.params
 
 	return
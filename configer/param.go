@@ -1,14 +1,19 @@
 package configer
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
 	"reflect"
 	"strings"
 	"time"
 
 	"github.com/spf13/cast"
 	"github.com/spf13/pflag"
+	"github.com/yubo/golib/util"
+	utilnet "github.com/yubo/golib/util/net"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
 )
 
 type param struct {
@@ -18,6 +23,11 @@ type param struct {
 	configPath   string      // config path
 	flagValue    interface{} // flag's value
 	defaultValue interface{} // flag's default value
+	description  string      // the description tag
+	deprecated   string      // the deprecated tag, e.g. "use sys.http.port"
+	enum         []string    // the oneof of the validate tag
+	candidates   []string    // the candidates tag, the values of the completion
+	typ          reflect.Type
 }
 
 func pathValueToTable(path string, val interface{}) map[string]interface{} {
@@ -35,8 +45,8 @@ func (p *Configer) Envs() (names []string) {
 		return
 	}
 	for _, f := range p.params {
-		if f.envName != "" {
-			names = append(names, f.envName)
+		if name := p.envName(f); name != "" {
+			names = append(names, name)
 		}
 	}
 	return
@@ -56,6 +66,12 @@ func (p *Configer) Flags() (names []string) {
 
 func (p *Configer) mergeDefaultValues(into map[string]interface{}) {
 	for _, f := range p.params {
+		// the default of the deprecated config is only used if it's from the env
+		if deprecatedTarget(f.deprecated) != "" {
+			if _, ok := p.getEnv(f.envName); f.envName == "" || !p.enableEnv || !ok {
+				continue
+			}
+		}
 		if v := f.defaultValue; v != nil {
 			klog.V(7).InfoS("def", "path", joinPath(append(p.path, f.configPath)...), "value", v)
 			mergeValues(into, pathValueToTable(joinPath(append(p.path, f.configPath)...), v))
@@ -93,6 +109,9 @@ func (p *Configer) getFlagValue(f *param) interface{} {
 	}
 
 	if p.flagSet.Changed(f.flag) {
+		if v, ok := f.flagValue.(interface{ Get() interface{} }); ok {
+			return v.Get()
+		}
 		return reflect.ValueOf(f.flagValue).Elem().Interface()
 	}
 
@@ -225,6 +244,22 @@ func (p *Options) addConfigs(path []string, fs *pflag.FlagSet, rt reflect.Type)
 			addConfigField(fs, ps, opt, fs.Float64, fs.Float64P, cast.ToFloat64(def))
 		case time.Duration:
 			addConfigField(fs, ps, opt, fs.Duration, fs.DurationP, cast.ToDuration(def))
+		case []time.Duration:
+			addConfigField(fs, ps, opt, fs.DurationSlice, fs.DurationSliceP, splitDurations(def))
+		case net.IP:
+			addConfigField(fs, ps, opt, fs.IP, fs.IPP, net.ParseIP(def))
+		case util.ByteSize:
+			size, err := util.ParseByteSize(def)
+			if def != "" && err != nil {
+				return fmt.Errorf("%s: %s", ps, err)
+			}
+			addConfigVar(fs, ps, opt, &size, size)
+		case utilnet.HostPort:
+			addr, err := utilnet.ParseHostPort(def)
+			if def != "" && err != nil {
+				return fmt.Errorf("%s: %s", ps, err)
+			}
+			addConfigVar(fs, ps, opt, &addr, addr)
 		case []string:
 			addConfigField(fs, ps, opt, fs.StringArray, fs.StringArrayP, cast.ToStringSlice(def))
 		case []int:
@@ -232,12 +267,40 @@ func (p *Options) addConfigs(path []string, fs *pflag.FlagSet, rt reflect.Type)
 		case map[string]string:
 			addConfigField(fs, ps, opt, fs.StringToString, fs.StringToStringP, cast.ToStringMapString(def))
 		default:
+			if isStructSlice(ft) {
+				value, err := newYamlValue(ft, def)
+				if err != nil {
+					return fmt.Errorf("%s: invalid default, %s", ps, err)
+				}
+				addConfigVar(fs, ps, opt, value, value.Get())
+				continue
+			}
 			klog.V(6).InfoS("add config unsupported", "type", ft.String(), "path", joinPath(path...), "kind", ft.Kind())
 		}
 	}
 	return nil
 }
 
+// splitDurations returns the durations separated by ','
+func splitDurations(s string) []time.Duration {
+	if s == "" {
+		return nil
+	}
+	return cast.ToDurationSlice(strings.Split(s, ","))
+}
+
+// isStructSlice returns true if typ is the slice of structs or the pointers of structs
+func isStructSlice(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Slice {
+		return false
+	}
+	elem := typ.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	return elem.Kind() == reflect.Struct && elem != reflect.TypeOf(time.Time{})
+}
+
 type TagOpts struct {
 	Name        string   // field name
 	Json        string   // json:"{json}"
@@ -247,6 +310,9 @@ type TagOpts struct {
 	Description string   // description:"{description}"
 	Skip        bool     // if json:"-"
 	Arg         string   // arg:"{arg}"  args[0] arg1... -- arg2...
+	Validate    string   // validate:"{rules}"
+	Deprecated  string   // deprecated:"use {path}"
+	Candidates  []string // candidates:"{value1},{value2}"
 }
 
 func (p TagOpts) String() string {
@@ -273,6 +339,8 @@ func GetTagOpts(sf reflect.StructField, in ...*Options) (tag *TagOpts) {
 
 	if json != "" {
 		tag.Json = json
+	} else if options.nameMapper != nil {
+		tag.Json = options.nameMapper.Map(sf.Name)
 	}
 
 	if flag := strings.Split(strings.TrimSpace(sf.Tag.Get("flag")), ","); len(flag) > 0 && flag[0] != "" && flag[0] != "-" {
@@ -282,6 +350,11 @@ func GetTagOpts(sf reflect.StructField, in ...*Options) (tag *TagOpts) {
 	tag.Default = sf.Tag.Get("default")
 	tag.Description = sf.Tag.Get("description")
 	tag.Arg = sf.Tag.Get("arg")
+	tag.Validate = sf.Tag.Get("validate")
+	tag.Deprecated = sf.Tag.Get("deprecated")
+	if candidates := strings.TrimSpace(sf.Tag.Get("candidates")); candidates != "" {
+		tag.Candidates = strings.Split(candidates, ",")
+	}
 	tag.Env = strings.Replace(strings.ToUpper(sf.Tag.Get("env")), "-", "_", -1)
 
 	if !options.enableEnv || tag.Env == "" {
@@ -334,15 +407,31 @@ func (o tagOptions) Contains(optionName string) bool {
 	return false
 }
 
-func addConfigField(fs *pflag.FlagSet, path string, opt *TagOpts, varFn, varPFn, def interface{}) {
+func newParam(path string, opt *TagOpts, def interface{}) *param {
 	v := &param{
 		configPath: path,
 		envName:    opt.Env,
+		// the env is appended to the description by GetTagOpts
+		description: strings.TrimSuffix(opt.Description, fmt.Sprintf(" (env %s)", opt.Env)),
+		typ:         reflect.TypeOf(def),
+		deprecated:  opt.Deprecated,
+		candidates:  opt.Candidates,
+	}
+
+	for _, rule := range strings.Split(opt.Validate, ",") {
+		if rule = strings.TrimSpace(rule); strings.HasPrefix(rule, "oneof=") {
+			v.enum = strings.Fields(strings.TrimPrefix(rule, "oneof="))
+		}
 	}
 
 	if opt.Default != "" {
 		v.defaultValue = def
 	}
+	return v
+}
+
+func addConfigField(fs *pflag.FlagSet, path string, opt *TagOpts, varFn, varPFn, def interface{}) {
+	v := newParam(path, opt, def)
 
 	// add flag
 	switch len(opt.Flag) {
@@ -372,3 +461,65 @@ func addConfigField(fs *pflag.FlagSet, path string, opt *TagOpts, varFn, varPFn,
 
 	GlobalOptions.params = append(GlobalOptions.params, v)
 }
+
+// addConfigVar adds the config of the pflag.Value, the value holds the
+// default, and should be a pointer or implement Get() like yamlValue
+func addConfigVar(fs *pflag.FlagSet, path string, opt *TagOpts, value pflag.Value, def interface{}) {
+	v := newParam(path, opt, def)
+	v.flagValue = value
+
+	switch len(opt.Flag) {
+	case 0:
+		// nothing
+	case 1:
+		v.flag = opt.Flag[0]
+		fs.Var(value, opt.Flag[0], opt.Description)
+	case 2:
+		v.flag = opt.Flag[0]
+		v.shothand = opt.Flag[1]
+		fs.VarP(value, opt.Flag[0], opt.Flag[1], opt.Description)
+	default:
+		panic("invalid flag value")
+	}
+
+	GlobalOptions.params = append(GlobalOptions.params, v)
+}
+
+// yamlValue is the flag of the slice of structs, the value is a yaml or
+// json list, e.g. --servers='[{"addr": "127.0.0.1:80"}]'
+type yamlValue struct {
+	ptr reflect.Value
+}
+
+func newYamlValue(typ reflect.Type, def string) (*yamlValue, error) {
+	v := &yamlValue{ptr: reflect.New(typ)}
+	if def == "" {
+		return v, nil
+	}
+	return v, v.Set(def)
+}
+
+func (p *yamlValue) Get() interface{} {
+	return p.ptr.Elem().Interface()
+}
+
+func (p *yamlValue) Set(s string) error {
+	ptr := reflect.New(p.ptr.Elem().Type())
+	if err := yaml.Unmarshal([]byte(s), ptr.Interface()); err != nil {
+		return err
+	}
+	p.ptr.Elem().Set(ptr.Elem())
+	return nil
+}
+
+func (p *yamlValue) String() string {
+	if p.ptr.Elem().IsNil() {
+		return ""
+	}
+	b, _ := json.Marshal(p.ptr.Elem().Interface())
+	return string(b)
+}
+
+func (p *yamlValue) Type() string {
+	return "yaml"
+}
@@ -0,0 +1,112 @@
+package configer
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/yubo/golib/util"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// JSONSchema returns the JSON Schema of the registered configs, with the
+// types, defaults, descriptions and the enums of the `validate:"oneof=..."`,
+// e.g. the editors and CI validate the value files before deploy
+//
+//	b, err := proc.ConfigerMustFrom(ctx).JSONSchema()
+//	ioutil.WriteFile("schema.json", b, 0644)
+func (p *Configer) JSONSchema() ([]byte, error) {
+	root := newObjectSchema()
+
+	for _, f := range p.sortedParams() {
+		path := parsePath(f.configPath)
+
+		node := root
+		for _, name := range path[:len(path)-1] {
+			props := node["properties"].(map[string]interface{})
+			child, ok := props[name].(map[string]interface{})
+			if !ok {
+				child = newObjectSchema()
+				props[name] = child
+			}
+			node = child
+		}
+
+		node["properties"].(map[string]interface{})[path[len(path)-1]] = f.schema()
+	}
+
+	root["$schema"] = jsonSchemaDraft
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func newObjectSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{},
+	}
+}
+
+func (f *param) schema() map[string]interface{} {
+	ret := typeSchema(f.typ)
+
+	if f.description != "" {
+		ret["description"] = f.description
+	}
+
+	if f.deprecated != "" {
+		ret["deprecated"] = true
+	}
+
+	if f.defaultValue != nil {
+		if d, ok := f.defaultValue.(time.Duration); ok {
+			ret["default"] = d.String()
+		} else {
+			ret["default"] = f.defaultValue
+		}
+	}
+
+	if len(f.enum) > 0 {
+		enum := make([]interface{}, 0, len(f.enum))
+		for _, s := range f.enum {
+			if v, err := castEnvValue(f.typ, s); err == nil {
+				enum = append(enum, v)
+			}
+		}
+		ret["enum"] = enum
+	}
+
+	return ret
+}
+
+func typeSchema(typ reflect.Type) map[string]interface{} {
+	if typ == nil {
+		return map[string]interface{}{}
+	}
+
+	switch typ {
+	case reflect.TypeOf(time.Duration(0)), reflect.TypeOf(util.ByteSize(0)):
+		// "5s", "512Mi", or the integer
+		return map[string]interface{}{"type": []string{"string", "integer"}}
+	case reflect.TypeOf(net.IP{}):
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(typ.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(typ.Elem())}
+	}
+	return map[string]interface{}{}
+}
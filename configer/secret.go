@@ -0,0 +1,152 @@
+package configer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SecretProvider resolves the secret references in the values, the
+// references are resolved by Read, so the secrets are never kept in the
+// config files or the merged data, e.g.
+//
+//	db:
+//	  dsn: env://DB_DSN                   # the env DB_DSN
+//	  password: file:///run/secrets/db    # the content of the file
+//	  token: secret://vault/app/db#token  # the provider registered as "vault"
+//
+// the env and file providers are built in, the "secret://<name>/..."
+// is resolved by the provider registered by RegisterSecretProvider(name)
+type SecretProvider interface {
+	Resolve(ref *url.URL) (string, error)
+}
+
+// SecretProviderFunc is a function adapter of SecretProvider
+type SecretProviderFunc func(ref *url.URL) (string, error)
+
+func (f SecretProviderFunc) Resolve(ref *url.URL) (string, error) {
+	return f(ref)
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{
+		"env":  SecretProviderFunc(resolveEnvSecret),
+		"file": SecretProviderFunc(resolveFileSecret),
+	}
+)
+
+// RegisterSecretProvider registers the provider of the references
+// "secret://<name>/path#key"
+func RegisterSecretProvider(name string, provider SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+
+	secretProviders[name] = provider
+}
+
+func getSecretProvider(name string) (SecretProvider, bool) {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+
+	p, ok := secretProviders[name]
+	return p, ok
+}
+
+// env://NAME
+func resolveEnvSecret(ref *url.URL) (string, error) {
+	name := ref.Host + ref.Path
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("env %s is not set", name)
+	}
+	return v, nil
+}
+
+// file:///path, the trailing newline is trimmed
+func resolveFileSecret(ref *url.URL) (string, error) {
+	b, err := ioutil.ReadFile(ref.Path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// resolveSecret returns the secret of the reference s, ok is false if
+// s isn't a reference
+func resolveSecret(s string) (secret string, ok bool, err error) {
+	i := strings.Index(s, "://")
+	if i <= 0 {
+		return "", false, nil
+	}
+
+	var provider SecretProvider
+	switch scheme := s[:i]; scheme {
+	case "env", "file":
+		provider, _ = getSecretProvider(scheme)
+	case "secret":
+		name := s[i+3:]
+		if j := strings.IndexByte(name, '/'); j >= 0 {
+			name = name[:j]
+		}
+		if provider, ok = getSecretProvider(name); !ok {
+			return "", true, fmt.Errorf("secret provider %q is not registered", name)
+		}
+	default:
+		return "", false, nil
+	}
+
+	ref, err := url.Parse(s)
+	if err != nil {
+		return "", true, err
+	}
+
+	secret, err = provider.Resolve(ref)
+	return secret, true, err
+}
+
+// resolveSecrets returns a copy of v with the secret references resolved,
+// v is not changed
+func resolveSecrets(path string, v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		secret, ok, err := resolveSecret(t)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		if ok {
+			return secret, nil
+		}
+		return t, nil
+	case Values:
+		return resolveSecrets(path, map[string]interface{}(t))
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			rv, err := resolveSecrets(p, v)
+			if err != nil {
+				return nil, err
+			}
+			ret[k] = rv
+		}
+		return ret, nil
+	case []interface{}:
+		ret := make([]interface{}, len(t))
+		for i, v := range t {
+			rv, err := resolveSecrets(fmt.Sprintf("%s[%d]", path, i), v)
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = rv
+		}
+		return ret, nil
+	}
+	return v, nil
+}
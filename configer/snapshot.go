@@ -0,0 +1,67 @@
+package configer
+
+// Snapshot returns a deep copy of the values, which can be restored by
+// Restore, e.g. the tests change the configs and restore them at the end
+//
+//	defer cf.Restore(cf.Snapshot())
+//	cf.Set("sys.http.port", 8080)
+func (p *Configer) Snapshot() Values {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return Values(copyValues(p.data).(map[string]interface{}))
+}
+
+// Restore replaces the values with the snapshot, the OnChange callbacks
+// are called with the changed paths
+func (p *Configer) Restore(snapshot Values) {
+	p.writeMu.Lock()
+	changed := p.swap(copyValues(map[string]interface{}(snapshot)).(map[string]interface{}))
+	p.writeMu.Unlock()
+
+	p.notify(changed)
+}
+
+// NewTestConfiger returns the configer of the values, the value files,
+// flags, envs and GlobalOptions are not used, e.g.
+//
+//	cf := configer.NewTestConfiger(map[string]interface{}{
+//		"sys": map[string]interface{}{"port": 8080},
+//	})
+func NewTestConfiger(values map[string]interface{}) *Configer {
+	options := newOptions()
+	options.enableFlag = false
+	options.enableEnv = false
+
+	data := map[string]interface{}{}
+	if values != nil {
+		data = copyValues(values).(map[string]interface{})
+	}
+
+	return &Configer{
+		Options:  options,
+		data:     data,
+		prepared: true,
+	}
+}
+
+// copyValues returns a deep copy of the maps and the lists
+func copyValues(v interface{}) interface{} {
+	switch t := v.(type) {
+	case Values:
+		return Values(copyValues(map[string]interface{}(t)).(map[string]interface{}))
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			ret[k] = copyValues(v)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(t))
+		for i, v := range t {
+			ret[i] = copyValues(v)
+		}
+		return ret
+	}
+	return v
+}
@@ -0,0 +1,271 @@
+package configer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// ValueSource reads the values in yaml from the remote storage, it's
+// selected by the scheme of the value file, e.g.
+//
+//	-f https://config.example.com/app.yaml
+//	-f etcd://127.0.0.1:2379/config/app.yaml
+//	-f consul://127.0.0.1:8500/config/app.yaml
+//
+// the remote values are merged in the same order as the local files
+type ValueSource interface {
+	Read(ctx context.Context) ([]byte, error)
+}
+
+// WatchableSource is the ValueSource can watch the changes, the sources
+// not implemented it are polled by Watch, see WithPollInterval
+type WatchableSource interface {
+	ValueSource
+	// Watch calls onChange on the change until the ctx is done
+	Watch(ctx context.Context, onChange func())
+}
+
+// SourceFactory returns the ValueSource of the uri
+type SourceFactory func(u *url.URL) (ValueSource, error)
+
+var (
+	sourcesMu sync.RWMutex
+	sources   = map[string]SourceFactory{
+		"http":   newHTTPSource,
+		"https":  newHTTPSource,
+		"etcd":   newEtcdSource,
+		"consul": newConsulSource,
+	}
+)
+
+// RegisterValueSource makes the ValueSource available by the scheme of the value file
+func RegisterValueSource(scheme string, factory SourceFactory) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+
+	sources[scheme] = factory
+}
+
+// getValueSource returns the ValueSource of the value file, nil if it's a local file
+func getValueSource(file string) (ValueSource, error) {
+	i := strings.Index(file, "://")
+	if i < 0 {
+		return nil, nil
+	}
+
+	sourcesMu.RLock()
+	factory, ok := sources[file[:i]]
+	sourcesMu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+
+	u, err := url.Parse(file)
+	if err != nil {
+		return nil, err
+	}
+	return factory(u)
+}
+
+// WithPollInterval sets the interval to poll the value sources which
+// can't be watched, default 30s
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.pollInterval = interval
+	}
+}
+
+// watchSource reloads the configer on the change of the source
+func (p *Configer) watchSource(ctx context.Context, file string, source ValueSource) {
+	reload := func() {
+		if err := p.Reload(); err != nil {
+			klog.ErrorS(err, "config reload", "source", file)
+		}
+	}
+
+	if s, ok := source.(WatchableSource); ok {
+		go s.Watch(ctx, reload)
+		return
+	}
+
+	interval := p.pollInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reload()
+			}
+		}
+	}()
+}
+
+var sourceClient = &http.Client{Timeout: 10 * time.Second}
+
+func httpGet(ctx context.Context, client *http.Client, method, url string, body []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, b, nil
+}
+
+// httpSource reads the values by the http GET
+type httpSource struct {
+	url string
+}
+
+func newHTTPSource(u *url.URL) (ValueSource, error) {
+	return &httpSource{url: u.String()}, nil
+}
+
+func (p *httpSource) Read(ctx context.Context) ([]byte, error) {
+	resp, b, err := httpGet(ctx, sourceClient, "GET", p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s: %s", p.url, resp.Status)
+	}
+	return b, nil
+}
+
+// etcdSource reads the value of the key by the grpc gateway of etcd v3,
+// etcd://host:port/key, it's polled
+type etcdSource struct {
+	endpoint string
+	key      string
+}
+
+func newEtcdSource(u *url.URL) (ValueSource, error) {
+	return &etcdSource{
+		endpoint: "http://" + u.Host + "/v3/kv/range",
+		key:      u.Path,
+	}, nil
+}
+
+func (p *etcdSource) Read(ctx context.Context) ([]byte, error) {
+	body, _ := json.Marshal(map[string]string{
+		"key": base64.StdEncoding.EncodeToString([]byte(p.key)),
+	})
+
+	resp, b, err := httpGet(ctx, sourceClient, "POST", p.endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("etcd range %s: %s", p.key, resp.Status)
+	}
+
+	var ret struct {
+		Kvs []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.Unmarshal(b, &ret); err != nil {
+		return nil, fmt.Errorf("etcd range %s: %s", p.key, err)
+	}
+	if len(ret.Kvs) == 0 {
+		return nil, fmt.Errorf("etcd key %s is not found", p.key)
+	}
+
+	return base64.StdEncoding.DecodeString(ret.Kvs[0].Value)
+}
+
+// consulSource reads the value of the key of the consul kv store,
+// consul://host:port/key, it's watched by the blocking queries
+type consulSource struct {
+	url string
+}
+
+func newConsulSource(u *url.URL) (ValueSource, error) {
+	return &consulSource{
+		url: "http://" + u.Host + "/v1/kv/" + strings.TrimPrefix(u.Path, "/"),
+	}, nil
+}
+
+func (p *consulSource) get(ctx context.Context, client *http.Client, index uint64) ([]byte, uint64, error) {
+	url := p.url + "?raw"
+	if index > 0 {
+		url += fmt.Sprintf("&index=%d&wait=5m", index)
+	}
+
+	resp, b, err := httpGet(ctx, client, "GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("consul get %s: %s", p.url, resp.Status)
+	}
+
+	index, _ = strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return b, index, nil
+}
+
+func (p *consulSource) Read(ctx context.Context) ([]byte, error) {
+	b, _, err := p.get(ctx, sourceClient, 0)
+	return b, err
+}
+
+func (p *consulSource) Watch(ctx context.Context, onChange func()) {
+	// the blocking query waits up to 5 minutes
+	client := &http.Client{}
+
+	var index uint64
+	for {
+		_, next, err := p.get(ctx, client, index)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			klog.ErrorS(err, "consul watch")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		// the first query catches up the change since the last Read
+		if next != index {
+			onChange()
+		}
+		if next < index {
+			// the index is reset, e.g. the key is recreated
+			next = 0
+		}
+		index = next
+	}
+}
@@ -0,0 +1,193 @@
+package configer
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	utilerrors "github.com/yubo/golib/util/errors"
+)
+
+// validate checks the struct read from the path by the `validate` tags
+// of the fields and the Validate() of the structs, e.g.
+//
+//	type Config struct {
+//		Addr    string        `json:"addr" validate:"required,hostport"`
+//		Mode    string        `json:"mode" validate:"oneof=debug release"`
+//		Workers int           `json:"workers" validate:"min=1,max=64"`
+//		Timeout time.Duration `json:"timeout" validate:"min=1s"`
+//		Hook    string        `json:"hook" validate:"url"`
+//	}
+//
+// the rules are separated by ',', all the rules except required skip the
+// zero value. The min and max are the length of the strings, slices and
+// maps. All the errors are aggregated with the config paths of the fields.
+func (p *Options) validate(path string, into interface{}) error {
+	var errs []error
+	p.validateValue(path, reflect.ValueOf(into), &errs)
+	return utilerrors.NewAggregate(errs)
+}
+
+func (p *Options) validateValue(path string, rv reflect.Value, errs *[]error) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			p.validateValue(fmt.Sprintf("%s[%d]", path, i), rv.Index(i), errs)
+		}
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	rt := rv.Type()
+	if rt == reflect.TypeOf(time.Time{}) {
+		return
+	}
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		opt := GetTagOpts(sf, p)
+		if opt.Skip {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if sf.Anonymous {
+			p.validateValue(path, fv, errs)
+			continue
+		}
+
+		name := opt.Json
+		if name == "" {
+			name = sf.Name
+		}
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			for _, rule := range strings.Split(tag, ",") {
+				if err := validateRule(fv, strings.TrimSpace(rule)); err != nil {
+					*errs = append(*errs, fmt.Errorf("%s: %s", fieldPath, err))
+				}
+			}
+		}
+
+		p.validateValue(fieldPath, fv, errs)
+	}
+
+	if rv.CanAddr() {
+		rv = rv.Addr()
+	}
+	if v, ok := rv.Interface().(validator); ok {
+		if err := v.Validate(); err != nil {
+			if path == "" {
+				*errs = append(*errs, err)
+			} else {
+				*errs = append(*errs, fmt.Errorf("%s: %s", path, err))
+			}
+		}
+	}
+}
+
+func validateRule(rv reflect.Value, rule string) error {
+	name, arg := rule, ""
+	if i := strings.IndexByte(rule, '='); i >= 0 {
+		name, arg = rule[:i], rule[i+1:]
+	}
+
+	if name == "required" {
+		if rv.IsZero() {
+			return fmt.Errorf("is required")
+		}
+		return nil
+	}
+
+	if rv.IsZero() {
+		return nil
+	}
+	rv = reflect.Indirect(rv)
+
+	switch name {
+	case "min", "max":
+		return validateRange(rv, name, arg)
+	case "oneof":
+		s := fmt.Sprint(rv.Interface())
+		for _, v := range strings.Fields(arg) {
+			if s == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of [%s]", s, arg)
+	case "url":
+		u, err := url.Parse(rv.String())
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%q is not a valid url", rv.String())
+		}
+	case "hostport":
+		_, port, err := net.SplitHostPort(rv.String())
+		if err != nil {
+			return fmt.Errorf("%q is not a valid host:port", rv.String())
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+			return fmt.Errorf("%q has an invalid port", rv.String())
+		}
+	default:
+		return fmt.Errorf("unknown validate rule %q", rule)
+	}
+	return nil
+}
+
+func validateRange(rv reflect.Value, name, arg string) error {
+	var v, limit float64
+	var what string
+
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		v, what = float64(rv.Len()), "length "
+		limit = cast.ToFloat64(arg)
+	default:
+		if rv.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(arg)
+			if err != nil {
+				return fmt.Errorf("invalid %s duration %q", name, arg)
+			}
+			if (name == "min" && rv.Int() < int64(d)) || (name == "max" && rv.Int() > int64(d)) {
+				return fmt.Errorf("%s is out of the range, %s %s", time.Duration(rv.Int()), name, d)
+			}
+			return nil
+		}
+
+		var err error
+		if v, err = cast.ToFloat64E(rv.Interface()); err != nil {
+			return fmt.Errorf("%s is not applicable to %s", name, rv.Type())
+		}
+		limit = cast.ToFloat64(arg)
+	}
+
+	if name == "min" && v < limit {
+		return fmt.Errorf("%s%v is less than the min %s", what, v, arg)
+	}
+	if name == "max" && v > limit {
+		return fmt.Errorf("%s%v is greater than the max %s", what, v, arg)
+	}
+	return nil
+}
@@ -17,8 +17,10 @@ limitations under the License.
 package configer
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -141,13 +143,67 @@ func istable(v interface{}) bool {
 //	chapter:
 //	  one:
 //	    title: "Loomings"
+//
+// The elements of the lists are indexed by [n], e.g. "servers[0].port".
 func (v Values) PathValue(path string) (interface{}, error) {
 	if path == "" {
 		return nil, errors.New("YAML path cannot be empty")
 	}
+	if strings.Contains(path, "[") {
+		return v.indexPathValue(path)
+	}
 	return v.pathValue(parsePath(path))
 }
 
+// indexPathValue walks the path with the list indexes, e.g. "a.b[0][1].c"
+func (v Values) indexPathValue(path string) (interface{}, error) {
+	var cur interface{} = map[string]interface{}(v)
+
+	for _, key := range parsePath(path) {
+		var indexes []int
+		if i := strings.IndexByte(key, '['); i >= 0 {
+			for rest := key[i:]; rest != ""; {
+				j := strings.IndexByte(rest, ']')
+				if rest[0] != '[' || j < 0 {
+					return nil, fmt.Errorf("invalid path %q", path)
+				}
+				n, err := strconv.Atoi(rest[1:j])
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid index of the path %q", path)
+				}
+				indexes = append(indexes, n)
+				rest = rest[j+1:]
+			}
+			key = key[:i]
+		}
+
+		if key != "" {
+			m, ok := cur.(map[string]interface{})
+			if !ok {
+				if vals, isValues := cur.(Values); isValues {
+					m, ok = vals, true
+				}
+			}
+			if !ok {
+				return nil, ErrNoValue{key}
+			}
+			if cur, ok = m[key]; !ok {
+				return nil, ErrNoValue{key}
+			}
+		}
+
+		for _, n := range indexes {
+			list, ok := cur.([]interface{})
+			if !ok || n >= len(list) {
+				return nil, ErrNoValue{fmt.Sprintf("%s[%d]", key, n)}
+			}
+			cur = list[n]
+		}
+	}
+
+	return cur, nil
+}
+
 func (v Values) pathValue(path []string) (interface{}, error) {
 	if len(path) == 1 {
 		// if exists must be root key not table
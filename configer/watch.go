@@ -0,0 +1,145 @@
+package configer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+)
+
+// OnChange registers the callback of the reload, the changed are the
+// paths of the added, removed or changed values, e.g. "sys.http.port"
+func (p *Configer) OnChange(fn func(changed []string)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.watchers = append(p.watchers, fn)
+}
+
+// Reload re-merges the values of all the sources, and calls the
+// OnChange callbacks if any value is changed. The data is kept if
+// any source fails to be parsed.
+func (p *Configer) Reload() error {
+	p.writeMu.Lock()
+	data, err := p.load()
+	if err != nil {
+		p.writeMu.Unlock()
+		return err
+	}
+	changed := p.swap(data)
+	p.writeMu.Unlock()
+
+	p.notify(changed)
+	return nil
+}
+
+// swap replaces the data and returns the changed paths, the caller
+// holds the writeMu, and calls notify with the paths after releasing it
+func (p *Configer) swap(data map[string]interface{}) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	diff := &Diff{}
+	diffValues("", p.data, data, diff)
+	p.data = data
+	return diff.Paths()
+}
+
+// notify calls the OnChange callbacks with the changed paths
+func (p *Configer) notify(changed []string) {
+	if len(changed) == 0 {
+		return
+	}
+
+	p.mu.RLock()
+	watchers := p.watchers
+	p.mu.RUnlock()
+
+	klog.V(1).InfoS("config reload", "changed", changed)
+	for _, fn := range watchers {
+		fn(changed)
+	}
+}
+
+// Watch watches the value files, and reloads the configer when any of
+// them is changed, until the ctx is done. The directories of the files
+// are watched, so the files replaced by the editors or the configmaps
+// are still watched, the remote ValueSources are watched or polled.
+func (p *Configer) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	files := map[string]bool{}
+	dirs := map[string]bool{}
+	valueDirs := map[string]bool{} // the directories of WithValueFile
+	for _, file := range p.valueFiles {
+		source, err := getValueSource(file)
+		if err != nil {
+			watcher.Close()
+			return err
+		}
+		if source != nil {
+			p.watchSource(ctx, file, source)
+			continue
+		}
+
+		file, err := filepath.Abs(file)
+		if err != nil {
+			watcher.Close()
+			return err
+		}
+		dir := filepath.Dir(file)
+		if info, err := os.Stat(file); err == nil && info.IsDir() {
+			dir = file
+			valueDirs[dir] = true
+		} else {
+			files[file] = true
+		}
+
+		if dirs[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+		dirs[dir] = true
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Clean(event.Name)
+				switch {
+				case files[name] && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0:
+				case valueDirs[filepath.Dir(name)] && isValueFile(name):
+					// the files are also added or removed
+				default:
+					continue
+				}
+				if err := p.Reload(); err != nil {
+					klog.ErrorS(err, "config reload", "file", event.Name)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.ErrorS(err, "config watch")
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,5 @@
+package msgpack
+
+const (
+	MIME_MSGPACK = "application/msgpack" // Accept or Content-Type used in Consumes() and/or Produces()
+)
@@ -0,0 +1,41 @@
+package msgpack
+
+import (
+	restful "github.com/emicklei/go-restful"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+//restful.RegisterEntityAccessor(MIME_MSGPACK, NewEntityAccessor())
+
+// NewEntityAccessor returns a new EntityReaderWriter for accessing MessagePack content.
+// This package is not initialized with such an accessor using the MIME_MSGPACK contentType.
+func NewEntityAccessor() restful.EntityReaderWriter {
+	return entityAccess{}
+}
+
+// entityAccess is a EntityReaderWriter for MessagePack encoding
+type entityAccess struct {
+}
+
+// Read unmarshalls the value from request body and using msgpack to unmarshal
+func (e entityAccess) Read(req *restful.Request, v interface{}) error {
+	dec := msgpack.NewDecoder(req.Request.Body)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// Write marshals the value to byte slice and set the Content-Type Header.
+func (e entityAccess) Write(resp *restful.Response, status int, v interface{}) error {
+	if v == nil {
+		resp.WriteHeader(status)
+		// do not write a nil representation
+		return nil
+	}
+
+	resp.Header().Set(restful.HEADER_ContentType, MIME_MSGPACK)
+	resp.WriteHeader(status)
+
+	enc := msgpack.NewEncoder(resp)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
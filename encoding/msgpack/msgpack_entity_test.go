@@ -0,0 +1,41 @@
+package msgpack
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgpack(t *testing.T) {
+	restful.RegisterEntityAccessor(MIME_MSGPACK, NewEntityAccessor())
+	type Tool struct {
+		Name   string   `json:"name"`
+		Vendor string   `json:"vendor"`
+		Tags   []string `json:"tags"`
+	}
+
+	// Write
+	httpWriter := httptest.NewRecorder()
+	in := &Tool{Name: "msgpack", Vendor: "apple", Tags: []string{"a", "b"}}
+	out := &Tool{}
+	resp := restful.NewResponse(httpWriter)
+	resp.SetRequestAccepts(MIME_MSGPACK)
+
+	err := resp.WriteEntity(in)
+	require.NoError(t, err)
+	require.Equal(t, MIME_MSGPACK, httpWriter.Header().Get(restful.HEADER_ContentType))
+
+	// Read
+	bodyReader := bytes.NewReader(httpWriter.Body.Bytes())
+	httpRequest, _ := http.NewRequest("POST", "/test", bodyReader)
+	httpRequest.Header.Set("Content-Type", MIME_MSGPACK)
+	request := restful.NewRequest(httpRequest)
+	err = request.ReadEntity(out)
+	require.NoError(t, err)
+
+	require.Equal(t, in, out)
+}
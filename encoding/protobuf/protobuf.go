@@ -0,0 +1,5 @@
+package protobuf
+
+const (
+	MIME_PROTOBUF = "application/x-protobuf" // Accept or Content-Type used in Consumes() and/or Produces()
+)
@@ -0,0 +1,60 @@
+package protobuf
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/gogo/protobuf/proto"
+)
+
+//restful.RegisterEntityAccessor(MIME_PROTOBUF, NewEntityAccessor())
+
+// NewEntityAccessor returns a new EntityReaderWriter for accessing protobuf content.
+// This package is not initialized with such an accessor using the MIME_PROTOBUF contentType.
+func NewEntityAccessor() restful.EntityReaderWriter {
+	return entityAccess{}
+}
+
+// entityAccess is a EntityReaderWriter for protobuf encoding
+type entityAccess struct {
+}
+
+// Read unmarshalls the value from request body, v must be a proto.Message
+func (e entityAccess) Read(req *restful.Request, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+
+	b, err := ioutil.ReadAll(req.Request.Body)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(b, msg)
+}
+
+// Write marshals the value to byte slice and set the Content-Type Header.
+func (e entityAccess) Write(resp *restful.Response, status int, v interface{}) error {
+	if v == nil {
+		resp.WriteHeader(status)
+		// do not write a nil representation
+		return nil
+	}
+
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	resp.Header().Set(restful.HEADER_ContentType, MIME_PROTOBUF)
+	resp.WriteHeader(status)
+	_, err = resp.Write(b)
+	return err
+}
@@ -0,0 +1,46 @@
+package protobuf
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+type tool struct {
+	Name   string `protobuf:"bytes,1,opt,name=name"`
+	Vendor string `protobuf:"bytes,2,opt,name=vendor"`
+}
+
+func (m *tool) Reset()         { *m = tool{} }
+func (m *tool) String() string { return proto.CompactTextString(m) }
+func (*tool) ProtoMessage()    {}
+
+func TestProtobuf(t *testing.T) {
+	restful.RegisterEntityAccessor(MIME_PROTOBUF, NewEntityAccessor())
+
+	// Write
+	httpWriter := httptest.NewRecorder()
+	in := &tool{Name: "protobuf", Vendor: "google"}
+	out := &tool{}
+	resp := restful.NewResponse(httpWriter)
+	resp.SetRequestAccepts(MIME_PROTOBUF)
+
+	err := resp.WriteEntity(in)
+	require.NoError(t, err)
+	require.Equal(t, MIME_PROTOBUF, httpWriter.Header().Get(restful.HEADER_ContentType))
+
+	// Read
+	bodyReader := bytes.NewReader(httpWriter.Body.Bytes())
+	httpRequest, _ := http.NewRequest("POST", "/test", bodyReader)
+	httpRequest.Header.Set("Content-Type", MIME_PROTOBUF)
+	request := restful.NewRequest(httpRequest)
+	err = request.ReadEntity(out)
+	require.NoError(t, err)
+
+	require.Equal(t, in, out)
+}
@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"net/url"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/yubo/golib/api/errors"
@@ -77,6 +79,12 @@ func (p *Decoder) Decode(dst interface{}) error {
 func (p *Decoder) decode(rv reflect.Value, rt reflect.Type) error {
 	klog.V(5).Infof("entering decode")
 
+	return p.decodeStruct("", rv, rt)
+}
+
+// decodeStruct decodes the values whose keys start with prefix into struct rv
+// e.g. prefix "a[0]." for `a[0].b=x`
+func (p *Decoder) decodeStruct(prefix string, rv reflect.Value, rt reflect.Type) error {
 	if rv.Kind() != reflect.Struct || rv.Kind() == reflect.Slice || rt.String() == "time.Time" {
 		return errors.NewInternalError(fmt.Errorf("schema: interface must be a pointer to struct"))
 	}
@@ -87,7 +95,6 @@ func (p *Decoder) decode(rv reflect.Value, rt reflect.Type) error {
 		ft := ff.Type
 
 		name, _, skip, inline := getTags(ff)
-
 		if !fv.CanSet() {
 			klog.V(5).Infof("can't addr name %s, continue", name)
 			continue
@@ -100,20 +107,190 @@ func (p *Decoder) decode(rv reflect.Value, rt reflect.Type) error {
 		if inline {
 			// use addr() let fv can set
 			util.PrepareValue(fv, ft)
-			if err := p.decode(fv, ft); err != nil {
+			if fv.Kind() == reflect.Ptr {
+				fv = fv.Elem()
+				ft = fv.Type()
+			}
+			if err := p.decodeStruct(prefix, fv, ft); err != nil {
 				return err
 			}
 			continue
 		}
 
-		if err := util.SetValue(fv, p.values[name]); err != nil {
+		if err := p.decodeField(prefix+name, fv, ft); err != nil {
 			return err
 		}
+	}
+
+	return nil
+}
+
+func (p *Decoder) decodeField(key string, fv reflect.Value, ft reflect.Type) error {
+	t := ft
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && t.String() != "time.Time":
+		// a.b=x
+		if !p.hasPrefix(key + ".") {
+			return nil
+		}
+		util.PrepareValue(fv, ft)
+		if fv.Kind() == reflect.Ptr {
+			fv = fv.Elem()
+		}
+		return p.decodeStruct(key+".", fv, t)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8:
+		return p.decodeSlice(key, fv, ft)
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		return p.decodeMap(key, fv, ft)
+	default:
+		return util.SetValue(fv, p.values[key])
+	}
+}
+
+// decodeSlice support repeated keys `tag=1&tag=2`,
+// indexed keys `tag[0]=1&tag[1]=2` and `a[0].b=x`
+func (p *Decoder) decodeSlice(key string, fv reflect.Value, ft reflect.Type) error {
+	t := ft
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	et := t.Elem()
+	indexes := p.indexes(key)
+
+	if isStruct(et) {
+		if len(indexes) == 0 {
+			return nil
+		}
+
+		util.PrepareValue(fv, ft)
+		if fv.Kind() == reflect.Ptr {
+			fv = fv.Elem()
+		}
+
+		n := indexes[len(indexes)-1] + 1
+		slice := reflect.MakeSlice(t, n, n)
+		for _, i := range indexes {
+			elem := slice.Index(i)
+			util.PrepareValue(elem, et)
+			if elem.Kind() == reflect.Ptr {
+				elem = elem.Elem()
+			}
+			if err := p.decodeStruct(fmt.Sprintf("%s[%d].", key, i), elem, elem.Type()); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+
+	data := p.values[key]
+	for _, i := range indexes {
+		if v, ok := p.values[fmt.Sprintf("%s[%d]", key, i)]; ok && len(v) > 0 {
+			data = append(data, v[0])
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
 
+	util.PrepareValue(fv, ft)
+	if fv.Kind() == reflect.Ptr {
+		fv = fv.Elem()
 	}
+
+	slice := reflect.MakeSlice(t, len(data), len(data))
+	for i, v := range data {
+		if err := util.SetValue(slice.Index(i), []string{v}); err != nil {
+			return err
+		}
+	}
+	fv.Set(slice)
+
 	return nil
 }
 
+// decodeMap support `m[key]=value`
+func (p *Decoder) decodeMap(key string, fv reflect.Value, ft reflect.Type) error {
+	t := ft
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	for k, v := range p.values {
+		if !strings.HasPrefix(k, key+"[") || !strings.HasSuffix(k, "]") {
+			continue
+		}
+		mapKey := k[len(key)+1 : len(k)-1]
+		if mapKey == "" || strings.ContainsAny(mapKey, "[]") {
+			continue
+		}
+
+		util.PrepareValue(fv, ft)
+		mv := fv
+		if mv.Kind() == reflect.Ptr {
+			mv = mv.Elem()
+		}
+		if mv.IsNil() {
+			mv.Set(reflect.MakeMap(t))
+		}
+
+		elem := reflect.New(t.Elem()).Elem()
+		if err := util.SetValue(elem, v); err != nil {
+			return err
+		}
+		mv.SetMapIndex(reflect.ValueOf(mapKey).Convert(t.Key()), elem)
+	}
+
+	return nil
+}
+
+func (p *Decoder) hasPrefix(prefix string) bool {
+	for k := range p.values {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexes returns the sorted indexes of the keys like `key[N]` or `key[N].xxx`
+func (p *Decoder) indexes(key string) []int {
+	set := map[int]bool{}
+	for k := range p.values {
+		if !strings.HasPrefix(k, key+"[") {
+			continue
+		}
+		rest := k[len(key)+1:]
+		n := strings.Index(rest, "]")
+		if n <= 0 {
+			continue
+		}
+		i, err := strconv.Atoi(rest[:n])
+		if err != nil || i < 0 || i > maxSliceIndex {
+			continue
+		}
+		set[i] = true
+	}
+
+	ret := make([]int, 0, len(set))
+	for i := range set {
+		ret = append(ret, i)
+	}
+	sort.Ints(ret)
+	return ret
+}
+
+func isStruct(rt reflect.Type) bool {
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt.Kind() == reflect.Struct && rt.String() != "time.Time"
+}
+
 // `name:"name?(,inline|{format})?"`
 func getTags(rf reflect.StructField) (name, format string, skip, inline bool) {
 	tag, _ := rf.Tag.Lookup("name")
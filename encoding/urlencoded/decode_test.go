@@ -0,0 +1,44 @@
+package urlencoded
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeNested(t *testing.T) {
+	type Item struct {
+		Name  string
+		Count int
+	}
+	type Addr struct {
+		City string
+	}
+	type Input struct {
+		Tags   []string
+		Ids    []int64
+		Items  []Item
+		Addr   *Addr
+		Labels map[string]string
+	}
+
+	cases := []struct {
+		data string
+		want Input
+	}{
+		{"tags=a&tags=b", Input{Tags: []string{"a", "b"}}},
+		{"ids[0]=1&ids[1]=2", Input{Ids: []int64{1, 2}}},
+		{"ids=3&ids=4", Input{Ids: []int64{3, 4}}},
+		{"items[0].name=x&items[1].name=y&items[1].count=2",
+			Input{Items: []Item{{Name: "x"}, {Name: "y", Count: 2}}}},
+		{"addr.city=beijing", Input{Addr: &Addr{City: "beijing"}}},
+		{"labels[a]=1&labels[b]=2", Input{Labels: map[string]string{"a": "1", "b": "2"}}},
+	}
+
+	for _, c := range cases {
+		got := Input{}
+		err := Unmarshal([]byte(c.data), &got)
+		require.NoError(t, err, c.data)
+		require.Equal(t, c.want, got, c.data)
+	}
+}
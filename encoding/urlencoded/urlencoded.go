@@ -2,5 +2,6 @@ package urlencoded
 
 const (
 	maxFormSize      = int64(1<<63 - 1)
+	maxSliceIndex    = 1000                                // the max index of `a[N]`
 	MIME_URL_ENCODED = "application/x-www-form-urlencoded" // Accept or Content-Type used in Consumes() and/or Produces()
 )
@@ -21,6 +21,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/yubo/golib/util/validation"
 	"github.com/yubo/golib/util/validation/field"
 )
 
@@ -159,14 +160,28 @@ func ConvertSelectorToLabelsMap(selector string, opts ...field.PathOption) (Set,
 			return labelsMap, fmt.Errorf("invalid selector: %s", l)
 		}
 		key := strings.TrimSpace(l[0])
-		//if err := validateLabelKey(key, field.ToPath(opts...)); err != nil {
-		//	return labelsMap, err
-		//}
+		if err := validateLabelKey(key, field.ToPath(opts...)); err != nil {
+			return labelsMap, err
+		}
 		value := strings.TrimSpace(l[1])
-		//if err := validateLabelValue(key, value, field.ToPath(opts...)); err != nil {
-		//	return labelsMap, err
-		//}
+		if err := validateLabelValue(key, value, field.ToPath(opts...)); err != nil {
+			return labelsMap, err
+		}
 		labelsMap[key] = value
 	}
 	return labelsMap, nil
 }
+
+func validateLabelKey(k string, path *field.Path) *field.Error {
+	if errs := validation.IsQualifiedName(k); len(errs) != 0 {
+		return field.Invalid(path, k, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func validateLabelValue(k, v string, path *field.Path) *field.Error {
+	if errs := validation.IsValidLabelValue(v); len(errs) != 0 {
+		return field.Invalid(path.Key(k), v, strings.Join(errs, "; "))
+	}
+	return nil
+}
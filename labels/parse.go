@@ -0,0 +1,397 @@
+package labels
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yubo/golib/selection"
+)
+
+// likeEscape is the escape character of the like patterns,
+// the backslash is not used because it needs to be escaped in mysql
+const likeEscape = '!'
+
+// operatorTokens are the tokens of the binary operators in the selector
+var operatorTokens = map[selection.Operator]string{
+	selection.Equals:              "=",
+	selection.DoubleEquals:        "==",
+	selection.NotEquals:           "!=",
+	selection.GreaterThan:         ">",
+	selection.GreaterThanOrEquals: ">=",
+	selection.LessThan:            "<",
+	selection.LessThanOrEquals:    "<=",
+	selection.HasPrefix:           "^=",
+	selection.HasSuffix:           "$=",
+	selection.Contains:            "*=",
+	selection.Like:                "~",
+	selection.NotLike:             "!~",
+}
+
+// binaryOperators is ordered by the token length, the longest match wins
+var binaryOperators = []selection.Operator{
+	selection.DoubleEquals,
+	selection.NotEquals,
+	selection.GreaterThanOrEquals,
+	selection.LessThanOrEquals,
+	selection.HasPrefix,
+	selection.HasSuffix,
+	selection.Contains,
+	selection.NotLike,
+	selection.Equals,
+	selection.GreaterThan,
+	selection.LessThan,
+	selection.Like,
+}
+
+// Parse takes a string representing a selector and returns a selector
+// object, or an error. The requirements separated by ',' are ANDed,
+// the groups separated by ';' are ORed, and the parentheses group them,
+// they are compiled into the parameterized sql, e.g.
+//
+//	x=a,y!=b           x = ? and y != ?
+//	x in (a,b)         x in (?, ?)
+//	x notin (a,b)      x not in (?, ?)
+//	x, !y              x is not null and y is null
+//	x>1,y<=2           x > ? and y <= ?
+//	x^=a,y$=b,z*=c     x like 'a%' and y like '%b' and z like '%c%'
+//	x~a%b,y!~_c        x like 'a%b' and y not like '_c', the wildcards are kept
+//	x=a,y=b;z=c        ((x = ? and y = ?) or (z = ?))
+//	x=a,(y=b;z=c)      x = ? and ((y = ?) or (z = ?))
+func Parse(selector string) (Selector, error) {
+	if strings.TrimSpace(selector) == "" {
+		return internalSelector(nil), nil
+	}
+
+	p := &parser{s: selector}
+	groups, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at %d in %q", p.s[p.pos], p.pos, p.s)
+	}
+
+	if len(groups) == 1 {
+		return internalSelector(groups[0]), nil
+	}
+	return internalSelector{*newOrRequirement(groups)}, nil
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// peek returns the next non-space character, or 0 at the end
+func (p *parser) peek() byte {
+	if p.skipSpace(); p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+// parseOr parses the groups separated by ';'
+func (p *parser) parseOr() ([]Requirements, error) {
+	groups := []Requirements{}
+	for {
+		g, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+
+		if p.peek() != ';' {
+			return groups, nil
+		}
+		p.pos++
+	}
+}
+
+// parseAnd parses the requirements or the parenthesized groups separated by ','
+func (p *parser) parseAnd() (Requirements, error) {
+	reqs := Requirements{}
+	for {
+		if p.peek() == '(' {
+			p.pos++
+			groups, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if p.peek() != ')' {
+				return nil, fmt.Errorf("unclosed '(' in %q", p.s)
+			}
+			p.pos++
+
+			if len(groups) == 1 {
+				reqs = append(reqs, groups[0]...)
+			} else {
+				reqs = append(reqs, *newOrRequirement(groups))
+			}
+		} else {
+			r, err := parseRequirement(p.nextTerm())
+			if err != nil {
+				return nil, err
+			}
+			reqs = append(reqs, *r)
+		}
+
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+
+	// sort to have deterministic string representation
+	sort.SliceStable(reqs, func(i, j int) bool { return reqs[i].key < reqs[j].key })
+
+	return reqs, nil
+}
+
+// nextTerm reads a requirement until the ',', ';' or ')' out of the value list
+func (p *parser) nextTerm() string {
+	start := p.pos
+	depth := 0
+	for ; p.pos < len(p.s); p.pos++ {
+		switch p.s[p.pos] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return p.s[start:p.pos]
+			}
+			depth--
+		case ',', ';':
+			if depth == 0 {
+				return p.s[start:p.pos]
+			}
+		}
+	}
+	return p.s[start:]
+}
+
+// newOrRequirement returns a requirement ORs the groups
+func newOrRequirement(groups []Requirements) *Requirement {
+	r := &Requirement{operator: selection.Or, groups: groups}
+
+	queries := make([]string, 0, len(groups))
+	for _, g := range groups {
+		query, args := g.SQL()
+		queries = append(queries, "("+query+")")
+		r.args = append(r.args, args...)
+	}
+	r.query = "(" + strings.Join(queries, " or ") + ")"
+
+	return r
+}
+
+func parseRequirement(term string) (*Requirement, error) {
+	term = strings.TrimSpace(term)
+
+	if strings.HasPrefix(term, "!") {
+		key := strings.TrimSpace(term[1:])
+		if err := validateKey(key); err != nil {
+			return nil, err
+		}
+		return newRequirement(key, selection.DoesNotExist, nil)
+	}
+
+	i := 0
+	for i < len(term) && isKeyChar(term[i]) {
+		i++
+	}
+	key, rest := term[:i], strings.TrimSpace(term[i:])
+	if err := validateKey(key); err != nil {
+		return nil, fmt.Errorf("invalid requirement %q: %s", term, err)
+	}
+
+	if rest == "" {
+		return newRequirement(key, selection.Exists, nil)
+	}
+
+	for _, op := range []selection.Operator{selection.NotIn, selection.In} {
+		if !strings.HasPrefix(rest, string(op)) {
+			continue
+		}
+		list := strings.TrimSpace(rest[len(op):])
+		if !strings.HasPrefix(list, "(") || !strings.HasSuffix(list, ")") {
+			return nil, fmt.Errorf("invalid requirement %q: expected '(' values ')' after %s", term, op)
+		}
+		values := []string{}
+		for _, v := range strings.Split(list[1:len(list)-1], ",") {
+			values = append(values, strings.TrimSpace(v))
+		}
+		return newRequirement(key, op, values)
+	}
+
+	for _, op := range binaryOperators {
+		token := operatorTokens[op]
+		if strings.HasPrefix(rest, token) {
+			return newRequirement(key, op, []string{strings.TrimSpace(rest[len(token):])})
+		}
+	}
+
+	return nil, fmt.Errorf("invalid requirement %q: unknown operator", term)
+}
+
+// newRequirement validates the values and compiles the requirement into the sql form
+func newRequirement(key string, op selection.Operator, values []string) (*Requirement, error) {
+	for _, v := range values {
+		if err := validateValue(v); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s", key, err)
+		}
+	}
+
+	r := &Requirement{key: key, operator: op, values: values}
+
+	switch op {
+	case selection.Exists:
+		r.query = key + " is not null"
+	case selection.DoesNotExist:
+		r.query = key + " is null"
+	case selection.Equals, selection.DoubleEquals:
+		r.query = key + " = ?"
+		r.args = []interface{}{values[0]}
+	case selection.NotEquals:
+		r.query = key + " != ?"
+		r.args = []interface{}{values[0]}
+	case selection.In, selection.NotIn:
+		if len(values) == 0 || (len(values) == 1 && values[0] == "") {
+			return nil, fmt.Errorf("for '%s' operator on %s, values set can't be empty", op, key)
+		}
+		sqlOp := "in"
+		if op == selection.NotIn {
+			sqlOp = "not in"
+		}
+		r.query = fmt.Sprintf("%s %s (%s)", key, sqlOp, strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", "))
+		for _, v := range values {
+			r.args = append(r.args, v)
+		}
+	case selection.GreaterThan, selection.GreaterThanOrEquals, selection.LessThan, selection.LessThanOrEquals:
+		n, err := parseNumber(values[0])
+		if err != nil {
+			return nil, fmt.Errorf("for '%s' operator on %s, the value must be a number: %s", operatorTokens[op], key, err)
+		}
+		sqlOp := strings.TrimSpace(operatorTokens[op])
+		r.query = fmt.Sprintf("%s %s ?", key, sqlOp)
+		r.args = []interface{}{n}
+	case selection.HasPrefix, selection.HasSuffix, selection.Contains:
+		if values[0] == "" {
+			return nil, fmt.Errorf("for '%s' operator on %s, the value can't be empty", operatorTokens[op], key)
+		}
+		pattern := escapeLike(values[0])
+		switch op {
+		case selection.HasPrefix:
+			pattern = pattern + "%"
+		case selection.HasSuffix:
+			pattern = "%" + pattern
+		default:
+			pattern = "%" + pattern + "%"
+		}
+		r.query = fmt.Sprintf("%s like ? escape '%c'", key, likeEscape)
+		r.args = []interface{}{pattern}
+	case selection.Like, selection.NotLike:
+		if values[0] == "" {
+			return nil, fmt.Errorf("for '%s' operator on %s, the value can't be empty", operatorTokens[op], key)
+		}
+		sqlOp := "like"
+		if op == selection.NotLike {
+			sqlOp = "not like"
+		}
+		r.query = fmt.Sprintf("%s %s ?", key, sqlOp)
+		r.args = []interface{}{values[0]}
+	default:
+		return nil, fmt.Errorf("unsupported operator %s", op)
+	}
+
+	return r, nil
+}
+
+func parseNumber(s string) (interface{}, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func escapeLike(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if c == '%' || c == '_' || c == likeEscape {
+			b.WriteRune(likeEscape)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func isKeyChar(c byte) bool {
+	return c == '_' || c == '.' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// validateKey make sure the key is a valid sql identifier, e.g. name, user.name
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("key can't be empty")
+	}
+	if key[0] >= '0' && key[0] <= '9' {
+		return fmt.Errorf("key %q must start with a letter or '_'", key)
+	}
+	for i := 0; i < len(key); i++ {
+		if !isKeyChar(key[i]) {
+			return fmt.Errorf("invalid character %q in key %q", key[i], key)
+		}
+	}
+	return nil
+}
+
+func validateValue(v string) error {
+	if i := strings.IndexAny(v, "=!<>|&()^$*,;"); i >= 0 {
+		return fmt.Errorf("invalid character %q in value %q", v[i], v)
+	}
+	return nil
+}
+
+// Query returns the sql form of the requirement, e.g. "name = ?"
+func (r *Requirement) Query() string {
+	return r.query
+}
+
+// Args returns the args of the sql form
+func (r *Requirement) Args() []interface{} {
+	return r.args
+}
+
+// Key returns requirement key, empty if the requirement is created by NewRequirement
+func (r *Requirement) Key() string {
+	return r.key
+}
+
+// Operator returns requirement operator
+func (r *Requirement) Operator() selection.Operator {
+	return r.operator
+}
+
+// Values returns requirement values
+func (r *Requirement) Values() []string {
+	return r.values
+}
+
+// SQL returns the where clause of the requirements, the requirements are ANDed,
+// e.g. "x = ? and y in (?, ?)", the query is empty if there is no requirement
+func (p Requirements) SQL() (query string, args []interface{}) {
+	queries := make([]string, 0, len(p))
+	for i := range p {
+		queries = append(queries, p[i].query)
+		args = append(args, p[i].args...)
+	}
+	return strings.Join(queries, " and "), args
+}
@@ -0,0 +1,98 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSQL(t *testing.T) {
+	cases := []struct {
+		selector string
+		str      string
+		query    string
+		args     []interface{}
+	}{
+		{"", "", "", nil},
+		{"x=a,y!=b", "x=a,y!=b", "x = ? and y != ?", []interface{}{"a", "b"}},
+		{"y==b, x=a", "x=a,y==b", "x = ? and y = ?", []interface{}{"a", "b"}},
+		{"x in (a, b),y notin (c)", "x in (a,b),y notin (c)", "x in (?, ?) and y not in (?)", []interface{}{"a", "b", "c"}},
+		{"x,!y", "x,!y", "x is not null and y is null", nil},
+		{"x>1,y<=2.5", "x>1,y<=2.5", "x > ? and y <= ?", []interface{}{int64(1), 2.5}},
+		{"a^=foo,b$=bar,c*=50%", "a^=foo,b$=bar,c*=50%", "a like ? escape '!' and b like ? escape '!' and c like ? escape '!'",
+			[]interface{}{"foo%", "%bar", "%50!%%"}},
+		{"user.name=tom", "user.name=tom", "user.name = ?", []interface{}{"tom"}},
+		{"a~foo%,b!~_ar", "a~foo%,b!~_ar", "a like ? and b not like ?", []interface{}{"foo%", "_ar"}},
+	}
+
+	for _, c := range cases {
+		s, err := Parse(c.selector)
+		require.NoError(t, err, c.selector)
+		require.Equal(t, c.str, s.String(), c.selector)
+
+		reqs, _ := s.Requirements()
+		query, args := reqs.SQL()
+		require.Equal(t, c.query, query, c.selector)
+		require.Equal(t, c.args, args, c.selector)
+	}
+}
+
+func TestParseSQLError(t *testing.T) {
+	cases := []string{
+		"x=a||y=b",
+		"x==a==b",
+		"!x=a",
+		"x<a",
+		"x in ()",
+		"x in (a",
+		"x in a",
+		"x^=",
+		"x~",
+		"x;drop table t=1",
+		"1x=a",
+		"x=a)",
+	}
+
+	for _, c := range cases {
+		_, err := Parse(c)
+		require.Error(t, err, c)
+	}
+}
+
+func TestParseGroups(t *testing.T) {
+	cases := []struct {
+		selector string
+		str      string
+		query    string
+		args     []interface{}
+	}{
+		{"status=active,role=admin;status=pending", "role=admin,status=active;status=pending",
+			"((role = ? and status = ?) or (status = ?))", []interface{}{"admin", "active", "pending"}},
+		{"(status=active,role=admin);(status=pending)", "role=admin,status=active;status=pending",
+			"((role = ? and status = ?) or (status = ?))", []interface{}{"admin", "active", "pending"}},
+		{"x=a,(y in (b,c);z=d)", "(y in (b,c);z=d),x=a",
+			"((y in (?, ?)) or (z = ?)) and x = ?", []interface{}{"b", "c", "d", "a"}},
+		{"(x=a)", "x=a", "x = ?", []interface{}{"a"}},
+	}
+
+	for _, c := range cases {
+		s, err := Parse(c.selector)
+		require.NoError(t, err, c.selector)
+		require.Equal(t, c.str, s.String(), c.selector)
+
+		reqs, _ := s.Requirements()
+		query, args := reqs.SQL()
+		require.Equal(t, c.query, query, c.selector)
+		require.Equal(t, c.args, args, c.selector)
+
+		// the string representation can be parsed again
+		s2, err := Parse(s.String())
+		require.NoError(t, err, c.selector)
+		require.Equal(t, s.String(), s2.String(), c.selector)
+	}
+
+	for _, c := range []string{"(x=a", "x=a)", "x=a;", "(x=a;y=b", "x=a,()"} {
+		_, err := Parse(c)
+		require.Error(t, err, c)
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"bytes"
 	"fmt"
 	"strings"
+
+	"github.com/yubo/golib/selection"
 )
 
 // Requirements is AND of all requirements.
@@ -100,6 +102,13 @@ func (s internalSelector) DeepCopySelector() Selector {
 }
 
 type Requirement struct {
+	// the selector form, empty if the requirement is created by NewRequirement
+	key      string
+	operator selection.Operator
+	values   []string
+	groups   []Requirements // the ORed groups of the selection.Or requirement
+
+	// the sql form, e.g. "key in (?, ?)" with the args
 	query string
 	// In huge majority of cases we have at most one value here.
 	// It is generally faster to operate on a single-element slice
@@ -110,6 +119,18 @@ type Requirement struct {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Requirement) DeepCopyInto(out *Requirement) {
 	*out = *in
+	if in.values != nil {
+		in, out := &in.values, &out.values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.groups != nil {
+		in, out := &in.groups, &out.groups
+		*out = make([]Requirements, len(*in))
+		for i := range *in {
+			(*out)[i] = Requirements(internalSelector((*in)[i]).DeepCopy())
+		}
+	}
 	if in.args != nil {
 		in, out := &in.args, &out.args
 		*out = make([]interface{}, len(*in))
@@ -200,6 +221,28 @@ func (s internalSelector) Empty() bool {
 func (r *Requirement) String() string {
 	buf := &bytes.Buffer{}
 
+	if r.operator == selection.Or {
+		groups := make([]string, 0, len(r.groups))
+		for _, g := range r.groups {
+			groups = append(groups, internalSelector(g).String())
+		}
+		return "(" + strings.Join(groups, ";") + ")"
+	}
+
+	if r.key != "" {
+		switch r.operator {
+		case selection.DoesNotExist:
+			buf.WriteString("!" + r.key)
+		case selection.Exists:
+			buf.WriteString(r.key)
+		case selection.In, selection.NotIn:
+			fmt.Fprintf(buf, "%s %s (%s)", r.key, r.operator, strings.Join(r.values, ","))
+		default:
+			buf.WriteString(r.key + operatorTokens[r.operator] + strings.Join(r.values, ""))
+		}
+		return buf.String()
+	}
+
 	fmt.Fprint(buf, r.query)
 	fmt.Fprint(buf, r.args...)
 
@@ -235,6 +278,11 @@ func (s internalSelector) Requirements() (Requirements, bool) { return Requireme
 // String returns a comma-separated string of all
 // the internalSelector Requirements' human-readable strings.
 func (s internalSelector) String() string {
+	if len(s) == 1 && s[0].operator == selection.Or {
+		str := s[0].String()
+		return str[1 : len(str)-1]
+	}
+
 	var reqs []string
 	for ix := range s {
 		reqs = append(reqs, s[ix].String())
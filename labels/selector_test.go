@@ -17,7 +17,6 @@ limitations under the License.
 package labels
 
 import (
-	"reflect"
 	"strings"
 	"testing"
 
@@ -76,97 +75,12 @@ func TestDeterministicParse(t *testing.T) {
 	}
 }
 
-func expectMatch(t *testing.T, selector string, ls Set) {
-	lq, err := Parse(selector)
-	if err != nil {
-		t.Errorf("Unable to parse %v as a selector\n", selector)
-		return
-	}
-	if !lq.Matches(ls) {
-		t.Errorf("Wanted %s to match '%s', but it did not.\n", selector, ls)
-	}
-}
-
-func expectNoMatch(t *testing.T, selector string, ls Set) {
-	lq, err := Parse(selector)
-	if err != nil {
-		t.Errorf("Unable to parse %v as a selector\n", selector)
-		return
-	}
-	if lq.Matches(ls) {
-		t.Errorf("Wanted '%s' to not match '%s', but it did.", selector, ls)
-	}
-}
-
 func TestEverything(t *testing.T) {
-	if !Everything().Matches(Set{"x": "y"}) {
-		t.Errorf("Nil selector didn't match")
-	}
 	if !Everything().Empty() {
 		t.Errorf("Everything was not empty")
 	}
 }
 
-func TestSelectorMatches(t *testing.T) {
-	expectMatch(t, "", Set{"x": "y"})
-	expectMatch(t, "x=y", Set{"x": "y"})
-	expectMatch(t, "x=y,z=w", Set{"x": "y", "z": "w"})
-	expectMatch(t, "x!=y,z!=w", Set{"x": "z", "z": "a"})
-	expectMatch(t, "notin=in", Set{"notin": "in"}) // in and notin in exactMatch
-	expectMatch(t, "x", Set{"x": "z"})
-	expectMatch(t, "!x", Set{"y": "z"})
-	expectMatch(t, "x>1", Set{"x": "2"})
-	expectMatch(t, "x<1", Set{"x": "0"})
-	expectNoMatch(t, "x=z", Set{})
-	expectNoMatch(t, "x=y", Set{"x": "z"})
-	expectNoMatch(t, "x=y,z=w", Set{"x": "w", "z": "w"})
-	expectNoMatch(t, "x!=y,z!=w", Set{"x": "z", "z": "w"})
-	expectNoMatch(t, "x", Set{"y": "z"})
-	expectNoMatch(t, "!x", Set{"x": "z"})
-	expectNoMatch(t, "x>1", Set{"x": "0"})
-	expectNoMatch(t, "x<1", Set{"x": "2"})
-
-	labelset := Set{
-		"foo": "bar",
-		"baz": "blah",
-	}
-	expectMatch(t, "foo=bar", labelset)
-	expectMatch(t, "baz=blah", labelset)
-	expectMatch(t, "foo=bar,baz=blah", labelset)
-	expectNoMatch(t, "foo=blah", labelset)
-	expectNoMatch(t, "baz=bar", labelset)
-	expectNoMatch(t, "foo=bar,foobar=bar,baz=blah", labelset)
-}
-
-func expectMatchDirect(t *testing.T, selector, ls Set) {
-	if !SelectorFromSet(selector).Matches(ls) {
-		t.Errorf("Wanted %s to match '%s', but it did not.\n", selector, ls)
-	}
-}
-
-//lint:ignore U1000 currently commented out in TODO of TestSetMatches
-func expectNoMatchDirect(t *testing.T, selector, ls Set) {
-	if SelectorFromSet(selector).Matches(ls) {
-		t.Errorf("Wanted '%s' to not match '%s', but it did.", selector, ls)
-	}
-}
-
-func TestSetMatches(t *testing.T) {
-	labelset := Set{
-		"foo": "bar",
-		"baz": "blah",
-	}
-	expectMatchDirect(t, Set{}, labelset)
-	expectMatchDirect(t, Set{"foo": "bar"}, labelset)
-	expectMatchDirect(t, Set{"baz": "blah"}, labelset)
-	expectMatchDirect(t, Set{"foo": "bar", "baz": "blah"}, labelset)
-
-	//TODO: bad values not handled for the moment in SelectorFromSet
-	//expectNoMatchDirect(t, Set{"foo": "=blah"}, labelset)
-	//expectNoMatchDirect(t, Set{"baz": "=bar"}, labelset)
-	//expectNoMatchDirect(t, Set{"foo": "=bar", "foobar": "bar", "baz": "blah"}, labelset)
-}
-
 func TestNilMapIsValid(t *testing.T) {
 	selector := Set(nil).AsSelector()
 	if selector == nil {
@@ -186,165 +100,6 @@ func TestSetIsEmpty(t *testing.T) {
 	}
 }
 
-func TestLexer(t *testing.T) {
-	testcases := []struct {
-		s string
-		t Token
-	}{
-		{"", EndOfStringToken},
-		{",", CommaToken},
-		{"notin", NotInToken},
-		{"in", InToken},
-		{"=", EqualsToken},
-		{"==", DoubleEqualsToken},
-		{">", GreaterThanToken},
-		{"<", LessThanToken},
-		//Note that Lex returns the longest valid token found
-		{"!", DoesNotExistToken},
-		{"!=", NotEqualsToken},
-		{"(", OpenParToken},
-		{")", ClosedParToken},
-		//Non-"special" characters are considered part of an identifier
-		{"~", IdentifierToken},
-		{"||", IdentifierToken},
-	}
-	for _, v := range testcases {
-		l := &Lexer{s: v.s, pos: 0}
-		token, lit := l.Lex()
-		if token != v.t {
-			t.Errorf("Got %d it should be %d for '%s'", token, v.t, v.s)
-		}
-		if v.t != ErrorToken && lit != v.s {
-			t.Errorf("Got '%s' it should be '%s'", lit, v.s)
-		}
-	}
-}
-
-func min(l, r int) (m int) {
-	m = r
-	if l < r {
-		m = l
-	}
-	return m
-}
-
-func TestLexerSequence(t *testing.T) {
-	testcases := []struct {
-		s string
-		t []Token
-	}{
-		{"key in ( value )", []Token{IdentifierToken, InToken, OpenParToken, IdentifierToken, ClosedParToken}},
-		{"key notin ( value )", []Token{IdentifierToken, NotInToken, OpenParToken, IdentifierToken, ClosedParToken}},
-		{"key in ( value1, value2 )", []Token{IdentifierToken, InToken, OpenParToken, IdentifierToken, CommaToken, IdentifierToken, ClosedParToken}},
-		{"key", []Token{IdentifierToken}},
-		{"!key", []Token{DoesNotExistToken, IdentifierToken}},
-		{"()", []Token{OpenParToken, ClosedParToken}},
-		{"x in (),y", []Token{IdentifierToken, InToken, OpenParToken, ClosedParToken, CommaToken, IdentifierToken}},
-		{"== != (), = notin", []Token{DoubleEqualsToken, NotEqualsToken, OpenParToken, ClosedParToken, CommaToken, EqualsToken, NotInToken}},
-		{"key>2", []Token{IdentifierToken, GreaterThanToken, IdentifierToken}},
-		{"key<1", []Token{IdentifierToken, LessThanToken, IdentifierToken}},
-	}
-	for _, v := range testcases {
-		var tokens []Token
-		l := &Lexer{s: v.s, pos: 0}
-		for {
-			token, _ := l.Lex()
-			if token == EndOfStringToken {
-				break
-			}
-			tokens = append(tokens, token)
-		}
-		if len(tokens) != len(v.t) {
-			t.Errorf("Bad number of tokens for '%s %d, %d", v.s, len(tokens), len(v.t))
-		}
-		for i := 0; i < min(len(tokens), len(v.t)); i++ {
-			if tokens[i] != v.t[i] {
-				t.Errorf("Test '%s': Mismatching in token type found '%v' it should be '%v'", v.s, tokens[i], v.t[i])
-			}
-		}
-	}
-}
-func TestParserLookahead(t *testing.T) {
-	testcases := []struct {
-		s string
-		t []Token
-	}{
-		{"key in ( value )", []Token{IdentifierToken, InToken, OpenParToken, IdentifierToken, ClosedParToken, EndOfStringToken}},
-		{"key notin ( value )", []Token{IdentifierToken, NotInToken, OpenParToken, IdentifierToken, ClosedParToken, EndOfStringToken}},
-		{"key in ( value1, value2 )", []Token{IdentifierToken, InToken, OpenParToken, IdentifierToken, CommaToken, IdentifierToken, ClosedParToken, EndOfStringToken}},
-		{"key", []Token{IdentifierToken, EndOfStringToken}},
-		{"!key", []Token{DoesNotExistToken, IdentifierToken, EndOfStringToken}},
-		{"()", []Token{OpenParToken, ClosedParToken, EndOfStringToken}},
-		{"", []Token{EndOfStringToken}},
-		{"x in (),y", []Token{IdentifierToken, InToken, OpenParToken, ClosedParToken, CommaToken, IdentifierToken, EndOfStringToken}},
-		{"== != (), = notin", []Token{DoubleEqualsToken, NotEqualsToken, OpenParToken, ClosedParToken, CommaToken, EqualsToken, NotInToken, EndOfStringToken}},
-		{"key>2", []Token{IdentifierToken, GreaterThanToken, IdentifierToken, EndOfStringToken}},
-		{"key<1", []Token{IdentifierToken, LessThanToken, IdentifierToken, EndOfStringToken}},
-	}
-	for _, v := range testcases {
-		p := &Parser{l: &Lexer{s: v.s, pos: 0}, position: 0}
-		p.scan()
-		if len(p.scannedItems) != len(v.t) {
-			t.Errorf("Expected %d items found %d", len(v.t), len(p.scannedItems))
-		}
-		for {
-			token, lit := p.lookahead(KeyAndOperator)
-
-			token2, lit2 := p.consume(KeyAndOperator)
-			if token == EndOfStringToken {
-				break
-			}
-			if token != token2 || lit != lit2 {
-				t.Errorf("Bad values")
-			}
-		}
-	}
-}
-
-func TestSafeSort(t *testing.T) {
-	tests := []struct {
-		name   string
-		in     []string
-		inCopy []string
-		want   []string
-	}{
-		{
-			name:   "nil strings",
-			in:     nil,
-			inCopy: nil,
-			want:   nil,
-		},
-		{
-			name:   "ordered strings",
-			in:     []string{"bar", "foo"},
-			inCopy: []string{"bar", "foo"},
-			want:   []string{"bar", "foo"},
-		},
-		{
-			name:   "unordered strings",
-			in:     []string{"foo", "bar"},
-			inCopy: []string{"foo", "bar"},
-			want:   []string{"bar", "foo"},
-		},
-		{
-			name:   "duplicated strings",
-			in:     []string{"foo", "bar", "foo", "bar"},
-			inCopy: []string{"foo", "bar", "foo", "bar"},
-			want:   []string{"bar", "bar", "foo", "foo"},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := safeSort(tt.in); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("safeSort() = %v, want %v", got, tt.want)
-			}
-			if !reflect.DeepEqual(tt.in, tt.inCopy) {
-				t.Errorf("after safeSort(), input = %v, want %v", tt.in, tt.inCopy)
-			}
-		})
-	}
-}
-
 func BenchmarkSelectorFromValidatedSet(b *testing.B) {
 	set := map[string]string{
 		"foo": "foo",
@@ -0,0 +1,83 @@
+package logs
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Level is the runtime log level settings
+type Level struct {
+	V       *string `json:"v,omitempty"`
+	VModule *string `json:"vmodule,omitempty"`
+}
+
+var levelMu sync.Mutex
+
+// GetLevel returns the current verbosity and vmodule
+func GetLevel() Level {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+
+	v := flagValue("v")
+	vmodule := flagValue("vmodule")
+	return Level{V: &v, VModule: &vmodule}
+}
+
+// SetLevel changes the verbosity and vmodule at runtime,
+// the nil fields are left unchanged.
+// It lasts until the process restarts or the options is applied again.
+func SetLevel(level Level) error {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+
+	if level.V != nil {
+		if _, err := GlogSetter(*level.V); err != nil {
+			return err
+		}
+	}
+	if level.VModule != nil {
+		if err := flag.Set("vmodule", *level.VModule); err != nil {
+			return fmt.Errorf("failed set klog.logging.vmodule %s: %v", *level.VModule, err)
+		}
+	}
+	return nil
+}
+
+func flagValue(name string) string {
+	if f := flag.Lookup(name); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
+
+// LevelHandler returns a http.Handler to get(GET) or set(PUT/POST)
+// the log level at runtime, e.g.
+//
+//	curl -X PUT -d '{"v": "5", "vmodule": "db*=6"}' http://localhost/debug/loglevel
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			level := Level{}
+			if err := json.NewDecoder(r.Body).Decode(&level); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := SetLevel(level); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(GetLevel())
+	})
+}
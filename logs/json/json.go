@@ -31,6 +31,9 @@ var (
 	// JSONLogger is global json log format logr
 	JSONLogger logr.Logger
 
+	// StructuredJSONLogger is global structured json log format logr
+	StructuredJSONLogger logr.Logger
+
 	// timeNow stubbed out for testing
 	timeNow = time.Now
 )
@@ -39,8 +42,9 @@ var (
 type zapLogger struct {
 	// NB: this looks very similar to zap.SugaredLogger, but
 	// deals with our desire to have multiple verbosity levels.
-	l   *zap.Logger
-	lvl int
+	l    *zap.Logger
+	lvl  int
+	name string
 }
 
 // implement logr.Logger
@@ -54,8 +58,9 @@ func (l *zapLogger) Enabled() bool {
 // Info write message to error level log
 func (l *zapLogger) Info(msg string, keysAndVals ...interface{}) {
 	entry := zapcore.Entry{
-		Time:    timeNow(),
-		Message: msg,
+		LoggerName: l.name,
+		Time:       timeNow(),
+		Message:    msg,
 	}
 	checkedEntry := l.l.Core().Check(entry, nil)
 	checkedEntry.Write(l.handleFields(keysAndVals)...)
@@ -116,9 +121,10 @@ func (l *zapLogger) handleFields(args []interface{}, additional ...zap.Field) []
 // Error write log message to error level
 func (l *zapLogger) Error(err error, msg string, keysAndVals ...interface{}) {
 	entry := zapcore.Entry{
-		Level:   zapcore.ErrorLevel,
-		Time:    timeNow(),
-		Message: msg,
+		LoggerName: l.name,
+		Level:      zapcore.ErrorLevel,
+		Time:       timeNow(),
+		Message:    msg,
 	}
 	checkedEntry := l.l.Core().Check(entry, nil)
 	checkedEntry.Write(l.handleFields(keysAndVals, handleError(err))...)
@@ -127,21 +133,29 @@ func (l *zapLogger) Error(err error, msg string, keysAndVals ...interface{}) {
 // V return info logr.Logger  with specified level
 func (l *zapLogger) V(level int) logr.Logger {
 	return &zapLogger{
-		lvl: l.lvl + level,
-		l:   l.l,
+		lvl:  l.lvl + level,
+		l:    l.l,
+		name: l.name,
 	}
 }
 
 // WithValues return logr.Logger with some keys And Values
 func (l *zapLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
-	l.l = l.l.With(l.handleFields(keysAndValues)...)
-	return l
+	out := *l
+	out.l = l.l.With(l.handleFields(keysAndValues)...)
+	return &out
 }
 
 // WithName return logger Named with specified name
 func (l *zapLogger) WithName(name string) logr.Logger {
-	l.l = l.l.Named(name)
-	return l
+	out := *l
+	out.l = l.l.Named(name)
+	if l.name == "" {
+		out.name = name
+	} else {
+		out.name = l.name + "." + name
+	}
+	return &out
 }
 
 // encoderConfig config zap encodetime format
@@ -152,8 +166,40 @@ var encoderConfig = zapcore.EncoderConfig{
 	EncodeTime: zapcore.EpochMillisTimeEncoder,
 }
 
+// structuredEncoderConfig also records the level and the module(logger name)
+// of each entry, for ingestion by log pipelines like loki/elk
+var structuredEncoderConfig = zapcore.EncoderConfig{
+	MessageKey: "msg",
+
+	TimeKey:    "timestamp",
+	EncodeTime: zapcore.ISO8601TimeEncoder,
+
+	LevelKey:    "level",
+	EncodeLevel: zapcore.LowercaseLevelEncoder,
+
+	NameKey: "module",
+}
+
 // NewJSONLogger creates a new json logr.Logger using the given Zap Logger to log.
 func NewJSONLogger(w zapcore.WriteSyncer) logr.Logger {
+	return newJSONLogger(w, encoderConfig)
+}
+
+// NewStructuredJSONLogger creates a new json logr.Logger
+// with timestamp, level, module, msg and key-values fields
+func NewStructuredJSONLogger(w zapcore.WriteSyncer) logr.Logger {
+	return newJSONLogger(w, structuredEncoderConfig)
+}
+
+// NewZapLogger creates a new logr.Logger that routes the log entries to l,
+// e.g. the application's own zap logger with its encoder and outputs
+func NewZapLogger(l *zap.Logger) logr.Logger {
+	return &zapLogger{
+		l: l.WithOptions(zap.AddCallerSkip(1)),
+	}
+}
+
+func newJSONLogger(w zapcore.WriteSyncer, config zapcore.EncoderConfig) logr.Logger {
 	l, _ := zap.NewProduction()
 	if w == nil {
 		w = os.Stdout
@@ -161,7 +207,7 @@ func NewJSONLogger(w zapcore.WriteSyncer) logr.Logger {
 	log := l.WithOptions(zap.AddCallerSkip(1),
 		zap.WrapCore(
 			func(zapcore.Core) zapcore.Core {
-				return zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), zapcore.AddSync(w), zapcore.DebugLevel)
+				return zapcore.NewCore(zapcore.NewJSONEncoder(config), zapcore.AddSync(w), zapcore.DebugLevel)
 			}))
 	return &zapLogger{
 		l: log,
@@ -174,4 +220,5 @@ func handleError(err error) zap.Field {
 
 func init() {
 	JSONLogger = NewJSONLogger(nil)
+	StructuredJSONLogger = NewStructuredJSONLogger(nil)
 }
@@ -28,6 +28,7 @@ import (
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
 	"k8s.io/klog/v2"
 )
@@ -188,3 +189,42 @@ func (b *testBuff) Write(p []byte) (int, error) {
 	b.writeCount++
 	return len(p), nil
 }
+
+// TestStructuredJSONLogger test structured json format
+func TestStructuredJSONLogger(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	var buffer bytes.Buffer
+	writer := bufio.NewWriter(&buffer)
+	var logger = NewStructuredJSONLogger(zapcore.AddSync(writer))
+	logger.WithName("db").V(2).Info("test", "ns", "default")
+	logger.WithName("db").Error(fmt.Errorf("invalid"), "test")
+	writer.Flush()
+
+	expect := `{"level":"info","timestamp":"1970-01-01T00:00:00.000Z","module":"db","msg":"test","v":2,"ns":"default"}
+{"level":"error","timestamp":"1970-01-01T00:00:00.000Z","module":"db","msg":"test","err":"invalid","v":0}
+`
+	assert.Equal(t, expect, buffer.String())
+}
+
+// TestZapLogger test routing entries to the given zap logger
+func TestZapLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := NewZapLogger(zap.New(core))
+
+	logger.WithName("db").WithValues("ns", "default").Info("test")
+	logger.Error(fmt.Errorf("invalid"), "test")
+
+	entries := logs.AllUntimed()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "test", entries[0].Message)
+	assert.Equal(t, "db", entries[0].LoggerName)
+	assert.Equal(t, "default", entries[0].ContextMap()["ns"])
+	assert.Equal(t, zapcore.ErrorLevel, entries[1].Level)
+	assert.Equal(t, "invalid", entries[1].ContextMap()["err"])
+	// WithName and WithValues don't change the parent logger
+	assert.Equal(t, "", entries[1].LoggerName)
+	assert.NotContains(t, entries[1].ContextMap(), "ns")
+}
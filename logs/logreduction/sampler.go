@@ -0,0 +1,98 @@
+package logreduction
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampler provides a rate limiter for the messages with the same key;
+// the first N messages of each key are printed per interval,
+// and the rest are dropped and counted.
+type Sampler struct {
+	sync.Mutex
+	first       int
+	interval    time.Duration
+	counters    map[string]*sampleCounter
+	lastCleanup time.Time
+
+	// OnExpire is called with the suppressed number of the expired counter
+	// which is removed before the key is seen again, the suppressed
+	// number is dropped if it's nil
+	OnExpire func(key string, suppressed int)
+}
+
+type sampleCounter struct {
+	start time.Time
+	n     int
+}
+
+// NewSampler returns an initialized Sampler
+func NewSampler(first int, interval time.Duration) *Sampler {
+	return &Sampler{
+		first:       first,
+		interval:    interval,
+		counters:    make(map[string]*sampleCounter),
+		lastCleanup: nowfunc(),
+	}
+}
+
+// Check determines whether a message with the key should be printed,
+// suppressed is the number of the messages dropped in the last interval
+// of the key, which is expected to be summarized with the printed message.
+func (s *Sampler) Check(key string) (ok bool, suppressed int) {
+	s.Lock()
+
+	now := nowfunc()
+	ok, suppressed = s.check(key, now)
+
+	// the counter of the key is renewed by check, so its suppressed
+	// number is returned rather than expired
+	expired := s.cleanup(now)
+	s.Unlock()
+
+	// out of the lock, the callback may log
+	if s.OnExpire != nil {
+		for k, n := range expired {
+			s.OnExpire(k, n)
+		}
+	}
+	return ok, suppressed
+}
+
+func (s *Sampler) check(key string, now time.Time) (ok bool, suppressed int) {
+	c, found := s.counters[key]
+	if !found || now.Sub(c.start) >= s.interval {
+		if found && c.n > s.first {
+			suppressed = c.n - s.first
+		}
+		s.counters[key] = &sampleCounter{start: now, n: 1}
+		return true, suppressed
+	}
+
+	c.n++
+	return c.n <= s.first, 0
+}
+
+// cleanup drops the expired counters, and returns the suppressed numbers
+// of the dropped counters, the keys often contain the ids or addresses,
+// so the counters are dropped even if the key isn't seen again
+func (s *Sampler) cleanup(now time.Time) (expired map[string]int) {
+	if now.Sub(s.lastCleanup) < s.interval {
+		return nil
+	}
+	s.lastCleanup = now
+
+	for key, c := range s.counters {
+		if now.Sub(c.start) < s.interval {
+			continue
+		}
+		if c.n > s.first {
+			if expired == nil {
+				expired = map[string]int{}
+			}
+			expired[key] = c.n - s.first
+		}
+		delete(s.counters, key)
+	}
+	return expired
+}
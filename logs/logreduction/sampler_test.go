@@ -0,0 +1,62 @@
+package logreduction
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	var timeToReturn = time0
+	nowfunc = func() time.Time { return timeToReturn }
+	s := NewSampler(2, identicalErrorDelay)
+
+	cases := []struct {
+		now        time.Time
+		key        string
+		ok         bool
+		suppressed int
+	}{
+		{time0, mesg1, true, 0},
+		{time0, mesg1, true, 0},
+		{time0, mesg1, false, 0},
+		{time0, mesg2, true, 0},
+		{time1, mesg1, false, 0},
+		{time2, mesg1, true, 2},
+		{time2, mesg2, true, 0},
+		{time2, mesg1, true, 0},
+	}
+
+	for i, c := range cases {
+		timeToReturn = c.now
+		ok, suppressed := s.Check(c.key)
+		if ok != c.ok || suppressed != c.suppressed {
+			t.Errorf("case %d failed, expected (%v, %d), got (%v, %d)", i, c.ok, c.suppressed, ok, suppressed)
+		}
+	}
+}
+
+func TestSamplerExpire(t *testing.T) {
+	var timeToReturn = time0
+	nowfunc = func() time.Time { return timeToReturn }
+	s := NewSampler(1, identicalErrorDelay)
+
+	expired := map[string]int{}
+	s.OnExpire = func(key string, suppressed int) { expired[key] = suppressed }
+
+	for i := 0; i < 3; i++ {
+		s.Check(mesg1)
+		s.Check(mesg2)
+	}
+
+	// mesg2 isn't seen again, its counter is removed with the suppressed number
+	timeToReturn = time2
+	if ok, suppressed := s.Check(mesg1); !ok || suppressed != 2 {
+		t.Errorf("expected (true, 2), got (%v, %d)", ok, suppressed)
+	}
+	if len(expired) != 1 || expired[mesg2] != 2 {
+		t.Errorf("expected the expired %s with 2 suppressed, got %v", mesg2, expired)
+	}
+	if _, ok := s.counters[mesg2]; ok || len(s.counters) != 1 {
+		t.Errorf("expected the counter of %s removed, got %v", mesg2, s.counters)
+	}
+}
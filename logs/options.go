@@ -19,11 +19,14 @@ package logs
 import (
 	"flag"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/pflag"
 
+	json "github.com/yubo/golib/logs/json"
 	"github.com/yubo/golib/logs/sanitization"
 	"k8s.io/klog/v2"
 )
@@ -35,14 +38,26 @@ const (
 
 // List of logs (k8s.io/klog + github.com/yubo/golib/logs) flags supported by all logging formats
 var supportedLogsFlags = map[string]struct{}{
-	"v": {},
-	// TODO: support vmodule after 1.19 Alpha
+	"v":       {},
+	"vmodule": {},
 }
 
 // Options has klog format parameters
 type Options struct {
-	LogFormat       string
-	LogSanitization bool
+	LogFormat       string       `json:"format" description:"Sets the log format, text|json|structured-json"`
+	LogSanitization bool         `json:"sanitization"`
+	File            *FileOptions `json:"file"`
+	// Verbosity is the global log level, the same as -v
+	Verbosity *int `json:"v" description:"number for the log level verbosity"`
+	// VModule sets the log level per module, the key is the go file
+	// name pattern (without the .go suffix), e.g. {"db*": 5, "http": 2}
+	VModule map[string]int `json:"vmodule" description:"per module log level verbosity, pattern=N"`
+	// Sampling drops the identical messages, only works with the non-text
+	// log format, Validate rejects it with the text format
+	Sampling *SamplingOptions `json:"sampling" description:"drops the identical log messages, not supported by the text log format"`
+
+	// writer is the log file opened by Apply, reused or closed by the next Apply
+	writer *RotateWriter
 }
 
 // NewOptions return new klog options
@@ -67,6 +82,27 @@ func (o *Options) Validate() []error {
 	if _, err := o.Get(); err != nil {
 		errs = append(errs, fmt.Errorf("unsupported log format: %s", o.LogFormat))
 	}
+	if f := o.File; f != nil && f.Path != "" {
+		if f.MaxSize < 0 || f.MaxBackups < 0 || f.MaxAge.Duration < 0 {
+			errs = append(errs, fmt.Errorf("log file maxSize, maxAge and maxBackups must not be negative"))
+		}
+	}
+	if s := o.Sampling; s != nil {
+		if o.LogFormat == defaultLogFormat {
+			errs = append(errs, fmt.Errorf("log sampling is not supported by the %s log format", defaultLogFormat))
+		}
+		if s.First <= 0 || s.Interval.Duration <= 0 {
+			errs = append(errs, fmt.Errorf("log sampling first and interval must be positive"))
+		}
+	}
+	for pattern, v := range o.VModule {
+		if pattern == "" || strings.ContainsAny(pattern, ",=") {
+			errs = append(errs, fmt.Errorf("invalid vmodule pattern %q", pattern))
+		}
+		if v < 0 {
+			errs = append(errs, fmt.Errorf("invalid vmodule level %d for %q", v, pattern))
+		}
+	}
 	return errs
 }
 
@@ -86,7 +122,7 @@ func flagIsSet(name string) bool {
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	unsupportedFlags := fmt.Sprintf("--%s", strings.Join(unsupportedLoggingFlags(), ", --"))
 	formats := fmt.Sprintf(`"%s"`, strings.Join(logRegistry.List(), `", "`))
-	fs.StringVar(&o.LogFormat, logFormatFlagName, defaultLogFormat, fmt.Sprintf("Sets the log format. Permitted formats: %s.\nNon-default formats don't honor these flags: %s.\nNon-default choices are currently alpha and subject to change without warning.", formats, unsupportedFlags))
+	fs.StringVar(&o.LogFormat, logFormatFlagName, defaultLogFormat, fmt.Sprintf("Sets the log format. Permitted formats: %s.\nNon-default formats don't honor these flags: %s.\nThe log sampling is only supported by the non-default formats.\nNon-default choices are currently alpha and subject to change without warning.", formats, unsupportedFlags))
 
 	// No new log formats should be added after generation is of flag options
 	logRegistry.Freeze()
@@ -98,12 +134,81 @@ Runtime log sanitization may introduce significant computation overhead and ther
 func (o *Options) Apply() {
 	// if log format not exists, use nil loggr
 	loggr, _ := o.Get()
+
+	// the writer of the last Apply, closed if it's replaced
+	var prev *RotateWriter
+	if o.File != nil && o.File.Path != "" {
+		if o.writer == nil || *o.writer.FileOptions != *o.File {
+			file := *o.File
+			w, err := NewRotateWriter(&file)
+			if err != nil {
+				klog.Errorf("open log file %s err: %s", o.File.Path, err)
+			} else {
+				prev, o.writer = o.writer, w
+			}
+		}
+		if o.writer != nil {
+			loggr = o.fileLogger(o.writer)
+		}
+	} else if o.writer != nil {
+		prev, o.writer = o.writer, nil
+		flag.Set("logtostderr", "true")
+	}
+
+	if s := o.Sampling; s != nil && loggr != nil {
+		loggr = NewSampledLogger(loggr, s.First, s.Interval.Duration)
+	}
+
 	klog.SetLogger(loggr)
+
+	if prev != nil {
+		klog.Flush()
+		prev.Close()
+	}
+
+	if o.Verbosity != nil {
+		flag.Set("v", strconv.Itoa(*o.Verbosity))
+	}
+	if len(o.VModule) > 0 {
+		if err := flag.Set("vmodule", o.vmodule()); err != nil {
+			klog.Errorf("set vmodule err: %s", err)
+		}
+	}
+
 	if o.LogSanitization {
 		klog.SetLogFilter(&sanitization.SanitizingFilter{})
 	}
 }
 
+// vmodule returns the VModule as klog -vmodule format, e.g. db*=5,http=2
+func (o *Options) vmodule() string {
+	patterns := make([]string, 0, len(o.VModule))
+	for pattern, v := range o.VModule {
+		patterns = append(patterns, fmt.Sprintf("%s=%d", pattern, v))
+	}
+	sort.Strings(patterns)
+
+	return strings.Join(patterns, ",")
+}
+
+// fileLogger redirects the output of the LogFormat logger to w
+func (o *Options) fileLogger(w *RotateWriter) logr.Logger {
+	switch o.LogFormat {
+	case jsonLogFormat:
+		return json.NewJSONLogger(w)
+	case structuredJSONLogFormat:
+		return json.NewStructuredJSONLogger(w)
+	}
+
+	flag.Set("logtostderr", "false")
+	flag.Set("alsologtostderr", "false")
+	// all severities share the same writer, avoid duplicated lines
+	flag.Set("one_output", "true")
+	klog.SetOutput(w)
+
+	return nil
+}
+
 // Get logger with LogFormat field
 func (o *Options) Get() (logr.Logger, error) {
 	return logRegistry.Get(o.LogFormat)
@@ -0,0 +1,65 @@
+package logs
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/klog/v2"
+)
+
+func TestOptionsVModule(t *testing.T) {
+	defer func() {
+		flag.Set("v", "0")
+		flag.Set("vmodule", "")
+	}()
+
+	v := 1
+	o := NewOptions()
+	o.Verbosity = &v
+	o.VModule = map[string]int{"http": 2, "db*": 5}
+	assert.Empty(t, o.Validate())
+	assert.Equal(t, "db*=5,http=2", o.vmodule())
+
+	o.Apply()
+	assert.Equal(t, "1", flag.Lookup("v").Value.String())
+	assert.Equal(t, "db*=5,http=2", flag.Lookup("vmodule").Value.String())
+	assert.True(t, klog.V(1).Enabled())
+	assert.False(t, klog.V(2).Enabled())
+
+	o.VModule = map[string]int{"a=b": 1}
+	assert.NotEmpty(t, o.Validate())
+}
+
+func TestOptionsApplyFile(t *testing.T) {
+	defer klog.SetLogger(nil)
+
+	dir := t.TempDir()
+	o := NewOptions()
+	o.LogFormat = jsonLogFormat
+	o.File = &FileOptions{Path: filepath.Join(dir, "a.log")}
+
+	o.Apply()
+	w := o.writer
+	assert.NotNil(t, w)
+
+	// the same file is reused
+	o.Apply()
+	assert.Equal(t, w, o.writer)
+
+	// the replaced file is closed
+	o.File = &FileOptions{Path: filepath.Join(dir, "b.log")}
+	o.Apply()
+	assert.NotEqual(t, w, o.writer)
+	_, err := w.Write([]byte("test"))
+	assert.Equal(t, os.ErrClosed, err)
+
+	w = o.writer
+	o.File = nil
+	o.Apply()
+	assert.Nil(t, o.writer)
+	_, err = w.Write([]byte("test"))
+	assert.Equal(t, os.ErrClosed, err)
+}
@@ -25,7 +25,8 @@ import (
 )
 
 const (
-	jsonLogFormat = "json"
+	jsonLogFormat           = "json"
+	structuredJSONLogFormat = "structured-json"
 )
 
 var logRegistry = NewLogFormatRegistry()
@@ -56,6 +57,14 @@ func (lfr *LogFormatRegistry) Register(name string, logger logr.Logger) error {
 	return nil
 }
 
+// RegisterLogFormat registers a custom logger as a log format,
+// e.g. an adapter of the application's logger(zap, zerolog ...),
+// so that it can be selected by the format option.
+// It must be called before the flags are added.
+func RegisterLogFormat(name string, logger logr.Logger) error {
+	return logRegistry.Register(name, logger)
+}
+
 // Get specified log format logger
 func (lfr *LogFormatRegistry) Get(name string) (logr.Logger, error) {
 	re, ok := lfr.registry[name]
@@ -103,4 +112,5 @@ func init() {
 	// Text format is default klog format
 	logRegistry.Register(defaultLogFormat, nil)
 	logRegistry.Register(jsonLogFormat, json.JSONLogger)
+	logRegistry.Register(structuredJSONLogFormat, json.StructuredJSONLogger)
 }
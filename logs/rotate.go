@@ -0,0 +1,349 @@
+package logs
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yubo/golib/api"
+)
+
+const (
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	compressSuffix   = ".gz"
+	megabyte         = 1024 * 1024
+)
+
+// FileOptions is the config of the log file output
+type FileOptions struct {
+	Path       string       `json:"path" description:"log to this file instead of stderr"`
+	MaxSize    int          `json:"maxSize" description:"the maximum size in megabytes of the log file before it gets rotated, 0 means never rotate"`
+	MaxAge     api.Duration `json:"maxAge" description:"the maximum duration to retain old log files, e.g. 168h, 0 means no limit"`
+	MaxBackups int          `json:"maxBackups" description:"the maximum number of old log files to retain, 0 means no limit"`
+	Compress   bool         `json:"compress" description:"compress the rotated log files using gzip"`
+}
+
+// RotateWriter is an io.WriteCloser that writes to the file,
+// and rotates the file when its size exceeds the maxSize
+type RotateWriter struct {
+	sync.Mutex
+	*FileOptions
+
+	maxSize int64
+	size    int64
+	file    *os.File
+	closed  bool
+	now     func() time.Time
+
+	// the rotated files are compressed and cleaned up in one goroutine,
+	// the pending rotations are merged into one cleanup
+	cleanupCh   chan struct{}
+	cleanupDone chan struct{}
+}
+
+func NewRotateWriter(opts *FileOptions) (*RotateWriter, error) {
+	if opts == nil || opts.Path == "" {
+		return nil, fmt.Errorf("log file path is empty")
+	}
+
+	w := &RotateWriter{
+		FileOptions: opts,
+		maxSize:     int64(opts.MaxSize) * megabyte,
+		now:         time.Now,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0755); err != nil {
+		return nil, err
+	}
+
+	if err := w.openFile(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write implements io.Writer
+func (p *RotateWriter) Write(b []byte) (int, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.closed {
+		return 0, os.ErrClosed
+	}
+
+	// reopen the file left closed by a failed rotation
+	if p.file == nil {
+		if err := p.openFile(); err != nil {
+			return 0, err
+		}
+	}
+
+	if p.maxSize > 0 && p.size > 0 && p.size+int64(len(b)) > p.maxSize {
+		if err := p.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := p.file.Write(b)
+	p.size += int64(n)
+	return n, err
+}
+
+// Sync flush the file, implements zapcore.WriteSyncer
+func (p *RotateWriter) Sync() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.file == nil {
+		return nil
+	}
+	return p.file.Sync()
+}
+
+// Close implements io.Closer
+func (p *RotateWriter) Close() error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.closed = true
+
+	// wait for the pending cleanups
+	if p.cleanupCh != nil {
+		close(p.cleanupCh)
+		<-p.cleanupDone
+		p.cleanupCh = nil
+	}
+
+	if p.file == nil {
+		return nil
+	}
+
+	err := p.file.Close()
+	p.file = nil
+	return err
+}
+
+// Rotate closes the current file, moves it aside with a timestamp suffix
+// and opens a new file
+func (p *RotateWriter) Rotate() error {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.rotate()
+}
+
+func (p *RotateWriter) openFile() error {
+	f, err := os.OpenFile(p.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	p.file = f
+	p.size = info.Size()
+	return nil
+}
+
+func (p *RotateWriter) rotate() error {
+	if p.file != nil {
+		if err := p.file.Close(); err != nil {
+			return err
+		}
+		p.file = nil
+	}
+
+	backup := p.uniqueBackupName(p.now())
+	if err := os.Rename(p.Path, backup); err != nil && !os.IsNotExist(err) {
+		// keep writing to the current file
+		if oerr := p.openFile(); oerr != nil {
+			return fmt.Errorf("%s, reopen err: %s", err, oerr)
+		}
+		return err
+	}
+
+	if err := p.openFile(); err != nil {
+		return err
+	}
+
+	// compress & clean up in the background, do not block the writer
+	if p.cleanupCh == nil {
+		p.cleanupCh = make(chan struct{}, 1)
+		p.cleanupDone = make(chan struct{})
+		go func(ch <-chan struct{}, done chan<- struct{}) {
+			defer close(done)
+			for range ch {
+				p.cleanup()
+			}
+		}(p.cleanupCh, p.cleanupDone)
+	}
+	select {
+	case p.cleanupCh <- struct{}{}:
+	default:
+		// a cleanup is pending, which also handles this backup
+	}
+
+	return nil
+}
+
+// uniqueBackupName returns the backup name of the time, the time is
+// increased by a millisecond if the name, or its compressed file, exists
+func (p *RotateWriter) uniqueBackupName(t time.Time) string {
+	for {
+		name := p.backupName(t)
+		if !fileExists(name) && !fileExists(name+compressSuffix) {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func fileExists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// backupName returns foo-2006-01-02T15-04-05.000.log for foo.log,
+// the time is in UTC, as it's parsed by backups
+func (p *RotateWriter) backupName(t time.Time) string {
+	ext := filepath.Ext(p.Path)
+	prefix := strings.TrimSuffix(p.Path, ext)
+	return fmt.Sprintf("%s-%s%s", prefix, t.UTC().Format(backupTimeFormat), ext)
+}
+
+type backupFile struct {
+	path string
+	t    time.Time
+	// the uncompressed file of the same backup, left by an interrupted compression
+	dup string
+}
+
+// backups returns the rotated files, newest first, the compressed and the
+// uncompressed files of the same time are one backup
+func (p *RotateWriter) backups() ([]backupFile, error) {
+	dir := filepath.Dir(p.Path)
+	ext := filepath.Ext(p.Path)
+	prefix := strings.TrimSuffix(filepath.Base(p.Path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []backupFile{}
+	index := map[time.Time]int{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		ts := strings.TrimSuffix(strings.TrimSuffix(name, compressSuffix), ext)
+		t, err := time.Parse(backupTimeFormat, strings.TrimPrefix(ts, prefix))
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if i, ok := index[t]; ok {
+			// prefer the compressed one
+			if strings.HasSuffix(path, compressSuffix) {
+				files[i].path, files[i].dup = path, files[i].path
+			} else {
+				files[i].dup = path
+			}
+			continue
+		}
+		index[t] = len(files)
+		files = append(files, backupFile{path: path, t: t})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].t.After(files[j].t) })
+	return files, nil
+}
+
+// cleanup compresses the uncompressed backups, and removes the backups
+// beyond the MaxBackups or the MaxAge
+func (p *RotateWriter) cleanup() {
+	if !p.Compress && p.MaxBackups <= 0 && p.MaxAge.Duration <= 0 {
+		return
+	}
+
+	files, err := p.backups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "log: list backups err: %s\n", err)
+		return
+	}
+
+	if p.Compress {
+		for i, f := range files {
+			// the dup is the uncompressed file of an interrupted compression
+			src := f.dup
+			if src == "" && !strings.HasSuffix(f.path, compressSuffix) {
+				src = f.path
+			}
+			if src == "" {
+				continue
+			}
+			if err := compressFile(src); err != nil {
+				fmt.Fprintf(os.Stderr, "log: compress %s err: %s\n", src, err)
+				continue
+			}
+			files[i] = backupFile{path: src + compressSuffix, t: f.t}
+		}
+	}
+
+	if p.MaxBackups <= 0 && p.MaxAge.Duration <= 0 {
+		return
+	}
+
+	cutoff := p.now().Add(-p.MaxAge.Duration)
+	for i, f := range files {
+		if (p.MaxBackups > 0 && i >= p.MaxBackups) ||
+			(p.MaxAge.Duration > 0 && f.t.Before(cutoff)) {
+			os.Remove(f.path)
+			if f.dup != "" {
+				os.Remove(f.dup)
+			}
+		}
+	}
+}
+
+func compressFile(src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gzf, err := os.OpenFile(src+compressSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(gzf)
+	if _, err := io.Copy(gz, f); err != nil {
+		gz.Close()
+		gzf.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		gzf.Close()
+		return err
+	}
+	if err := gzf.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
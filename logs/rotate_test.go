@@ -0,0 +1,158 @@
+package logs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/golib/api"
+)
+
+func TestRotateWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.log")
+
+	w, err := NewRotateWriter(&FileOptions{Path: path})
+	require.NoError(t, err)
+	defer w.Close()
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+	w.maxSize = 10
+
+	_, err = w.Write([]byte("0123456789"))
+	require.NoError(t, err)
+
+	// exceeds the maxSize, rotate
+	_, err = w.Write([]byte("abc"))
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(b))
+
+	b, err = os.ReadFile(filepath.Join(dir, "test-2021-01-01T00-00-00.000.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(b))
+}
+
+func TestRotateWriterCleanup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.log")
+
+	now := time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC)
+	w := &RotateWriter{
+		FileOptions: &FileOptions{
+			Path:       path,
+			MaxAge:     api.Duration{Duration: 5 * 24 * time.Hour},
+			MaxBackups: 2,
+			Compress:   true,
+		},
+		now: func() time.Time { return now },
+	}
+
+	for _, day := range []int{1, 7, 8, 9} {
+		name := w.backupName(time.Date(2021, 1, day, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, os.WriteFile(name, []byte("test"), 0644))
+	}
+
+	w.cleanup()
+
+	files, err := w.backups()
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, f := range files {
+		names = append(names, filepath.Base(f.path))
+	}
+	assert.Equal(t, []string{
+		"test-2021-01-09T00-00-00.000.log.gz",
+		"test-2021-01-08T00-00-00.000.log.gz",
+	}, names)
+}
+
+func TestRotateWriterMaxAgeLocal(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+8", 8*3600)
+	defer func() { time.Local = local }()
+
+	dir := t.TempDir()
+	now := time.Date(2021, 1, 10, 0, 0, 0, 0, time.Local)
+	w := &RotateWriter{
+		FileOptions: &FileOptions{
+			Path:   filepath.Join(dir, "test.log"),
+			MaxAge: api.Duration{Duration: 5 * time.Hour},
+		},
+		now: func() time.Time { return now },
+	}
+
+	for _, h := range []int{4, 6} {
+		name := w.backupName(now.Add(-time.Duration(h) * time.Hour))
+		require.NoError(t, os.WriteFile(name, []byte("test"), 0644))
+	}
+
+	w.cleanup()
+
+	files, err := w.backups()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(files))
+	assert.Equal(t, "test-2021-01-09T12-00-00.000.log", filepath.Base(files[0].path))
+}
+
+func TestRotateWriterRenameError(t *testing.T) {
+	// the backup name is too long to be renamed to
+	dir := t.TempDir()
+	path := filepath.Join(dir, strings.Repeat("a", 240)+".log")
+
+	w, err := NewRotateWriter(&FileOptions{Path: path})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("abc"))
+	require.NoError(t, err)
+	assert.Error(t, w.Rotate())
+
+	// the current file is reopened
+	_, err = w.Write([]byte("def"))
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef", string(b))
+}
+
+func TestRotateWriterFastRotations(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.log")
+
+	w, err := NewRotateWriter(&FileOptions{Path: path, MaxBackups: 2, Compress: true})
+	require.NoError(t, err)
+
+	// all the rotations happen in the same millisecond
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		_, err = w.Write([]byte{byte('0' + i)})
+		require.NoError(t, err)
+		require.NoError(t, w.Rotate())
+	}
+	require.NoError(t, w.Close())
+
+	files, err := w.backups()
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, f := range files {
+		assert.Empty(t, f.dup)
+		names = append(names, filepath.Base(f.path))
+	}
+	assert.Equal(t, []string{
+		"test-2021-01-01T00-00-00.004.log.gz",
+		"test-2021-01-01T00-00-00.003.log.gz",
+	}, names)
+}
@@ -0,0 +1,77 @@
+package logs
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/logs/logreduction"
+)
+
+// SamplingOptions limits the identical messages,
+// the first N messages are logged per interval, the rest are dropped
+// and summarized with the next logged one as the "suppressed" field.
+// The text log format is written by klog itself and can't be sampled,
+// so the sampling requires a non-text log format, e.g. json
+type SamplingOptions struct {
+	First    int          `json:"first" description:"the number of the identical messages to log per interval"`
+	Interval api.Duration `json:"interval" description:"the sampling interval, e.g. 1m"`
+}
+
+// NewSampledLogger wraps l with a per-message sampler
+func NewSampledLogger(l logr.Logger, first int, interval time.Duration) logr.Logger {
+	sampler := logreduction.NewSampler(first, interval)
+	// the key isn't seen again in the interval, summarize it alone
+	sampler.OnExpire = func(key string, suppressed int) {
+		l.Info("identical log messages suppressed", "message", key, "suppressed", suppressed)
+	}
+
+	return &sampledLogger{
+		Logger:  l,
+		sampler: sampler,
+	}
+}
+
+type sampledLogger struct {
+	logr.Logger
+	sampler *logreduction.Sampler
+}
+
+func (l *sampledLogger) Info(msg string, keysAndValues ...interface{}) {
+	ok, suppressed := l.sampler.Check(msg)
+	if !ok {
+		return
+	}
+	if suppressed > 0 {
+		keysAndValues = append(keysAndValues, "suppressed", suppressed)
+	}
+	l.Logger.Info(msg, keysAndValues...)
+}
+
+func (l *sampledLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	key := msg
+	if err != nil {
+		key += ": " + err.Error()
+	}
+
+	ok, suppressed := l.sampler.Check(key)
+	if !ok {
+		return
+	}
+	if suppressed > 0 {
+		keysAndValues = append(keysAndValues, "suppressed", suppressed)
+	}
+	l.Logger.Error(err, msg, keysAndValues...)
+}
+
+func (l *sampledLogger) V(level int) logr.Logger {
+	return &sampledLogger{Logger: l.Logger.V(level), sampler: l.sampler}
+}
+
+func (l *sampledLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return &sampledLogger{Logger: l.Logger.WithValues(keysAndValues...), sampler: l.sampler}
+}
+
+func (l *sampledLogger) WithName(name string) logr.Logger {
+	return &sampledLogger{Logger: l.Logger.WithName(name), sampler: l.sampler}
+}
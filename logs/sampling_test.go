@@ -0,0 +1,27 @@
+package logs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampledLogger(t *testing.T) {
+	entries := []*Entry{}
+	l := NewSampledLogger(NewSinkLogger(SinkFunc(func(e *Entry) error {
+		entries = append(entries, e)
+		return nil
+	})), 2, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		l.V(1).Error(fmt.Errorf("connection refused"), "dial")
+	}
+	l.Info("test")
+
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "dial", entries[1].Message)
+	assert.Equal(t, 1, entries[1].Level)
+	assert.Equal(t, "test", entries[2].Message)
+}
@@ -0,0 +1,157 @@
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// Severity is the severity of the log entry
+type Severity int
+
+const (
+	InfoSeverity  Severity = iota // logged by Info
+	ErrorSeverity                 // logged by Error, the Err may be nil
+)
+
+func (s Severity) String() string {
+	switch s {
+	case InfoSeverity:
+		return "info"
+	case ErrorSeverity:
+		return "error"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Entry is a log record passed to the Sink
+type Entry struct {
+	Time          time.Time
+	Severity      Severity
+	Name          string
+	Level         int
+	Err           error
+	Message       string
+	KeysAndValues []interface{}
+}
+
+// Sink is the log backend, e.g. an adapter of zap, zerolog or any other logger
+type Sink interface {
+	Write(entry *Entry) error
+}
+
+// SinkFunc is a function adapter of Sink
+type SinkFunc func(entry *Entry) error
+
+func (f SinkFunc) Write(entry *Entry) error {
+	return f(entry)
+}
+
+// NewSinkLogger creates a logr.Logger that routes the log entries to the sink,
+// it can be set as the klog logger or registered as a log format
+// by RegisterLogFormat
+func NewSinkLogger(sink Sink) logr.Logger {
+	return &sinkLogger{sink: sink}
+}
+
+type sinkLogger struct {
+	sink   Sink
+	name   string
+	level  int
+	values []interface{}
+}
+
+var _ logr.Logger = &sinkLogger{}
+
+func (l *sinkLogger) Enabled() bool {
+	return true
+}
+
+func (l *sinkLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.write(InfoSeverity, nil, msg, keysAndValues)
+}
+
+func (l *sinkLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.write(ErrorSeverity, err, msg, keysAndValues)
+}
+
+func (l *sinkLogger) write(severity Severity, err error, msg string, keysAndValues []interface{}) {
+	kvs := keysAndValues
+	if len(l.values) > 0 {
+		kvs = make([]interface{}, 0, len(l.values)+len(keysAndValues))
+		kvs = append(append(kvs, l.values...), keysAndValues...)
+	}
+
+	l.sink.Write(&Entry{
+		Time:          time.Now(),
+		Severity:      severity,
+		Name:          l.name,
+		Level:         l.level,
+		Err:           err,
+		Message:       msg,
+		KeysAndValues: kvs,
+	})
+}
+
+func (l *sinkLogger) V(level int) logr.Logger {
+	out := *l
+	out.level += level
+	return &out
+}
+
+func (l *sinkLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	out := *l
+	out.values = append(append([]interface{}{}, l.values...), keysAndValues...)
+	return &out
+}
+
+func (l *sinkLogger) WithName(name string) logr.Logger {
+	out := *l
+	if out.name == "" {
+		out.name = name
+	} else {
+		out.name += "." + name
+	}
+	return &out
+}
+
+// NewWriterSink returns a Sink that writes the entries to w in text format, e.g.
+//
+//	2006-01-02T15:04:05.000Z07:00 info v=2 module=db msg="test" ns="default"
+//	2006-01-02T15:04:05.000Z07:00 error v=0 module=db msg="test" err="invalid"
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{w: w}
+}
+
+type writerSink struct {
+	sync.Mutex
+	w io.Writer
+}
+
+func (p *writerSink) Write(e *Entry) error {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "%s %s v=%d", e.Time.Format("2006-01-02T15:04:05.000Z07:00"), e.Severity, e.Level)
+	if e.Name != "" {
+		fmt.Fprintf(buf, " module=%s", e.Name)
+	}
+	fmt.Fprintf(buf, " msg=%q", e.Message)
+	if e.Err != nil {
+		fmt.Fprintf(buf, " err=%q", e.Err.Error())
+	}
+	for i := 0; i+1 < len(e.KeysAndValues); i += 2 {
+		fmt.Fprintf(buf, " %v=%q", e.KeysAndValues[i], fmt.Sprintf("%+v", e.KeysAndValues[i+1]))
+	}
+	buf.WriteByte('\n')
+
+	p.Lock()
+	defer p.Unlock()
+
+	_, err := p.w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,48 @@
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSinkLogger(t *testing.T) {
+	entries := []*Entry{}
+	l := NewSinkLogger(SinkFunc(func(e *Entry) error {
+		entries = append(entries, e)
+		return nil
+	}))
+
+	l.WithName("db").WithValues("ns", "default").V(2).Info("test", "a", 1)
+	l.Error(fmt.Errorf("invalid"), "test")
+	l.Error(nil, "test")
+
+	assert.Len(t, entries, 3)
+	assert.Equal(t, InfoSeverity, entries[0].Severity)
+	assert.Equal(t, "db", entries[0].Name)
+	assert.Equal(t, 2, entries[0].Level)
+	assert.Equal(t, []interface{}{"ns", "default", "a", 1}, entries[0].KeysAndValues)
+	assert.Equal(t, "", entries[1].Name)
+	assert.Equal(t, ErrorSeverity, entries[1].Severity)
+	assert.EqualError(t, entries[1].Err, "invalid")
+	assert.Equal(t, ErrorSeverity, entries[2].Severity)
+	assert.Nil(t, entries[2].Err)
+}
+
+func TestWriterSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewSinkLogger(NewWriterSink(buf))
+
+	l.WithName("db").V(2).Info("test", "ns", "default")
+	l.WithName("db").Error(fmt.Errorf("invalid"), "test")
+	l.Error(nil, "test")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], ` info v=2 module=db msg="test" ns="default"`)
+	assert.Contains(t, lines[1], ` error v=0 module=db msg="test" err="invalid"`)
+	assert.Contains(t, lines[2], ` error v=0 msg="test"`)
+}
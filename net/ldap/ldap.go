@@ -14,7 +14,7 @@ type LdapConfig struct {
 	Addr               string `json:"addr"`
 	BaseDN             string `json:"baseDn"`
 	BindDN             string `json:"bindDn"`
-	BindPwd            string `json:"bindPwd"`
+	BindPwd            string `json:"bindPwd" datapolicy:"password"`
 	Filter             string `json:"filter"`
 	SSL                bool   `json:"ssl"`
 	TLS                bool   `json:"tls"`
@@ -44,7 +44,7 @@ func (p *LdapConfig) Validate() error {
 }
 
 func (p LdapConfig) String() string {
-	return util.Prettify(p)
+	return util.PrettifyRedacted(p)
 }
 
 type Ldap struct {
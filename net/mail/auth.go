@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"fmt"
+	"net/smtp"
+)
+
+// xoauth2Auth implements the XOAUTH2 mechanism used by gmail and office365
+// https://developers.google.com/gmail/imap/xoauth2-protocol
+type xoauth2Auth struct {
+	username    string
+	token       string
+	tokenSource func() (string, error)
+}
+
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS {
+		return "", nil, fmt.Errorf("xoauth2: unencrypted connection")
+	}
+
+	token := a.token
+	if a.tokenSource != nil {
+		var err error
+		if token, err = a.tokenSource(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + token + "\x01\x01"), nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// the server responds with a json error message, send an empty
+		// response to get the final error code
+		return []byte{}, nil
+	}
+	return nil, nil
+}
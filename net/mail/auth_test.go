@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"net/smtp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestXOAuth2(t *testing.T) {
+	a := &xoauth2Auth{username: "alex@example.com", token: "abc"}
+
+	_, _, err := a.Start(&smtp.ServerInfo{Name: "smtp.example.com"})
+	require.Error(t, err)
+
+	proto, resp, err := a.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true})
+	require.NoError(t, err)
+	require.Equal(t, "XOAUTH2", proto)
+	require.Equal(t, "user=alex@example.com\x01auth=Bearer abc\x01\x01", string(resp))
+
+	a.tokenSource = func() (string, error) { return "def", nil }
+	_, resp, _ = a.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true})
+	require.Equal(t, "user=alex@example.com\x01auth=Bearer def\x01\x01", string(resp))
+}
+
+func TestDialerOptions(t *testing.T) {
+	config := &Config{Host: "smtp.example.com", Port: 587, TLSMode: TLSModeImplicit,
+		InsecureSkipVerify: true, AuthType: AuthTypeXOAuth2}
+	require.NoError(t, config.Validate())
+
+	d, err := config.newDialer()
+	require.NoError(t, err)
+	require.True(t, d.SSL)
+	require.True(t, d.TLSConfig.InsecureSkipVerify)
+	require.IsType(t, &xoauth2Auth{}, d.Auth)
+
+	config = &Config{Host: "smtp.example.com", Port: 465, TLSMode: TLSModeStartTLS}
+	d, err = config.newDialer()
+	require.NoError(t, err)
+	require.False(t, d.SSL)
+
+	config.TLSMode = "ssl"
+	require.Error(t, config.Validate())
+}
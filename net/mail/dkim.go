@@ -0,0 +1,216 @@
+package mail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/yubo/golib/util/keyutil"
+	gomail "gopkg.in/gomail.v2"
+)
+
+var defaultDkimHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc",
+	"Message-ID", "MIME-Version", "Content-Type",
+}
+
+// DkimConfig signs outgoing messages with rsa-sha256, relaxed/relaxed canonicalization
+type DkimConfig struct {
+	Domain         string   `json:"domain"`
+	Selector       string   `json:"selector"`
+	PrivateKey     string   `json:"privateKey" description:"rsa private key in PEM format" datapolicy:"security-key"`
+	PrivateKeyFile string   `json:"privateKeyFile"`
+	Headers        []string `json:"headers" description:"headers to be signed, default: From, Reply-To, Subject, Date, To, Cc, Message-ID, MIME-Version, Content-Type"`
+}
+
+func (p DkimConfig) String() string {
+	p.PrivateKey = "<hidden>"
+	return fmt.Sprintf("domain %s selector %s privateKeyFile %s headers %v",
+		p.Domain, p.Selector, p.PrivateKeyFile, p.Headers)
+}
+
+func (p *DkimConfig) Validate() error {
+	if p.Domain == "" || p.Selector == "" {
+		return fmt.Errorf("dkim: domain and selector must be set")
+	}
+	if p.PrivateKey == "" && p.PrivateKeyFile == "" {
+		return fmt.Errorf("dkim: privateKey or privateKeyFile must be set")
+	}
+	return nil
+}
+
+func (p *DkimConfig) signer() (*dkimSigner, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	data := []byte(p.PrivateKey)
+	if len(data) == 0 {
+		var err error
+		if data, err = ioutil.ReadFile(p.PrivateKeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := keyutil.ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("dkim: %s", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("dkim: private key is not a rsa key")
+	}
+
+	headers := p.Headers
+	if len(headers) == 0 {
+		headers = defaultDkimHeaders
+	}
+
+	return &dkimSigner{
+		domain:   p.Domain,
+		selector: p.Selector,
+		key:      rsaKey,
+		headers:  headers,
+	}, nil
+}
+
+type dkimSigner struct {
+	domain   string
+	selector string
+	key      *rsa.PrivateKey
+	headers  []string
+}
+
+// Sign returns the message with the DKIM-Signature header prepended
+func (p *dkimSigner) Sign(msg []byte) ([]byte, error) {
+	header, body := splitMessage(msg)
+
+	bh := sha256.Sum256(dkimRelaxedBody(body))
+
+	// the last instance of a header is signed first (rfc6376 5.4.2),
+	// mail composed by gomail has one instance for each header
+	fields := parseHeader(header)
+	names := []string{}
+	hashed := &bytes.Buffer{}
+	for _, name := range p.headers {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if strings.EqualFold(fields[i].name, name) {
+				names = append(names, strings.ToLower(name))
+				hashed.WriteString(dkimRelaxedHeader(fields[i].name, fields[i].value))
+				break
+			}
+		}
+	}
+
+	sig := fmt.Sprintf("v=1; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		p.domain, p.selector, time.Now().Unix(), strings.Join(names, ":"),
+		base64.StdEncoding.EncodeToString(bh[:]))
+
+	// the signature header itself is hashed without the trailing CRLF
+	hashed.WriteString(strings.TrimSuffix(dkimRelaxedHeader("DKIM-Signature", sig), "\r\n"))
+	sum := sha256.Sum256(hashed.Bytes())
+
+	b, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("DKIM-Signature: " + sig + base64.StdEncoding.EncodeToString(b) + "\r\n")
+	buf.Write(msg)
+
+	return buf.Bytes(), nil
+}
+
+// dkimSender signs the message before passing it to the underlying sender
+type dkimSender struct {
+	gomail.Sender
+	signer *dkimSigner
+}
+
+func (p *dkimSender) Send(from string, to []string, msg io.WriterTo) error {
+	buf := &bytes.Buffer{}
+	if _, err := msg.WriteTo(buf); err != nil {
+		return err
+	}
+
+	signed, err := p.signer.Sign(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return p.Sender.Send(from, to, bytes.NewBuffer(signed))
+}
+
+type headerField struct {
+	name  string
+	value string // raw value, may contain folding CRLF
+}
+
+func splitMessage(msg []byte) (header, body []byte) {
+	if i := bytes.Index(msg, []byte("\r\n\r\n")); i >= 0 {
+		return msg[:i+2], msg[i+4:]
+	}
+	return msg, nil
+}
+
+func parseHeader(header []byte) (fields []headerField) {
+	lines := strings.SplitAfter(string(header), "\r\n")
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].value += line
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		fields = append(fields, headerField{name: line[:i], value: line[i+1:]})
+	}
+	return
+}
+
+// rfc6376 3.4.2
+func dkimRelaxedHeader(name, value string) string {
+	value = strings.Replace(value, "\r\n", "", -1)
+	value = strings.Join(strings.Fields(value), " ")
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + value + "\r\n"
+}
+
+// rfc6376 3.4.4
+func dkimRelaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(strings.Join(strings.FieldsFunc(line, isWSP), " "), " ")
+		if len(line) > 0 && isWSP(rune(line[0])) && lines[i] != "" {
+			lines[i] = " " + lines[i]
+		}
+	}
+
+	// ignore all empty lines at the end of the message body
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		return []byte{}
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func isWSP(r rune) bool {
+	return r == ' ' || r == '\t'
+}
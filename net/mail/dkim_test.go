@@ -0,0 +1,80 @@
+package mail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/golib/util/keyutil"
+	gomail "gopkg.in/gomail.v2"
+)
+
+func TestDkimRelaxed(t *testing.T) {
+	require.Equal(t, "subject:hello world\r\n", dkimRelaxedHeader("Subject ", " hello \r\n\t world "))
+	require.Equal(t, " c\r\nd e\r\n", string(dkimRelaxedBody([]byte(" c \r\nd \t e\r\n\r\n\r\n"))))
+	require.Equal(t, "", string(dkimRelaxedBody([]byte("\r\n"))))
+}
+
+func TestDkimSign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	pem, err := keyutil.MarshalPrivateKeyToPEM(key)
+	require.NoError(t, err)
+
+	config := &DkimConfig{Domain: "example.com", Selector: "mail", PrivateKey: string(pem)}
+	signer, err := config.signer()
+	require.NoError(t, err)
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", "alex@example.com")
+	m.SetHeader("To", "bob@example.com")
+	m.SetHeader("Subject", "Hello!")
+	m.SetBody("text/plain", "Hello Bob")
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	require.NoError(t, err)
+
+	signed, err := signer.Sign(buf.Bytes())
+	require.NoError(t, err)
+
+	// verify
+	header, body := splitMessage(signed)
+	fields := parseHeader(header)
+	require.Equal(t, "DKIM-Signature", fields[0].name)
+
+	tags := map[string]string{}
+	for _, kv := range strings.Split(fields[0].value, ";") {
+		kv = strings.TrimSpace(kv)
+		if i := strings.Index(kv, "="); i > 0 {
+			tags[kv[:i]] = kv[i+1:]
+		}
+	}
+	require.Equal(t, "example.com", tags["d"])
+	require.Equal(t, "mail", tags["s"])
+
+	bh := sha256.Sum256(dkimRelaxedBody(body))
+	require.Equal(t, base64.StdEncoding.EncodeToString(bh[:]), tags["bh"])
+
+	hashed := &bytes.Buffer{}
+	for _, name := range strings.Split(tags["h"], ":") {
+		for _, f := range fields[1:] {
+			if strings.EqualFold(f.name, name) {
+				hashed.WriteString(dkimRelaxedHeader(f.name, f.value))
+			}
+		}
+	}
+	sigValue := strings.TrimSuffix(fields[0].value, tags["b"]+"\r\n")
+	hashed.WriteString(strings.TrimSuffix(dkimRelaxedHeader("DKIM-Signature", sigValue), "\r\n"))
+	sum := sha256.Sum256(hashed.Bytes())
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	require.NoError(t, err)
+	require.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig))
+}
@@ -2,32 +2,71 @@ package mail
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/smtp"
 	"os"
 
 	ht "html/template"
 
+	"github.com/yubo/golib/crypto/tlsutil"
 	"github.com/yubo/golib/util"
 	gomail "gopkg.in/gomail.v2"
 )
 
+const (
+	TLSModeAuto     = ""         // implicit TLS on port 465, otherwise STARTTLS if the server supports it
+	TLSModeImplicit = "implicit" // implicit TLS, e.g. smtps on port 465
+	TLSModeStartTLS = "starttls" // plain connection upgraded by STARTTLS if the server supports it
+
+	AuthTypeAuto    = ""        // chosen by the server's AUTH extension, e.g. CRAM-MD5, LOGIN, PLAIN
+	AuthTypePlain   = "plain"   // PLAIN
+	AuthTypeXOAuth2 = "xoauth2" // XOAUTH2, password is used as the oauth2 access token
+)
+
 type Config struct {
-	Enabled  bool     `json:"enabled"`
-	From     []string `json:"from"`
-	Host     string   `json:"host"`
-	Port     int      `json:"port"`
-	Username string   `json:"username"`
-	Password string   `json:"password"`
-	TmpDir   string   `json:"tmpDir"`
+	Enabled            bool        `json:"enabled"`
+	From               []string    `json:"from"`
+	Host               string      `json:"host"`
+	Port               int         `json:"port"`
+	Username           string      `json:"username"`
+	Password           string      `json:"password" datapolicy:"password"`
+	TmpDir             string      `json:"tmpDir"`
+	Dkim               *DkimConfig `json:"dkim"`
+	TLSMode            string      `json:"tlsMode" description:"implicit|starttls, default implicit on port 465, otherwise starttls"`
+	CaFile             string      `json:"caFile"`
+	InsecureSkipVerify bool        `json:"insecureSkipVerify"`
+	AuthType           string      `json:"authType" description:"plain|xoauth2, default chosen by the server"`
+
+	// TokenSource returns the oauth2 access token for xoauth2,
+	// Password is used as the token if it is nil
+	TokenSource func() (string, error) `json:"-"`
 }
 
 func (p Config) String() string {
-	return util.Prettify(p)
+	return util.PrettifyRedacted(p)
 }
 
 func (p *Config) Validate() error {
+	switch p.TLSMode {
+	case TLSModeAuto, TLSModeImplicit, TLSModeStartTLS:
+	default:
+		return fmt.Errorf("unsupported tlsMode %q", p.TLSMode)
+	}
+
+	switch p.AuthType {
+	case AuthTypeAuto, AuthTypePlain, AuthTypeXOAuth2:
+	default:
+		return fmt.Errorf("unsupported authType %q", p.AuthType)
+	}
+
+	if p.Dkim != nil {
+		if err := p.Dkim.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -65,6 +104,49 @@ func (p *Config) NewMail(tpl Executer, input interface{}) (*MailContext, error)
 		contentType = "text/plain"
 	}
 
+	ctx, err := p.newMailContext()
+	if err != nil {
+		return nil, err
+	}
+	ctx.Message.SetBody(contentType, buff.String())
+
+	return ctx, nil
+}
+
+// NewTemplateMail composes the message from the named template in tpls,
+// the text/plain part is the body and the html part is the alternative
+func (p *Config) NewTemplateMail(tpls *Templates, name string, input interface{}) (*MailContext, error) {
+	if p == nil {
+		return nil, fmt.Errorf("mail config is nil ptr")
+	}
+	if !p.Enabled {
+		return nil, fmt.Errorf("mail is not enabled")
+	}
+
+	html, text, err := tpls.Execute(name, input)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := p.newMailContext()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case text != "" && html != "":
+		ctx.Message.SetBody("text/plain", text)
+		ctx.Message.AddAlternative("text/html", html)
+	case html != "":
+		ctx.Message.SetBody("text/html", html)
+	default:
+		ctx.Message.SetBody("text/plain", text)
+	}
+
+	return ctx, nil
+}
+
+func (p *Config) newMailContext() (*MailContext, error) {
 	m := gomail.NewMessage()
 
 	if len(p.From) == 2 {
@@ -73,8 +155,10 @@ func (p *Config) NewMail(tpl Executer, input interface{}) (*MailContext, error)
 		m.SetHeader("From", p.From[0])
 	}
 
-	m.SetBody(contentType, buff.String())
-	d := gomail.NewDialer(p.Host, p.Port, p.Username, p.Password)
+	d, err := p.newDialer()
+	if err != nil {
+		return nil, err
+	}
 
 	return &MailContext{
 		Config:  p,
@@ -83,6 +167,41 @@ func (p *Config) NewMail(tpl Executer, input interface{}) (*MailContext, error)
 	}, nil
 }
 
+func (p *Config) newDialer() (*gomail.Dialer, error) {
+	d := gomail.NewDialer(p.Host, p.Port, p.Username, p.Password)
+
+	switch p.TLSMode {
+	case TLSModeImplicit:
+		d.SSL = true
+	case TLSModeStartTLS:
+		d.SSL = false
+	}
+
+	if p.CaFile != "" || p.InsecureSkipVerify {
+		d.TLSConfig = &tls.Config{
+			ServerName:         p.Host,
+			InsecureSkipVerify: p.InsecureSkipVerify,
+		}
+
+		if p.CaFile != "" {
+			pool, err := tlsutil.CertPoolFromFile(p.CaFile)
+			if err != nil {
+				return nil, err
+			}
+			d.TLSConfig.RootCAs = pool
+		}
+	}
+
+	switch p.AuthType {
+	case AuthTypePlain:
+		d.Auth = smtp.PlainAuth("", p.Username, p.Password, p.Host)
+	case AuthTypeXOAuth2:
+		d.Auth = &xoauth2Auth{username: p.Username, token: p.Password, tokenSource: p.TokenSource}
+	}
+
+	return d, nil
+}
+
 func (p *MailContext) EmbedBuffer(name string, body []byte) error {
 	tmpFile, err := ioutil.TempFile(p.TmpDir, "mail_")
 	if err != nil {
@@ -108,7 +227,22 @@ func (p *MailContext) DialAndSend() error {
 		}
 	}()
 
-	return p.Dialer.DialAndSend(p.Message)
+	if p.Dkim == nil {
+		return p.Dialer.DialAndSend(p.Message)
+	}
+
+	signer, err := p.Dkim.signer()
+	if err != nil {
+		return err
+	}
+
+	s, err := p.Dialer.Dial()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return gomail.Send(&dkimSender{Sender: s, signer: signer}, p.Message)
 }
 
 func (p *MailContext) SetHeader(field string, value ...string) {
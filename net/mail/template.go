@@ -0,0 +1,112 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	ht "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	tt "text/template"
+)
+
+// Templates is a set of mail templates,
+// `{name}.html` is the html part and `{name}.txt` is the text/plain part,
+// either of them may be omitted.
+type Templates struct {
+	html  map[string]*ht.Template
+	text  map[string]*tt.Template
+	funcs map[string]interface{}
+}
+
+func NewTemplates() *Templates {
+	return &Templates{
+		html: map[string]*ht.Template{},
+		text: map[string]*tt.Template{},
+	}
+}
+
+// Funcs adds the elements of the argument map to the templates' function map,
+// must be called before the templates are parsed
+func (p *Templates) Funcs(funcMap map[string]interface{}) *Templates {
+	p.funcs = funcMap
+	return p
+}
+
+// ParseFS parses the templates from fsys that match the patterns,
+// e.g. ParseFS(os.DirFS("/etc/app/mail"), "*.html", "*.txt")
+func (p *Templates) ParseFS(fsys fs.FS, patterns ...string) error {
+	for _, pattern := range patterns {
+		files, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("mail: pattern matches no files: %#q", pattern)
+		}
+
+		for _, file := range files {
+			b, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return err
+			}
+			if err := p.Add(path.Base(file), string(b)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Add register a template, the file ext of fileName decides the part type
+func (p *Templates) Add(fileName, text string) error {
+	ext := path.Ext(fileName)
+	name := strings.TrimSuffix(fileName, ext)
+
+	switch ext {
+	case ".html", ".htm":
+		tpl, err := ht.New(name).Funcs(p.funcs).Parse(text)
+		if err != nil {
+			return fmt.Errorf("mail: parse %s err: %s", fileName, err)
+		}
+		p.html[name] = tpl
+	case ".txt", ".text", ".tpl":
+		tpl, err := tt.New(name).Funcs(p.funcs).Parse(text)
+		if err != nil {
+			return fmt.Errorf("mail: parse %s err: %s", fileName, err)
+		}
+		p.text[name] = tpl
+	default:
+		return fmt.Errorf("mail: unsupported template file %s", fileName)
+	}
+
+	return nil
+}
+
+// Execute renders the html and text parts of the template
+func (p *Templates) Execute(name string, data interface{}) (html, text string, err error) {
+	htpl, hok := p.html[name]
+	ttpl, tok := p.text[name]
+	if !hok && !tok {
+		return "", "", fmt.Errorf("mail: template %s not found", name)
+	}
+
+	buf := &bytes.Buffer{}
+	if hok {
+		if err = htpl.Execute(buf, data); err != nil {
+			return
+		}
+		html = buf.String()
+	}
+
+	if tok {
+		buf.Reset()
+		if err = ttpl.Execute(buf, data); err != nil {
+			return
+		}
+		text = buf.String()
+	}
+
+	return
+}
@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"welcome.html": {Data: []byte(`<p>Hello {{.Name}}</p>`)},
+		"welcome.txt":  {Data: []byte(`Hello {{.Name}}`)},
+		"notice.txt":   {Data: []byte(`Notice {{.Name}}`)},
+	}
+
+	tpls := NewTemplates()
+	require.NoError(t, tpls.ParseFS(fsys, "*.html", "*.txt"))
+
+	html, text, err := tpls.Execute("welcome", map[string]string{"Name": "<tom>"})
+	require.NoError(t, err)
+	require.Equal(t, "<p>Hello &lt;tom&gt;</p>", html)
+	require.Equal(t, "Hello <tom>", text)
+
+	html, text, err = tpls.Execute("notice", map[string]string{"Name": "tom"})
+	require.NoError(t, err)
+	require.Equal(t, "", html)
+	require.Equal(t, "Notice tom", text)
+
+	_, _, err = tpls.Execute("nonexistent", nil)
+	require.Error(t, err)
+
+	config := &Config{Enabled: true, From: []string{"alex@example.com"}}
+	_, err = config.NewTemplateMail(tpls, "welcome", map[string]string{"Name": "tom"})
+	require.NoError(t, err)
+}
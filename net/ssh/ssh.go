@@ -17,11 +17,11 @@ var (
 type ClientConfig struct {
 	Addr       string
 	User       string
-	PrivateKey string
+	PrivateKey string `datapolicy:"security-key"`
 }
 
 func (p ClientConfig) String() string {
-	return util.Prettify(p)
+	return util.PrettifyRedacted(p)
 }
 
 type Client struct {
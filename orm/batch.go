@@ -0,0 +1,191 @@
+package orm
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const DefaultBatchSize = 500
+
+type batchOptions struct {
+	batchSize int
+}
+
+type BatchOption func(*batchOptions)
+
+// WithBatchSize sets the number of the rows of one insert statement,
+// the args of a statement should not exceed the limit of the driver,
+// e.g. 65535 for postgres, 32766 for sqlite
+func WithBatchSize(n int) BatchOption {
+	return func(o *batchOptions) {
+		o.batchSize = n
+	}
+}
+
+// InsertBatch inserts the samples, a slice of struct or *struct, with
+// the multi-row insert statements in a transaction, the samples are
+// split into the chunks of the batch size.
+// The columns are taken from the first sample, the nil fields are
+// skipped as Insert does, so all the samples must have the same columns.
+func (p *DB) InsertBatch(table string, samples interface{}, opts ...BatchOption) error {
+	o := &batchOptions{batchSize: DefaultBatchSize}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.batchSize <= 0 {
+		return fmt.Errorf("invalid batch size %d", o.batchSize)
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(samples))
+	if rv.Kind() != reflect.Slice {
+		return fmt.Errorf("InsertBatch() samples must be a slice, got %s", rv.Type())
+	}
+	if rv.Len() == 0 {
+		return nil
+	}
+
+	return p.Transaction(p.context(), func(tx *DB) error {
+		for i := 0; i < rv.Len(); i += o.batchSize {
+			end := i + o.batchSize
+			if end > rv.Len() {
+				end = rv.Len()
+			}
+
+			if err := tx.insertBatch(table, rv.Slice(i, end)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (p *DB) insertBatch(table string, rv reflect.Value) error {
+	samples := make([]interface{}, rv.Len())
+	for i := range samples {
+		sample := rv.Index(i)
+		if sample.Kind() == reflect.Struct && sample.CanAddr() {
+			// let the hooks with the pointer receiver modify the sample
+			sample = sample.Addr()
+		}
+		samples[i] = sample.Interface()
+
+		if err := beforeInsert(p, samples[i]); err != nil {
+			return err
+		}
+	}
+
+	if p.driver == "clickhouse" {
+		if err := p.insertPrepared(table, samples); err != nil {
+			return fmt.Errorf("InsertBatch() err: %w", err)
+		}
+	} else {
+		sql, args, err := GenInsertBatchSql(p.driver, table, samples)
+		if err != nil {
+			return err
+		}
+
+		if _, err := p.exec(sql, args...); err != nil {
+			return fmt.Errorf("InsertBatch() err: %w", err)
+		}
+	}
+
+	for _, sample := range samples {
+		if err := afterInsert(p, sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertPrepared inserts the samples with a prepared statement in the
+// transaction, the rows are sent as a block on commit, which is the only
+// way to insert supported by clickhouse
+func (p *DB) insertPrepared(table string, samples []interface{}) error {
+	query, _, err := genInsertSql(p.dialect, table, samples[0])
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	stmt, err := p.tx.PrepareContext(p.context(), query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, sample := range samples {
+		sql, args, err := genInsertSql(p.dialect, table, sample)
+		if err != nil {
+			return err
+		}
+		if sql != query {
+			return fmt.Errorf("insert into %s sample %d has different columns", table, i)
+		}
+
+		args = p.timeArgs(args)
+		if _, err := stmt.ExecContext(p.context(), args...); err != nil {
+			p.log("exec", query, args, start, -1, err)
+			return err
+		}
+	}
+
+	p.log("exec", query, nil, start, int64(len(samples)), nil)
+	return nil
+}
+
+// GenInsertBatchSql generates the multi-row insert statement of the driver, e.g.
+//
+//	mysql:           insert into user (`id`, `name`) values (?, ?), (?, ?)
+//	sqlite/postgres: insert into user ("id", "name") values (?, ?), (?, ?)
+func GenInsertBatchSql(driver, table string, samples []interface{}) (string, []interface{}, error) {
+	d := newDialect(driver)
+	if len(samples) == 0 {
+		return "", nil, fmt.Errorf("insert into %s `samples` is empty", table)
+	}
+
+	buf := &bytes.Buffer{}
+	args := []interface{}{}
+	var keys []string
+
+	for i, sample := range samples {
+		values := []kv{}
+		if err := genInsertValues(reflect.Indirect(reflect.ValueOf(sample)), &values); err != nil {
+			return "", nil, err
+		}
+
+		if i == 0 {
+			if len(values) == 0 {
+				return "", nil, fmt.Errorf("insert into %s `values` is empty", table)
+			}
+
+			buf.WriteString("insert into " + table + " (")
+			for j, v := range values {
+				if j != 0 {
+					buf.WriteString(", ")
+				}
+				buf.WriteString(d.quoteIdent(v.k))
+				keys = append(keys, v.k)
+			}
+			buf.WriteString(") values ")
+		} else {
+			buf.WriteString(", ")
+		}
+
+		if len(values) != len(keys) {
+			return "", nil, fmt.Errorf("insert into %s sample %d has %d columns, expected %d", table, i, len(values), len(keys))
+		}
+
+		for j, v := range values {
+			if v.k != keys[j] {
+				return "", nil, fmt.Errorf("insert into %s sample %d column %s, expected %s", table, i, v.k, keys[j])
+			}
+			args = append(args, v.v)
+		}
+		buf.WriteString("(" + strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ") + ")")
+	}
+
+	return buf.String(), args, nil
+}
@@ -0,0 +1,142 @@
+package orm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yubo/golib/labels"
+)
+
+type bulkOptions struct {
+	allowAll  bool
+	chunkKey  string
+	chunkSize int
+}
+
+// BulkOption is the option of UpdateWhere and DeleteWhere
+type BulkOption func(*bulkOptions)
+
+// WithAllowAll allows the empty selector to update or delete all the rows
+func WithAllowAll() BulkOption {
+	return func(o *bulkOptions) {
+		o.allowAll = true
+	}
+}
+
+// WithChunk splits the statement by the primary key, each statement
+// updates or deletes at most size rows, to avoid the long locks of
+// the large tables, e.g. WithChunk("id", 1000)
+func WithChunk(key string, size int) BulkOption {
+	return func(o *bulkOptions) {
+		o.chunkKey = key
+		o.chunkSize = size
+	}
+}
+
+// UpdateWhere sets the columns of the rows matched by the selector in one
+// statement, e.g.
+//
+//	selector, _ := labels.Parse("status=pending,created_at<100")
+//	n, err := db.UpdateWhere("job", selector, map[string]interface{}{"status": "timeout"})
+//
+// returns the number of the affected rows. The empty selector is refused
+// unless WithAllowAll is set, the soft-deleted rows are not filtered out.
+func (p *DB) UpdateWhere(table string, selector labels.Selector, set map[string]interface{}, opts ...BulkOption) (int64, error) {
+	if len(set) == 0 {
+		return 0, fmt.Errorf("UpdateWhere() of %s has nothing to set", table)
+	}
+
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sets := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, k := range keys {
+		sets[i] = k + "=?"
+		args[i] = set[k]
+	}
+
+	return p.bulkExec("update "+table+" set "+strings.Join(sets, ", "), args, table, selector, opts)
+}
+
+// DeleteWhere deletes the rows matched by the selector in one statement,
+// see UpdateWhere
+func (p *DB) DeleteWhere(table string, selector labels.Selector, opts ...BulkOption) (int64, error) {
+	return p.bulkExec("delete from "+table, nil, table, selector, opts)
+}
+
+func (p *DB) bulkExec(stmt string, args []interface{}, table string, selector labels.Selector, opts []BulkOption) (int64, error) {
+	o := &bulkOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var where string
+	var whereArgs []interface{}
+	if selector == nil || selector.Empty() {
+		if !o.allowAll {
+			return 0, fmt.Errorf("refuse to change all the rows of %s with the empty selector", table)
+		}
+	} else {
+		reqs, selectable := selector.Requirements()
+		if !selectable {
+			return 0, nil
+		}
+		where, whereArgs = reqs.SQL()
+	}
+
+	if o.chunkSize <= 0 {
+		if where != "" {
+			stmt += " where " + where
+			args = append(args, whereArgs...)
+		}
+		return p.execNum(stmt, args...)
+	}
+
+	// select the keys of the chunk, then change the rows of the keys,
+	// the keys are ordered so the rows changed by the selector are skipped
+	var total int64
+	var last interface{}
+	for {
+		conds := []string{}
+		condArgs := []interface{}{}
+		if where != "" {
+			conds = append(conds, where)
+			condArgs = append(condArgs, whereArgs...)
+		}
+		if last != nil {
+			conds = append(conds, o.chunkKey+" > ?")
+			condArgs = append(condArgs, last)
+		}
+
+		query := fmt.Sprintf("select %s from %s", o.chunkKey, table)
+		if len(conds) > 0 {
+			query += " where " + joinConditions(conds)
+		}
+		query += fmt.Sprintf(" order by %s limit %d", o.chunkKey, o.chunkSize)
+
+		var ids []interface{}
+		if err := p.Query(query, condArgs...).Rows(&ids, o.chunkSize); err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		n, err := p.execNum(fmt.Sprintf("%s where %s in (%s)", stmt, o.chunkKey,
+			strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")), append(args[:len(args):len(args)], ids...)...)
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		if len(ids) < o.chunkSize {
+			return total, nil
+		}
+		last = ids[len(ids)-1]
+	}
+}
@@ -0,0 +1,9 @@
+// Package clickhouse registers the "clickhouse" driver, e.g.
+//
+//	db, err := orm.DbOpen("clickhouse", "tcp://127.0.0.1:9000?database=events")
+//
+// the rows are inserted in blocks by Insert and InsertBatch, the
+// transactions, Update and Delete are not supported by clickhouse
+package clickhouse
+
+import _ "github.com/ClickHouse/clickhouse-go"
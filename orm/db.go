@@ -4,14 +4,16 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
-	"encoding/json"
+	sqldriver "database/sql/driver"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/uber-go/tally"
 	"github.com/yubo/golib/api/errors"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/klog/v2"
 )
 
@@ -26,71 +28,111 @@ const (
 )
 
 type session interface {
-	Exec(query string, args ...interface{}) (sql.Result, error)
-	Query(query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 }
 
 type DB struct {
-	greatest string
-	tx       *sql.Tx
-	session  session // sql.DB or sql.Tx
-	DB       *sql.DB // DB
+	driver     string
+	dialect    dialect         // the syntax of the driver
+	savepoint  int             // the depth of the nested transaction
+	spName     string          // the savepoint of the nested transaction begun by Begin
+	ctx        context.Context // the context of the statements, see WithContext
+	logger     Logger
+	metrics    *dbMetrics    // nil if the metrics is disabled
+	slow       time.Duration // the threshold of the slow statements, 0 to disable
+	timeFormat TimeFormat    // the default format of the time fields
+	tx         *sql.Tx
+	stmts      *stmtCache     // nil if the statement cache is disabled
+	replicas   *replicaPool   // nil if there is no replica
+	health     *healthChecker // nil if the health check is disabled
+	tracer     trace.Tracer   // nil if the tracing is disabled
+	session    session        // sql.DB, sql.Tx or stmtSession
+	DB         *sql.DB        // DB
 }
 
-func printString(b []byte) string {
-	s := make([]byte, len(b))
-
-	for i := 0; i < len(b); i++ {
-		if strconv.IsPrint(rune(b[i])) {
-			s[i] = b[i]
-		} else {
-			s[i] = '.'
-		}
-	}
-	return string(s)
+type dbOptions struct {
+	stmtCacheSize  int
+	logger         Logger
+	slowThreshold  time.Duration
+	metrics        tally.Scope
+	replicas       []string
+	replicaPolicy  ReplicaPolicy
+	timeFormat     TimeFormat
+	healthInterval time.Duration
+	healthChange   func(healthy bool, err error)
+	tracer         trace.Tracer
+	sqlite         *SqliteOptions
+	views          []view
 }
 
-func dlog(format string, args ...interface{}) {
-	if klog.V(3).Enabled() {
-		klog.InfoDepth(2, fmt.Sprintf(format, args...))
-	}
-}
+type DBOption func(*dbOptions)
 
-func dlogSql(query string, args ...interface{}) {
-	if klog.V(3).Enabled() {
-		args2 := make([]interface{}, len(args))
+func DbOpen(driverName, dataSourceName string, opts ...DBOption) (*DB, error) {
+	o := &dbOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
 
-		for i := 0; i < len(args2); i++ {
-			rv := reflect.Indirect(reflect.ValueOf(args[i]))
-			if rv.IsValid() && rv.CanInterface() {
-				if b, ok := rv.Interface().([]byte); ok {
-					args2[i] = printString(b)
-				} else {
-					args2[i] = rv.Interface()
-				}
-			}
-		}
-		klog.InfoDepth(2, "\n\t"+fmt.Sprintf(strings.Replace(query, "?", "`%v`", -1), args2...))
+	if o.sqlite != nil && driverName == "sqlite3" {
+		dataSourceName = sqliteDSN(dataSourceName, o.sqlite)
 	}
-}
 
-func DbOpen(driverName, dataSourceName string) (*DB, error) {
 	db, err := sql.Open(driverName, dataSourceName)
 	if err != nil {
 		return nil, err
 	}
 
-	ret := &DB{DB: db, session: db, greatest: "greatest"}
+	ret := &DB{
+		DB:         db,
+		session:    db,
+		driver:     driverName,
+		dialect:    newDialect(driverName),
+		logger:     o.logger,
+		slow:       o.slowThreshold,
+		timeFormat: o.timeFormat,
+		tracer:     o.tracer,
+	}
+	if ret.timeFormat == "" {
+		ret.timeFormat = TimeUnix
+	}
+	if ret.logger == nil {
+		ret.logger = klogLogger{}
+	}
+
+	if len(o.replicas) > 0 {
+		if ret.replicas, err = newReplicaPool(driverName, o.replicaPolicy, o.replicas); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
 
-	if driverName == "sqlite3" {
-		ret.greatest = "max"
+	if o.healthInterval > 0 {
+		ret.health = newHealthChecker(db, o.healthInterval, o.healthChange)
+	}
+
+	if o.metrics != nil {
+		ret.metrics = newDBMetrics(o.metrics, driverName)
+		ret.metrics.run(ret)
+	}
+
+	if o.stmtCacheSize > 0 {
+		ret.stmts = newStmtCache(db, o.stmtCacheSize)
+		ret.session = &stmtSession{cache: ret.stmts}
+	}
+
+	for _, v := range o.views {
+		if err := ret.CreateView(v.name, v.query); err != nil {
+			ret.Close()
+			return nil, fmt.Errorf("create view %s: %s", v.name, err)
+		}
 	}
 
 	return ret, nil
 }
 
-func DbOpenWithCtx(driverName, dsn string, ctx context.Context) (*DB, error) {
-	db, err := DbOpen(driverName, dsn)
+func DbOpenWithCtx(driverName, dsn string, ctx context.Context, opts ...DBOption) (*DB, error) {
+	db, err := DbOpen(driverName, dsn, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -102,28 +144,133 @@ func DbOpenWithCtx(driverName, dsn string, ctx context.Context) (*DB, error) {
 
 	go func() {
 		<-ctx.Done()
-		db.DB.Close()
+		db.Close()
 	}()
 
 	return db, nil
 }
 
+// rebind replaces the '?' placeholders with $1, $2... for the dollar style drivers,
+// the '?' in the quoted strings or identifiers are ignored
+func (p *DB) rebind(query string) string {
+	if !p.dialect.dollar || strings.IndexByte(query, '?') < 0 {
+		return query
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(query)+8))
+	n := 0
+	var quote byte
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			buf.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		buf.WriteByte(c)
+	}
+
+	return buf.String()
+}
+
+// WithContext returns a shallow copy of the DB, the statements of which,
+// including the ones generated by Insert, Update, Query... are run with the ctx
+func (p *DB) WithContext(ctx context.Context) *DB {
+	db := *p
+	db.ctx = ctx
+	return &db
+}
+
+func (p *DB) context() context.Context {
+	if p.ctx != nil {
+		return p.ctx
+	}
+	return context.Background()
+}
+
+// exec runs the statement with the context of the DB, and logs it
+func (p *DB) exec(query string, args ...interface{}) (sql.Result, error) {
+	args = p.timeArgs(args)
+	ctx, span := p.startSpan("exec", query)
+	start := time.Now()
+	res, err := p.session.ExecContext(ctx, p.rebind(query), args...)
+
+	rows := int64(-1)
+	if err == nil {
+		rows, _ = res.RowsAffected()
+	}
+	p.log("exec", query, args, start, rows, err)
+	endSpan(span, rows, err)
+
+	return res, err
+}
+
+// query runs the query with the context of the DB, and logs it,
+// the duration doesn't include the time of scanning
+func (p *DB) query(query string, args ...interface{}) (*sql.Rows, error) {
+	args = p.timeArgs(args)
+	ctx, span := p.startSpan("query", query)
+	start := time.Now()
+	rows, err := p.readSession(query).QueryContext(ctx, p.rebind(query), args...)
+	p.log("query", query, args, start, -1, err)
+	endSpan(span, -1, err)
+
+	return rows, err
+}
+
 func (p *DB) Tx() bool {
 	return p.tx != nil
 }
 
+// BeginWithCtx begins a transaction, if p is already in a transaction,
+// it returns a nested transaction backed by a savepoint, whose Commit
+// releases the savepoint and Rollback rolls back to the savepoint
 func (p *DB) BeginWithCtx(ctx context.Context) (*DB, error) {
 	if p.Tx() {
-		return nil, fmt.Errorf("Already beginning a transaction")
+		db := *p
+		db.ctx = ctx
+		db.savepoint++
+		db.spName = fmt.Sprintf("sp_%d", db.savepoint)
+		if _, err := db.exec("SAVEPOINT " + db.spName); err != nil {
+			return nil, err
+		}
+		return &db, nil
 	}
 	if tx, err := p.DB.BeginTx(ctx, nil); err != nil {
 		return nil, err
 	} else {
-		return &DB{tx: tx, session: tx, greatest: p.greatest}, nil
+		db := p.txDB(tx)
+		db.ctx = ctx
+		return db, nil
 	}
 }
 
+// txDB returns the DB of the transaction with the settings of the driver
+func (p *DB) txDB(tx *sql.Tx) *DB {
+	db := &DB{tx: tx, session: tx, driver: p.driver, dialect: p.dialect, logger: p.logger, metrics: p.metrics, slow: p.slow, timeFormat: p.timeFormat, health: p.health, tracer: p.tracer}
+	if p.stmts != nil {
+		db.stmts = p.stmts
+		db.session = &stmtSession{cache: p.stmts, tx: tx}
+	}
+	return db
+}
+
 func (p *DB) Rollback() error {
+	if p.spName != "" {
+		if _, err := p.exec("ROLLBACK TO SAVEPOINT " + p.spName); err != nil {
+			return err
+		}
+		_, err := p.exec("RELEASE SAVEPOINT " + p.spName)
+		return err
+	}
 	if p.tx != nil {
 		return p.tx.Rollback()
 	}
@@ -131,6 +278,10 @@ func (p *DB) Rollback() error {
 }
 
 func (p *DB) Commit() error {
+	if p.spName != "" {
+		_, err := p.exec("RELEASE SAVEPOINT " + p.spName)
+		return err
+	}
 	if p.tx != nil {
 		return p.tx.Commit()
 	}
@@ -147,20 +298,41 @@ func (p *DB) SetConns(maxIdleConns, maxOpenConns int) {
 }
 
 func (p *DB) Close() {
+	if p.replicas != nil {
+		p.replicas.close()
+	}
+	if p.metrics != nil {
+		p.metrics.stop()
+	}
+	if p.health != nil {
+		p.health.stop()
+	}
+	if p.stmts != nil {
+		p.stmts.close()
+	}
 	p.DB.Close()
 }
 
 func (p *DB) Query(query string, args ...interface{}) *Rows {
-	dlogSql(query, args...)
-	ret := &Rows{}
-	ret.rows, ret.err = p.session.Query(query, args...)
+	return p.queryContext(query, args...)
+}
+
+// QueryContext is like Query, the query is cancelled when the ctx is done
+func (p *DB) QueryContext(ctx context.Context, query string, args ...interface{}) *Rows {
+	return p.WithContext(ctx).queryContext(query, args...)
+}
+
+func (p *DB) queryContext(query string, args ...interface{}) *Rows {
+	ret := &Rows{timeFormat: p.timeFormat}
+	ret.rows, ret.err = p.query(query, args...)
 	return ret
 }
 
 type Rows struct {
-	rows *sql.Rows
-	b    *binder
-	err  error
+	rows       *sql.Rows
+	b          *binder
+	err        error
+	timeFormat TimeFormat // the default format of the time fields
 }
 
 // Row(*int, *int, ...)
@@ -199,12 +371,56 @@ func (p *Rows) scanRow(dst interface{}) error {
 	}
 
 	if err := b.scan(row); err != nil {
-		return fmt.Errorf("rows.scan() err: %s", err)
+		return fmt.Errorf("rows.scan() err: %w", err)
 	}
 
 	return nil
 }
 
+// ForEach scans the rows one by one into dst, and calls fn after each scan,
+// the memory is bounded by one row, e.g. to export a large table.
+// The dst must be a pointer to a struct or a single column value,
+// the struct is reset to zero before scanning. The iteration is stopped
+// if fn returns an error, which is returned by ForEach.
+func (p *Rows) ForEach(dst interface{}, fn func() error) error {
+	if p.err != nil {
+		return p.err
+	}
+	defer p.rows.Close()
+
+	scan := func() error { return p.rows.Scan(dst) }
+
+	if isStructMode(dst) {
+		row := reflect.Indirect(reflect.ValueOf(dst))
+		if !row.CanSet() {
+			return fmt.Errorf("scan target can not be set")
+		}
+
+		b, err := p.genBinder(row.Type())
+		if err != nil {
+			return err
+		}
+
+		zero := reflect.Zero(row.Type())
+		scan = func() error {
+			row.Set(zero)
+			return b.scan(row)
+		}
+	}
+
+	for p.rows.Next() {
+		if err := scan(); err != nil {
+			return fmt.Errorf("rows.scan() err: %w", err)
+		}
+
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	return p.rows.Err()
+}
+
 func (p *Rows) Iter() (RowsIter, error) {
 	if p.err != nil {
 		return nil, p.err
@@ -246,7 +462,7 @@ func (p *Rows) Rows(dst interface{}, opts ...int) error {
 			row := reflect.New(sample).Elem()
 
 			if err := p.rows.Scan(row.Addr().Interface()); err != nil {
-				return fmt.Errorf("rows.scan() err: %s", err)
+				return fmt.Errorf("rows.scan() err: %w", err)
 			}
 
 			rv.Set(reflect.Append(rv, row))
@@ -299,22 +515,23 @@ func rowsInputValue(sample interface{}) (rv reflect.Value, err error) {
 	return rv, nil
 }
 
-func (p *DB) Exec(sql string, args ...interface{}) (sql.Result, error) {
-	dlogSql(sql, args...)
+// ExecContext is like Exec, the statement is cancelled when the ctx is done
+func (p *DB) ExecContext(ctx context.Context, sql string, args ...interface{}) (sql.Result, error) {
+	return p.WithContext(ctx).Exec(sql, args...)
+}
 
-	ret, err := p.session.Exec(sql, args...)
+func (p *DB) Exec(sql string, args ...interface{}) (sql.Result, error) {
+	ret, err := p.exec(sql, args...)
 	if err != nil {
 		klog.V(3).Info(1, err)
-		return nil, fmt.Errorf("Exec() err: %s", err)
+		return nil, fmt.Errorf("Exec() err: %w", err)
 	}
 
 	return ret, nil
 }
 
 func (p *DB) ExecErr(sql string, args ...interface{}) error {
-	dlogSql(sql, args...)
-
-	_, err := p.session.Exec(sql, args...)
+	_, err := p.exec(sql, args...)
 	if err != nil {
 		klog.InfoDepth(1, err)
 	}
@@ -322,17 +539,14 @@ func (p *DB) ExecErr(sql string, args ...interface{}) error {
 }
 
 func (p *DB) ExecLastId(sql string, args ...interface{}) (int64, error) {
-	dlogSql(sql, args...)
-
-	res, err := p.session.Exec(sql, args...)
+	res, err := p.exec(sql, args...)
 	if err != nil {
 		klog.InfoDepth(1, err)
-		return 0, fmt.Errorf("Exec() err: %s", err)
+		return 0, fmt.Errorf("Exec() err: %w", err)
 	}
 
 	if ret, err := res.LastInsertId(); err != nil {
-		dlogSql("%v", err)
-		return 0, fmt.Errorf("LastInsertId() err: %s", err)
+		return 0, fmt.Errorf("LastInsertId() err: %w", err)
 	} else {
 		return ret, nil
 	}
@@ -340,27 +554,23 @@ func (p *DB) ExecLastId(sql string, args ...interface{}) (int64, error) {
 }
 
 func (p *DB) execNum(sql string, args ...interface{}) (int64, error) {
-	res, err := p.session.Exec(sql, args...)
+	res, err := p.exec(sql, args...)
 	if err != nil {
-		dlogSql("%v", err)
-		return 0, fmt.Errorf("Exec() err: %s", err)
+		return 0, fmt.Errorf("Exec() err: %w", err)
 	}
 
 	if ret, err := res.RowsAffected(); err != nil {
-		dlogSql("%v", err)
-		return 0, fmt.Errorf("RowsAffected() err: %s", err)
+		return 0, fmt.Errorf("RowsAffected() err: %w", err)
 	} else {
 		return ret, nil
 	}
 }
 
 func (p *DB) ExecNum(sql string, args ...interface{}) (int64, error) {
-	dlogSql(sql, args...)
 	return p.execNum(sql, args...)
 }
 
 func (p *DB) ExecNumErr(s string, args ...interface{}) error {
-	dlogSql(s, args...)
 	if n, err := p.execNum(s, args...); err != nil {
 		return err
 	} else if n == 0 {
@@ -370,6 +580,54 @@ func (p *DB) ExecNumErr(s string, args ...interface{}) error {
 	}
 }
 
+// ExecExpect returns an error if the number of the affected rows is not n,
+// it's a NotFound StatusError if no row is affected
+func (p *DB) ExecExpect(n int64, sql string, args ...interface{}) error {
+	res, err := p.ExecResult(sql, args...)
+	if err != nil {
+		return err
+	}
+	return res.Expect(n)
+}
+
+// Result is the result of the exec
+type Result struct {
+	RowsAffected int64
+	// LastInsertId is 0 if it isn't supported by the driver, e.g. postgres
+	LastInsertId int64
+}
+
+// Expect returns an error if the number of the affected rows is not n,
+// it's a NotFound StatusError if no row is affected
+func (p *Result) Expect(n int64) error {
+	if p.RowsAffected == n {
+		return nil
+	}
+	if p.RowsAffected == 0 {
+		return errors.NewNotFound("rows")
+	}
+	return fmt.Errorf("expected %d rows affected, got %d", n, p.RowsAffected)
+}
+
+func newResult(res sql.Result) (*Result, error) {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("RowsAffected() err: %w", err)
+	}
+
+	id, _ := res.LastInsertId()
+	return &Result{RowsAffected: n, LastInsertId: id}, nil
+}
+
+// ExecResult is like Exec, returns the typed result
+func (p *DB) ExecResult(sql string, args ...interface{}) (*Result, error) {
+	res, err := p.exec(sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Exec() err: %w", err)
+	}
+	return newResult(res)
+}
+
 func (p *DB) ExecRows(bytes []byte) (err error) {
 	var (
 		cmds []string
@@ -377,7 +635,7 @@ func (p *DB) ExecRows(bytes []byte) (err error) {
 	)
 
 	if tx, err = p.DB.Begin(); err != nil {
-		return fmt.Errorf("Begin() err: %s", err)
+		return fmt.Errorf("Begin() err: %w", err)
 	}
 
 	defer func() {
@@ -430,53 +688,151 @@ func (p *DB) ExecRows(bytes []byte) (err error) {
 }
 
 func (p *DB) Update(table string, sample interface{}) error {
+	_, err := p.UpdateResult(table, sample)
+	return err
+}
+
+// UpdateResult is like Update, returns the number of the updated rows
+func (p *DB) UpdateResult(table string, sample interface{}) (*Result, error) {
+	if err := beforeUpdate(p, sample); err != nil {
+		return nil, err
+	}
+
 	sql, args, err := GenUpdateSql(table, sample)
 	if err != nil {
-		dlog("%v", err)
-		return err
+		return nil, err
+	}
+
+	res, err := p.exec(sql, args...)
+	if err != nil {
+		return nil, err
 	}
 
-	dlogSql(sql, args...)
-	_, err = p.session.Exec(sql, args...)
+	ret, err := newResult(res)
 	if err != nil {
-		dlog("%v", err)
+		return nil, err
+	}
+
+	if err := checkVersion(ret, sample); err != nil {
+		return nil, err
+	}
+
+	return ret, afterUpdate(p, sample)
+}
+
+// ErrStaleObject is returned by Update if the sample has a version field,
+// and the row has been updated or deleted by others since it was read,
+// it's a conflict StatusError, see errors.IsConflict
+var ErrStaleObject = errors.NewConflict("rows", fmt.Errorf("the object has been modified, please reload it and try again"))
+
+// checkVersion returns ErrStaleObject if no row is updated,
+// and increases the version field of the sample on success
+func checkVersion(res *Result, sample interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(sample))
+	fields := cachedTypeFields(rv.Type())
+	if fields.version < 0 {
+		return nil
+	}
+
+	if res.RowsAffected == 0 {
+		return ErrStaleObject
+	}
+
+	if fv, err := getSubv(rv, fields.list[fields.version].index, false); err == nil && fv.CanSet() {
+		fv.SetInt(fv.Int() + 1)
 	}
+	return nil
+}
+
+// Delete deletes the rows matched by the `where` fields of the sample,
+// the rows are marked as deleted instead if the sample has a softdelete field
+func (p *DB) Delete(table string, sample interface{}) error {
+	_, err := p.DeleteResult(table, sample)
 	return err
 }
 
+// DeleteResult is like Delete, returns the number of the deleted rows
+func (p *DB) DeleteResult(table string, sample interface{}) (*Result, error) {
+	if err := beforeDelete(p, sample); err != nil {
+		return nil, err
+	}
+
+	sql, args, err := GenDeleteSql(table, sample)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := p.exec(sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Delete() err: %w", err)
+	}
+	return newResult(res)
+}
+
 func (p *DB) Insert(table string, sample interface{}) error {
-	sql, args, err := GenInsertSql(table, sample)
+	if p.driver == "clickhouse" {
+		return p.InsertBatch(table, []interface{}{sample})
+	}
+
+	if err := beforeInsert(p, sample); err != nil {
+		return err
+	}
+
+	sql, args, err := genInsertSql(p.dialect, table, sample)
 	if err != nil {
 		return err
 	}
 
-	dlogSql(sql, args...)
-	if _, err := p.session.Exec(sql, args...); err != nil {
-		dlog("%v", err)
-		return fmt.Errorf("Insert() err: %s", err)
+	if _, err := p.exec(sql, args...); err != nil {
+		return fmt.Errorf("Insert() err: %w", err)
 	}
-	return nil
+	return afterInsert(p, sample)
 }
 
+// InsertLastId inserts the sample and returns the id of the inserted row,
+// the id is got with `returning` on postgres, which doesn't support
+// LastInsertId, the column is the first `where` field of the sample, or "id"
 func (p *DB) InsertLastId(table string, sample interface{}) (int64, error) {
-	sql, args, err := GenInsertSql(table, sample)
+	if err := beforeInsert(p, sample); err != nil {
+		return 0, err
+	}
+
+	sql, args, err := genInsertSql(p.dialect, table, sample)
 	if err != nil {
 		return 0, err
 	}
 
-	dlogSql(sql, args...)
-	res, err := p.session.Exec(sql, args...)
+	var ret int64
+	if p.dialect.returning {
+		sql += " returning " + p.dialect.quoteIdent(primaryKey(sample))
+		if err := p.queryContext(sql, args...).Row(&ret); err != nil {
+			return 0, fmt.Errorf("Insert() err: %w", err)
+		}
+		return ret, afterInsert(p, sample)
+	}
+
+	res, err := p.exec(sql, args...)
 	if err != nil {
-		dlog("%v", err)
-		return 0, fmt.Errorf("Exec() err: %s", err)
+		return 0, fmt.Errorf("Exec() err: %w", err)
 	}
 
-	if ret, err := res.LastInsertId(); err != nil {
-		dlog("%v", err)
-		return 0, fmt.Errorf("LastInsertId() err: %s", err)
-	} else {
-		return ret, nil
+	ret, err = res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("LastInsertId() err: %w", err)
 	}
+
+	return ret, afterInsert(p, sample)
+}
+
+// primaryKey returns the key of the first `where` field of the sample, or "id"
+func primaryKey(sample interface{}) string {
+	rt := reflect.Indirect(reflect.ValueOf(sample)).Type()
+	for _, f := range cachedTypeFields(rt).list {
+		if f.where {
+			return f.key
+		}
+	}
+	return "id"
 }
 
 // utils
@@ -594,7 +950,13 @@ func genUpdateSql(rv reflect.Value, set, where *[]kv) error {
 	fields := cachedTypeFields(rv.Type())
 	for _, f := range fields.list {
 		fv, err := getSubv(rv, f.index, false)
-		if err != nil || isNil(fv) {
+		if err != nil {
+			continue
+		}
+		if isNil(fv) {
+			if f.nullable && !f.where {
+				*set = append(*set, kv{f.key, nil})
+			}
 			continue
 		}
 
@@ -607,7 +969,13 @@ func genUpdateSql(rv reflect.Value, set, where *[]kv) error {
 			continue
 		}
 
-		v, err := sqlInterface(fv)
+		if f.version {
+			*set = append(*set, kv{f.key, fv.Int() + 1})
+			*where = append(*where, kv{f.key, fv.Int()})
+			continue
+		}
+
+		v, err := sqlInterface(fv, f)
 		if err != nil {
 			return err
 		}
@@ -616,12 +984,65 @@ func genUpdateSql(rv reflect.Value, set, where *[]kv) error {
 	return nil
 }
 
+// GenDeleteSql generates the delete statement with the `where` fields of the sample,
+// e.g. "delete from user where id=?", or "update user set deleted_at=? where id=?
+// and deleted_at is null" if the sample has a field tagged with `sql:",softdelete"`,
+// the deleted_at is set to the current unix time
+func GenDeleteSql(table string, sample interface{}) (string, []interface{}, error) {
+	set := []kv{}
+	where := []kv{}
+
+	rv := reflect.Indirect(reflect.ValueOf(sample))
+
+	if err := genUpdateSql(rv, &set, &where); err != nil {
+		return "", nil, err
+	}
+
+	if len(where) == 0 {
+		return "", nil, fmt.Errorf("delete %s `where` is empty", table)
+	}
+
+	buf := &bytes.Buffer{}
+	args := []interface{}{}
+
+	softDelete := ""
+	if f, ok := softDeleteField(rv.Type()); ok {
+		softDelete = f.key
+		buf.WriteString("update " + table + " set " + softDelete + "=?")
+		args = append(args, softDeleteValue(f))
+	} else {
+		buf.WriteString("delete from " + table)
+	}
+
+	buf.WriteString(" where ")
+	for i, v := range where {
+		if i != 0 {
+			buf.WriteString(" and ")
+		}
+		buf.WriteString(v.k + "=?")
+		args = append(args, v.v)
+	}
+
+	if softDelete != "" {
+		buf.WriteString(" and " + softDelete + " is null")
+	}
+
+	return buf.String(), args, nil
+}
+
+// GenInsertSql generates the insert statement with the columns quoted by
+// the backticks, e.g. "insert into user (`id`, `name`) values (?, ?)",
+// DB.Insert quotes the columns for the driver, see GenUpsertSql
 func GenInsertSql(table string, sample interface{}) (string, []interface{}, error) {
+	return genInsertSql(mysqlDialect, table, sample)
+}
+
+func genInsertSql(d dialect, table string, sample interface{}) (string, []interface{}, error) {
 	values := []kv{}
 
 	rv := reflect.Indirect(reflect.ValueOf(sample))
 
-	if err := genInsertSql(rv, &values); err != nil {
+	if err := genInsertValues(rv, &values); err != nil {
 		return "", nil, err
 	}
 
@@ -640,7 +1061,7 @@ func GenInsertSql(table string, sample interface{}) (string, []interface{}, erro
 			buf.WriteString(", ")
 			buf2.WriteString(", ")
 		}
-		buf.WriteString("`" + v.k + "`")
+		buf.WriteString(d.quoteIdent(v.k))
 		buf2.WriteString("?")
 		args = append(args, v.v)
 	}
@@ -648,11 +1069,17 @@ func GenInsertSql(table string, sample interface{}) (string, []interface{}, erro
 	return buf.String() + ") values (" + buf2.String() + ")", args, nil
 }
 
-func genInsertSql(rv reflect.Value, values *[]kv) error {
+func genInsertValues(rv reflect.Value, values *[]kv) error {
 	fields := cachedTypeFields(rv.Type())
 	for _, f := range fields.list {
 		fv, err := getSubv(rv, f.index, false)
-		if err != nil || isNil(fv) {
+		if err != nil {
+			continue
+		}
+		if isNil(fv) {
+			if f.nullable {
+				*values = append(*values, kv{f.key, nil})
+			}
 			continue
 		}
 
@@ -660,7 +1087,7 @@ func genInsertSql(rv reflect.Value, values *[]kv) error {
 			fv = fv.Elem()
 		}
 
-		v, err := sqlInterface(fv)
+		v, err := sqlInterface(fv, f)
 		if err != nil {
 			return err
 		}
@@ -692,19 +1119,21 @@ func (p *Rows) genBinder(rt reflect.Type) (*binder, error) {
 
 	// klog.V(5).Infof("dest len %d", len(dest))
 	return &binder{
-		fields:   cachedTypeFields(rt),
-		dest:     dest,
-		fieldMap: fieldMap,
-		rows:     p.rows,
+		fields:     cachedTypeFields(rt),
+		dest:       dest,
+		fieldMap:   fieldMap,
+		rows:       p.rows,
+		timeFormat: p.timeFormat,
 	}, nil
 
 }
 
 type binder struct {
-	fields   structFields
-	dest     []interface{}
-	fieldMap map[string]int
-	rows     *sql.Rows
+	fields     structFields
+	dest       []interface{}
+	fieldMap   map[string]int
+	rows       *sql.Rows
+	timeFormat TimeFormat
 }
 
 func (p binder) scan(sample reflect.Value) error {
@@ -714,7 +1143,7 @@ func (p binder) scan(sample reflect.Value) error {
 	}
 
 	if err := p.rows.Scan(p.dest...); err != nil {
-		return fmt.Errorf("Scan() err: %s", err)
+		return fmt.Errorf("Scan() err: %w", err)
 	}
 
 	for _, v := range tran {
@@ -727,13 +1156,28 @@ func (p binder) scan(sample reflect.Value) error {
 }
 
 type transfer struct {
-	dstProxy interface{} // byte
-	dst      interface{} // raw
-	ptr      bool
+	dstProxy   interface{} // byte
+	dst        interface{} // raw
+	ptr        bool
+	null       reflect.Value // **T of the scalar dst, nil if the column is NULL
+	serializer Serializer
+	strict     bool       // return the error of the serializer, it's set by the `serializer` tag
+	timeFormat TimeFormat // the format of the time.Time dst
+	encrypted  bool       // decrypt the data before unmarshaling, see Encryptor
 }
 
-// json -> dst
+// serialized data -> dst
 func (p *transfer) unmarshal() error {
+	if p.null.IsValid() {
+		rv := reflect.ValueOf(p.dst).Elem()
+		if v := p.null.Elem(); v.IsNil() {
+			rv.Set(reflect.Zero(rv.Type()))
+		} else {
+			rv.Set(v.Elem())
+		}
+		return nil
+	}
+
 	if p.dstProxy == nil {
 		return nil
 	}
@@ -746,21 +1190,51 @@ func (p *transfer) unmarshal() error {
 		rv = rv.Elem()
 	}
 
-	// time.Time
-	if i, ok := p.dstProxy.(int64); ok {
-		t := time.Unix(i, 0)
-		if dst, ok := rv.Addr().Interface().(*time.Time); ok {
-			*dst = t
+	if p.timeFormat != "" {
+		t, err := parseTime(p.dstProxy, p.timeFormat)
+		if err != nil {
+			return err
 		}
+		rv.Set(reflect.ValueOf(t))
 		return nil
 	}
 
-	if jsonStr, ok := p.dstProxy.([]byte); ok {
-		if err := json.Unmarshal(jsonStr, rv.Addr().Interface()); err != nil {
-			dlog("json.Unmarshal() error %s", err)
+	var data []byte
+	switch v := p.dstProxy.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+
+	if p.encrypted {
+		var err error
+		if data, err = decryptValue(data); err != nil {
+			return err
+		}
+
+		if p.serializer == nil {
+			switch {
+			case rv.Kind() == reflect.String:
+				rv.SetString(string(data))
+			case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+				rv.SetBytes(data)
+			default:
+				return fmt.Errorf("the encrypted field must be a string, []byte or serialized, got %s", rv.Type())
+			}
+			return nil
 		}
 	}
 
+	if err := p.serializer.Unmarshal(data, rv.Addr().Interface()); err != nil {
+		if p.strict {
+			return err
+		}
+		klog.V(3).Infof("Unmarshal() error %s", err)
+	}
+
 	return nil
 }
 
@@ -772,7 +1246,7 @@ func (p *binder) bind(rv reflect.Value) ([]*transfer, error) {
 			if err != nil {
 				return nil, err
 			}
-			if p.dest[i], err = scanInterface(fv, &tran); err != nil {
+			if p.dest[i], err = scanInterface(fv, f, p.timeFormat, &tran); err != nil {
 				return nil, err
 			}
 		}
@@ -781,17 +1255,40 @@ func (p *binder) bind(rv reflect.Value) ([]*transfer, error) {
 	return tran, nil
 }
 
-// sqlInterface: rv should not be ptr, return interface for use in sql's args
-func sqlInterface(rv reflect.Value) (interface{}, error) {
-	if rv.Type().String() == "time.Time" {
-		return rv.Interface().(time.Time).Unix(), nil
+// sqlInterface: rv should not be ptr, return interface for use in sql's args,
+// the field is marshaled by the serializer of the tag if it's set
+func sqlInterface(rv reflect.Value, f field) (interface{}, error) {
+	if f.encrypted {
+		f.encrypted = false
+		v, err := sqlInterface(rv, f)
+		if err != nil {
+			return nil, err
+		}
+		return encryptValue(v)
+	}
+
+	if f.serializer != "" {
+		s, err := getSerializer(f.serializer)
+		if err != nil {
+			return nil, err
+		}
+		return s.Marshal(rv.Interface())
+	}
+
+	if rv.Type().Implements(valuerType) {
+		// e.g. sql.NullString
+		return rv.Interface(), nil
+	}
+
+	if rv.Type() == timeType {
+		return timeValue{t: rv.Interface().(time.Time), format: f.timeFormat}, nil
 	} else if rv.Kind() == reflect.Struct || rv.Kind() == reflect.Map ||
 		(rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8) {
-		if b, err := json.Marshal(rv.Interface()); err != nil {
+		s, err := getSerializer("json")
+		if err != nil {
 			return nil, err
-		} else {
-			return b, nil
 		}
+		return s.Marshal(rv.Interface())
 	}
 
 	// if rv.Kind() == reflect.Ptr {
@@ -801,8 +1298,10 @@ func sqlInterface(rv reflect.Value) (interface{}, error) {
 	return rv.Interface(), nil
 }
 
-// scanInterface input is struct's field
-func scanInterface(rv reflect.Value, tran *[]*transfer) (interface{}, error) {
+// scanInterface input is struct's field, the column is unmarshaled
+// by the serializer of the tag if it's set, the time is parsed by
+// the time format of the tag, or the timeFormat if it's not set
+func scanInterface(rv reflect.Value, f field, timeFormat TimeFormat, tran *[]*transfer) (interface{}, error) {
 	rt := rv.Type()
 	ptr := false
 
@@ -811,19 +1310,83 @@ func scanInterface(rv reflect.Value, tran *[]*transfer) (interface{}, error) {
 		ptr = true
 	}
 
+	if f.encrypted {
+		node := &transfer{dst: rv.Addr().Interface(), ptr: ptr, encrypted: true, strict: true}
+		if f.serializer != "" {
+			s, err := getSerializer(f.serializer)
+			if err != nil {
+				return nil, err
+			}
+			node.serializer = s
+		}
+		*tran = append(*tran, node)
+		return &node.dstProxy, nil
+	}
+
+	if f.serializer != "" {
+		s, err := getSerializer(f.serializer)
+		if err != nil {
+			return nil, err
+		}
+		node := &transfer{dst: rv.Addr().Interface(), ptr: ptr, serializer: s, strict: true}
+		*tran = append(*tran, node)
+		return &node.dstProxy, nil
+	}
+
+	if rt == timeType {
+		if f.timeFormat != "" {
+			timeFormat = f.timeFormat
+		}
+		node := &transfer{dst: rv.Addr().Interface(), ptr: ptr, timeFormat: timeFormat}
+		*tran = append(*tran, node)
+		return &node.dstProxy, nil
+	}
+
+	if reflect.PtrTo(rt).Implements(scannerType) {
+		// e.g. sql.NullString, *sql.NullString
+		return rv.Addr().Interface(), nil
+	}
+
 	if rt.Kind() == reflect.Struct || rt.Kind() == reflect.Map ||
 		(rt.Kind() == reflect.Slice && rt.Elem().Kind() != reflect.Uint8) {
 		//if rt.Kind() == reflect.Slice || rt.Kind() == reflect.Map || rt.Kind() == reflect.Struct {
 		dst := rv.Addr().Interface()
 		// json decode support *struct{}, but not **struct{}, so should adapt it
-		node := &transfer{dst: dst, ptr: ptr}
+		s, err := getSerializer("json")
+		if err != nil {
+			return nil, err
+		}
+		node := &transfer{dst: dst, ptr: ptr, serializer: s}
 		*tran = append(*tran, node)
 		return &node.dstProxy, nil
 	}
 
+	if !ptr && isScalar(rt.Kind()) {
+		// scan into **T, the NULL is scanned as the zero value
+		node := &transfer{dst: rv.Addr().Interface(), null: reflect.New(reflect.PtrTo(rt))}
+		*tran = append(*tran, node)
+		return node.null.Interface(), nil
+	}
+
 	return rv.Addr().Interface(), nil
 }
 
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*sqldriver.Valuer)(nil)).Elem()
+)
+
+func isScalar(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
 func isNil(rv reflect.Value) bool {
 	switch rv.Kind() {
 	case reflect.Map, reflect.Ptr, reflect.UnsafePointer, reflect.Interface, reflect.Slice:
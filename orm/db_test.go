@@ -1,15 +1,30 @@
 package orm
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	sqldriver "database/sql/driver"
 	"fmt"
 	"os"
+	"reflect"
 	"runtime/debug"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/api/errors"
+	"github.com/yubo/golib/labels"
 	"github.com/yubo/golib/util"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	_ "github.com/yubo/golib/orm/mysql"
 	_ "github.com/yubo/golib/orm/sqlite"
@@ -215,6 +230,35 @@ func TestExecNum(t *testing.T) {
 	})
 }
 
+func TestExecResult(t *testing.T) {
+	type test struct {
+		Id    int64 `sql:",where"`
+		Value int
+	}
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (id integer primary key, value int)")
+
+		res, err := dbt.db.ExecResult("INSERT INTO test (value) VALUES (?)", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, &Result{RowsAffected: 1, LastInsertId: 1}, res)
+		dbt.mustExec("INSERT INTO test (value) VALUES (?)", 1)
+
+		assert.NoError(t, dbt.db.ExecExpect(2, "update test set value=? where value=?", 2, 1))
+		err = dbt.db.ExecExpect(1, "update test set value=?", 3)
+		assert.EqualError(t, err, "expected 1 rows affected, got 2")
+		assert.True(t, errors.IsNotFound(dbt.db.ExecExpect(1, "update test set value=? where id=?", 3, 10)))
+
+		res, err = dbt.db.UpdateResult("test", &test{Id: 1, Value: 4})
+		assert.NoError(t, err)
+		assert.NoError(t, res.Expect(1))
+
+		res, err = dbt.db.DeleteResult("test", &test{Id: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), res.RowsAffected)
+	})
+}
+
 func TestQueryRowStruct(t *testing.T) {
 	runTests(t, dsn, func(dbt *DBTest) {
 		type vt struct {
@@ -643,3 +687,1494 @@ PRIMARY KEY (id)
 	})
 
 }
+
+func TestSetNameMapper(t *testing.T) {
+	type vt struct {
+		UserID int
+		Name   string `sql:",where"`
+	}
+
+	SetNameMapper(util.NewNameMapper(util.SnakeCase))
+	defer SetNameMapper(util.NameMapperFunc(snakeCasedName))
+
+	sql, args, err := GenUpdateSql("vt", vt{1, "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "update vt set user_id=? where name=?", sql)
+	assert.Equal(t, []interface{}{1, "test"}, args)
+}
+
+func TestNamingStrategy(t *testing.T) {
+	type UserGroup struct {
+		GroupID int
+		Name    string `sql:",where"`
+	}
+
+	SetNamingStrategy(Naming{
+		TablePrefix: "t_",
+		Plural:      true,
+		NameMapper:  util.NewNameMapper(util.SnakeCase),
+	})
+	defer SetNamingStrategy(Naming{})
+
+	assert.Equal(t, "t_user_groups", TableName(&UserGroup{}))
+
+	sql, args, err := GenUpdateSql(TableName(UserGroup{}), UserGroup{1, "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "update t_user_groups set group_id=? where name=?", sql)
+	assert.Equal(t, []interface{}{1, "test"}, args)
+
+	for name, want := range map[string]string{
+		"user":   "users",
+		"class":  "classes",
+		"policy": "policies",
+		"key":    "keys",
+		"box":    "boxes",
+	} {
+		assert.Equal(t, want, pluralize(name))
+	}
+}
+
+func TestRebind(t *testing.T) {
+	db := &DB{dialect: newDialect("postgres")}
+
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"select * from t where a=? and b=?", "select * from t where a=$1 and b=$2"},
+		{"select * from t where a='?' and b=?", "select * from t where a='?' and b=$1"},
+		{"insert into t (a, b) values (?, ?)", "insert into t (a, b) values ($1, $2)"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.want, db.rebind(c.query))
+	}
+
+	assert.Equal(t, "select ?", (&DB{}).rebind("select ?"))
+}
+
+func TestDialect(t *testing.T) {
+	type user struct {
+		Id   int64 `sql:",where"`
+		Name string
+	}
+
+	sql, _, err := GenInsertSql("user", user{1, "tom"})
+	assert.NoError(t, err)
+	assert.Equal(t, "insert into user (`id`, `name`) values (?, ?)", sql)
+
+	sql, _, err = genInsertSql(newDialect("postgres"), "user", user{1, "tom"})
+	assert.NoError(t, err)
+	assert.Equal(t, `insert into user ("id", "name") values (?, ?)`, sql)
+
+	assert.Equal(t, "id", primaryKey(&user{}))
+	assert.True(t, newDialect("pgx").returning)
+	assert.False(t, newDialect("mysql").returning)
+}
+
+func TestQueryBuilder(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (name varchar(32), status varchar(32), score int)")
+		dbt.mustExec("INSERT INTO test VALUES (?, ?, ?), (?, ?, ?), (?, ?, ?), (?, ?, ?)",
+			"tom", "active", 1, "tom", "active", 2, "jerry", "active", 3, "bob", "pending", 4)
+
+		selector, err := labels.Parse("status=active;name=bob")
+		assert.NoError(t, err)
+
+		q := NewQuery(dbt.db).Table("test").
+			Select("name", "sum(score) as total").
+			WithSelector(selector).
+			Where("score > ?", 0).
+			GroupBy("name").
+			Having("sum(score) > ?", 2).
+			OrderBy("total desc").
+			Limit(10)
+
+		query, args, err := q.SQL()
+		assert.NoError(t, err)
+		assert.Equal(t, "select name, sum(score) as total from test"+
+			" where (((status = ?) or (name = ?))) and (score > ?)"+
+			" group by name having sum(score) > ? order by total desc limit 10", query)
+		assert.Equal(t, []interface{}{"active", "bob", 0, 2}, args)
+
+		var rows []struct {
+			Name  string
+			Total int
+		}
+		assert.NoError(t, q.Rows(&rows))
+		assert.Equal(t, 3, len(rows))
+		assert.Equal(t, "bob", rows[0].Name)
+		assert.Equal(t, 4, rows[0].Total)
+
+		n, err := q.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), n)
+	})
+}
+
+func TestQueryPagination(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (name varchar(32), status varchar(32), score int)")
+		dbt.mustExec("INSERT INTO test VALUES (?, ?, ?), (?, ?, ?), (?, ?, ?), (?, ?, ?)",
+			"tom", "active", 1, "jerry", "active", 3, "bob", "active", 2, "amy", "pending", 4)
+
+		pg := api.Pagination{Offset: 1, Limit: 2, Sort: "-score,name", Query: "status=active"}
+		q := NewQuery(dbt.db).Table("test").WithPagination(pg)
+
+		query, args, err := q.SQL()
+		assert.NoError(t, err)
+		assert.Equal(t, "select * from test where status = ? order by score desc, name limit 2 offset 1", query)
+		assert.Equal(t, []interface{}{"active"}, args)
+
+		var rows []struct {
+			Name  string
+			Score int
+		}
+		assert.NoError(t, q.Rows(&rows))
+		assert.Equal(t, 2, len(rows))
+		assert.Equal(t, "bob", rows[0].Name)
+		assert.Equal(t, "tom", rows[1].Name)
+
+		// offset without limit
+		q = NewQuery(dbt.db).Table("test").WithPagination(api.Pagination{Offset: 2, Sort: "name"})
+		query, _, err = q.SQL()
+		assert.NoError(t, err)
+		assert.Equal(t, "select * from test order by name limit -1 offset 2", query)
+
+		rows = nil
+		assert.NoError(t, q.Rows(&rows))
+		assert.Equal(t, 2, len(rows))
+		assert.Equal(t, "jerry", rows[0].Name)
+		assert.Equal(t, "tom", rows[1].Name)
+
+		query, _, err = NewQuery(&DB{dialect: newDialect("mysql")}).Table("test").Offset(2).SQL()
+		assert.NoError(t, err)
+		assert.Equal(t, "select * from test limit 18446744073709551615 offset 2", query)
+
+		query, _, err = NewQuery(&DB{dialect: newDialect("postgres")}).Table("test").Offset(2).SQL()
+		assert.NoError(t, err)
+		assert.Equal(t, "select * from test offset 2", query)
+
+		_, _, err = NewQuery(dbt.db).Table("test").WithPagination(api.Pagination{Sort: "name;drop table test"}).SQL()
+		assert.Error(t, err)
+
+		_, _, err = NewQuery(dbt.db).Table("test").WithPagination(api.Pagination{Query: "status in (a"}).SQL()
+		assert.Error(t, err)
+	})
+}
+
+func TestQueryJoin(t *testing.T) {
+	type user struct {
+		Name      string
+		Total     int
+		DeletedAt *int64 `sql:",softdelete"`
+	}
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id int, name varchar(32), status varchar(32), deleted_at int)")
+		dbt.mustExec("CREATE TABLE post (user_id int, score int, deleted_at int)")
+		dbt.mustExec("INSERT INTO user VALUES (?, ?, ?, NULL), (?, ?, ?, NULL), (?, ?, ?, ?)",
+			1, "tom", "active", 2, "jerry", "active", 3, "bob", "active", 1)
+		dbt.mustExec("INSERT INTO post VALUES (1, 1, NULL), (1, 2, NULL), (2, 3, NULL), (3, 4, NULL)")
+
+		selector, err := labels.Parse("u.status=active")
+		assert.NoError(t, err)
+
+		q := NewQuery(dbt.db).Table("user u").
+			Select("u.name", "sum(p.score) as total").
+			Join("left join post p on p.user_id = u.id and p.deleted_at is null").
+			WithSelector(selector).
+			GroupBy("u.name").
+			OrderBy("total desc", "u.name").
+			Model(&user{})
+
+		query, _, err := q.SQL()
+		assert.NoError(t, err)
+		assert.Equal(t, "select u.name, sum(p.score) as total from user u"+
+			" left join post p on p.user_id = u.id and p.deleted_at is null"+
+			" where (u.status = ?) and (u.deleted_at is null)"+
+			" group by u.name order by total desc, u.name", query)
+
+		var rows []user
+		assert.NoError(t, q.Rows(&rows))
+		assert.Equal(t, []user{{Name: "jerry", Total: 3}, {Name: "tom", Total: 3}}, rows)
+
+		n, err := q.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+	})
+}
+
+func TestTransaction(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		ctx := context.Background()
+		errTest := fmt.Errorf("test")
+		dbt.mustExec("CREATE TABLE test (value int)")
+
+		count := func() (n int) {
+			dbt.mustQueryRow(&n, "SELECT count(*) FROM test")
+			return
+		}
+
+		// commit
+		err := dbt.db.Transaction(ctx, func(tx *DB) error {
+			return tx.ExecErr("INSERT INTO test VALUES (?)", 1)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count())
+
+		// rollback
+		err = dbt.db.Transaction(ctx, func(tx *DB) error {
+			tx.ExecErr("INSERT INTO test VALUES (?)", 2)
+			return errTest
+		})
+		assert.Equal(t, errTest, err)
+		assert.Equal(t, 1, count())
+
+		// nested, only the savepoint is rolled back
+		err = dbt.db.Transaction(ctx, func(tx *DB) error {
+			tx.ExecErr("INSERT INTO test VALUES (?)", 3)
+
+			err := tx.Transaction(ctx, func(tx *DB) error {
+				tx.ExecErr("INSERT INTO test VALUES (?)", 4)
+				return errTest
+			})
+			assert.Equal(t, errTest, err)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count())
+
+		// nested in a savepoint, the inner savepoint doesn't release the outer one
+		tx, err := dbt.db.Begin()
+		assert.NoError(t, err)
+		sp, err := tx.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, sp.ExecErr("INSERT INTO test VALUES (?)", 5))
+		err = sp.Transaction(ctx, func(inner *DB) error {
+			assert.NotEqual(t, sp.spName, inner.spName)
+			return inner.ExecErr("INSERT INTO test VALUES (?)", 6)
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, sp.Rollback())
+		assert.NoError(t, tx.Commit())
+		assert.Equal(t, 2, count())
+
+		// retry
+		n := 0
+		err = dbt.db.Transaction(ctx, func(tx *DB) error {
+			if n++; n < 3 {
+				return fmt.Errorf("database is locked")
+			}
+			return nil
+		}, WithTxRetry(util.Backoff{InitialInterval: time.Millisecond, MaxRetries: 5}))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+	})
+}
+
+func TestIsRetryableTxError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&pq.Error{Code: "40001"}, true},
+		{fmt.Errorf("Exec() err: %w", &pq.Error{Code: "40P01"}), true},
+		{&pq.Error{Code: "23505"}, false},
+		{&mysql.MySQLError{Number: 1213}, true},
+		{fmt.Errorf("Insert() err: %w", &mysql.MySQLError{Number: 1205}), true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '40001'"}, false},
+		{fmt.Errorf("database is locked"), true},
+		{fmt.Errorf("Exec() err: %w", fmt.Errorf("database table is locked")), true},
+		{fmt.Errorf("user 40001 not found"), false},
+		{fmt.Errorf("the database is locked by the user 40P01"), false},
+		{nil, false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.want, IsRetryableTxError(c.err), "%v", c.err)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	type user struct {
+		Id        int64 `sql:",where"`
+		Name      string
+		DeletedAt *int64 `sql:",softdelete"`
+	}
+
+	sql, args, err := GenDeleteSql("user", user{Id: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "update user set deleted_at=? where id=? and deleted_at is null", sql)
+	assert.Equal(t, 2, len(args))
+	assert.Equal(t, int64(1), args[1])
+
+	type tag struct {
+		Id   int64 `sql:",where"`
+		Name string
+	}
+	sql, args, err = GenDeleteSql("tag", tag{Id: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "delete from tag where id=?", sql)
+	assert.Equal(t, []interface{}{int64(1)}, args)
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id int, name varchar(32), deleted_at int)")
+		dbt.mustExec("INSERT INTO user (id, name) VALUES (?, ?), (?, ?)", 1, "tom", 2, "jerry")
+
+		assert.NoError(t, dbt.db.Delete("user", user{Id: 1}))
+
+		var rows []user
+		assert.NoError(t, NewQuery(dbt.db).Table("user").Rows(&rows))
+		assert.Equal(t, 1, len(rows))
+		assert.Equal(t, "jerry", rows[0].Name)
+
+		var u user
+		assert.Error(t, NewQuery(dbt.db).Table("user").Where("id = ?", 1).Row(&u))
+
+		rows = nil
+		assert.NoError(t, NewQuery(dbt.db).Table("user").WithDeleted().OrderBy("id").Rows(&rows))
+		assert.Equal(t, 2, len(rows))
+		assert.NotNil(t, rows[0].DeletedAt)
+
+		n, err := NewQuery(dbt.db).Table("user").Model(user{}).Count()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	})
+}
+
+func TestSoftDeleteTimeFormat(t *testing.T) {
+	type rfcUser struct {
+		Id        int64      `sql:",where"`
+		DeletedAt *time.Time `sql:",softdelete,time=rfc3339"`
+	}
+	type milliUser struct {
+		Id        int64      `sql:",where"`
+		DeletedAt *time.Time `sql:",softdelete,time=unixmilli"`
+	}
+	type timeUser struct {
+		Id        int64      `sql:",where"`
+		DeletedAt *time.Time `sql:",softdelete"`
+	}
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE rfc (id int, deleted_at varchar(64))")
+		dbt.mustExec("CREATE TABLE milli (id int, deleted_at bigint)")
+		dbt.mustExec("CREATE TABLE tm (id int, deleted_at int)")
+		for _, table := range []string{"rfc", "milli", "tm"} {
+			dbt.mustExec("INSERT INTO " + table + " (id) VALUES (1)")
+		}
+
+		start := time.Now()
+		assert.NoError(t, dbt.db.Delete("rfc", rfcUser{Id: 1}))
+		assert.NoError(t, dbt.db.Delete("milli", milliUser{Id: 1}))
+		assert.NoError(t, dbt.db.Delete("tm", timeUser{Id: 1}))
+
+		var s string
+		assert.NoError(t, dbt.db.Query("select deleted_at from rfc").Row(&s))
+		deleted, err := time.Parse(time.RFC3339Nano, s)
+		assert.NoError(t, err)
+		assert.WithinDuration(t, start, deleted, time.Minute)
+
+		var n int64
+		assert.NoError(t, dbt.db.Query("select deleted_at from milli").Row(&n))
+		assert.WithinDuration(t, start, time.Unix(0, n*int64(time.Millisecond)), time.Minute)
+
+		// the default format of the DB, unix seconds
+		assert.NoError(t, dbt.db.Query("select deleted_at from tm").Row(&n))
+		assert.WithinDuration(t, start, time.Unix(n, 0), time.Minute)
+
+		var u milliUser
+		assert.Error(t, NewQuery(dbt.db).Table("milli").Row(&u))
+		assert.NoError(t, NewQuery(dbt.db).Table("milli").WithDeleted().Row(&u))
+		if assert.NotNil(t, u.DeletedAt) {
+			assert.WithinDuration(t, start, *u.DeletedAt, time.Minute)
+		}
+	})
+}
+
+type hookUser struct {
+	Id        int64 `sql:",where"`
+	Name      string
+	CreatedBy string
+	UpdatedAt int64
+	inserted  bool
+}
+
+func (p *hookUser) BeforeInsert(db *DB) error {
+	p.CreatedBy = "admin"
+	return nil
+}
+
+func (p *hookUser) AfterInsert(db *DB) error {
+	p.inserted = true
+	return nil
+}
+
+func (p *hookUser) BeforeUpdate(db *DB) error {
+	p.UpdatedAt = 100
+	return nil
+}
+
+func (p *hookUser) BeforeDelete(db *DB) error {
+	if p.Name == "root" {
+		return fmt.Errorf("can not delete %s", p.Name)
+	}
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id int primary key, name varchar(32), created_by varchar(32), updated_at int)")
+
+		u := &hookUser{Id: 1, Name: "tom"}
+		assert.NoError(t, dbt.db.Insert("user", u))
+		assert.True(t, u.inserted)
+
+		u.Name = "jerry"
+		assert.NoError(t, dbt.db.Update("user", u))
+
+		var got hookUser
+		assert.NoError(t, dbt.db.Query("select * from user where id = ?", 1).Row(&got))
+		assert.Equal(t, "jerry", got.Name)
+		assert.Equal(t, "admin", got.CreatedBy)
+		assert.Equal(t, int64(100), got.UpdatedAt)
+
+		assert.Error(t, dbt.db.Delete("user", &hookUser{Id: 1, Name: "root"}))
+		assert.NoError(t, dbt.db.Delete("user", &hookUser{Id: 1}))
+
+		// upsert calls the hooks of both the insert and the update
+		u = &hookUser{Id: 2, Name: "bob"}
+		assert.NoError(t, dbt.db.Upsert("user", u))
+		assert.True(t, u.inserted)
+
+		got = hookUser{}
+		assert.NoError(t, dbt.db.Query("select * from user where id = ?", 2).Row(&got))
+		assert.Equal(t, "admin", got.CreatedBy)
+		assert.Equal(t, int64(100), got.UpdatedAt)
+	})
+}
+
+func TestInsertBatch(t *testing.T) {
+	type user struct {
+		Id   int64
+		Name string
+	}
+
+	sql, args, err := GenInsertBatchSql("mysql", "user", []interface{}{user{1, "tom"}, &user{2, "jerry"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "insert into user (`id`, `name`) values (?, ?), (?, ?)", sql)
+	assert.Equal(t, []interface{}{int64(1), "tom", int64(2), "jerry"}, args)
+
+	sql, _, err = GenInsertBatchSql("postgres", "user", []interface{}{user{1, "tom"}})
+	assert.NoError(t, err)
+	assert.Equal(t, `insert into user ("id", "name") values (?, ?)`, sql)
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id int, name varchar(32))")
+
+		users := []user{}
+		for i := 0; i < 25; i++ {
+			users = append(users, user{int64(i), fmt.Sprintf("user-%d", i)})
+		}
+		assert.NoError(t, dbt.db.InsertBatch("user", users, WithBatchSize(10)))
+
+		var n int
+		assert.NoError(t, dbt.db.Query("select count(*) from user").Row(&n))
+		assert.Equal(t, 25, n)
+
+		// the batch is rolled back if any chunk fails
+		dbt.mustExec("CREATE UNIQUE INDEX user_id ON user (id)")
+		assert.Error(t, dbt.db.InsertBatch("user", []*user{{100, "a"}, {101, "b"}, {1, "c"}}, WithBatchSize(2)))
+		assert.NoError(t, dbt.db.Query("select count(*) from user").Row(&n))
+		assert.Equal(t, 25, n)
+	})
+}
+
+func TestUpsert(t *testing.T) {
+	type user struct {
+		Id    int64 `sql:",where"`
+		Name  string
+		Score int
+	}
+
+	sql, _, err := GenUpsertSql("mysql", "user", user{1, "tom", 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "insert into user (`id`, `name`, `score`) values (?, ?, ?)"+
+		" on duplicate key update `name`=values(`name`), `score`=values(`score`)", sql)
+
+	sql, _, err = GenUpsertSql("postgres", "user", user{1, "tom", 1}, WithUpdateColumns("score"))
+	assert.NoError(t, err)
+	assert.Equal(t, `insert into user ("id", "name", "score") values (?, ?, ?)`+
+		` on conflict ("id") do update set "score"=excluded."score"`, sql)
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id int primary key, name varchar(32), score int)")
+
+		assert.NoError(t, dbt.db.Upsert("user", user{1, "tom", 1}))
+		assert.NoError(t, dbt.db.Upsert("user", user{1, "jerry", 2}, WithUpdateColumns("score")))
+
+		var got user
+		assert.NoError(t, dbt.db.Query("select * from user where id = ?", 1).Row(&got))
+		assert.Equal(t, user{1, "tom", 2}, got)
+
+		var n int
+		assert.NoError(t, dbt.db.Query("select count(*) from user").Row(&n))
+		assert.Equal(t, 1, n)
+	})
+}
+
+func TestStmtCache(t *testing.T) {
+	if !available {
+		t.Skipf("SQL server not running on %s", dsn)
+	}
+
+	db, err := DbOpen(driver, dsn, WithStmtCache(2))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE test (value int)")
+	assert.NoError(t, err)
+	defer db.Exec("DROP TABLE IF EXISTS test")
+
+	for i := 0; i < 3; i++ {
+		_, err = db.Exec("INSERT INTO test VALUES (?)", i)
+		assert.NoError(t, err)
+	}
+
+	// evicts the "CREATE TABLE"
+	var n int
+	assert.NoError(t, db.Query("select count(*) from test").Row(&n))
+	assert.Equal(t, 3, n)
+
+	// evicts the "INSERT"
+	assert.NoError(t, db.Query("select max(value) from test").Row(&n))
+	assert.Equal(t, 2, n)
+
+	assert.NoError(t, db.Transaction(context.Background(), func(tx *DB) error {
+		_, err := tx.Exec("INSERT INTO test VALUES (?)", 3)
+		return err
+	}))
+
+	assert.NoError(t, db.Query("select count(*) from test").Row(&n))
+	assert.Equal(t, 4, n)
+
+	stats := db.StmtCacheStats()
+	assert.Equal(t, 2, stats.Size)
+	assert.Equal(t, uint64(6), stats.Misses)
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(4), stats.Evictions)
+	assert.Equal(t, 0.25, stats.HitRate())
+}
+
+func TestContext(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (value int)")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := dbt.db.ExecContext(ctx, "INSERT INTO test VALUES (?)", 1)
+		assert.NoError(t, err)
+
+		cancel()
+		_, err = dbt.db.ExecContext(ctx, "INSERT INTO test VALUES (?)", 2)
+		assert.Error(t, err)
+
+		var n int
+		assert.Error(t, dbt.db.QueryContext(ctx, "select count(*) from test").Row(&n))
+		assert.Error(t, NewQuery(dbt.db.WithContext(ctx)).Table("test").Row(&n))
+		assert.Error(t, dbt.db.WithContext(ctx).Insert("test", struct{ Value int }{3}))
+
+		assert.NoError(t, dbt.db.Query("select count(*) from test").Row(&n))
+		assert.Equal(t, 1, n)
+	})
+}
+
+type preloadUser struct {
+	Id      int64
+	Name    string
+	Profile *preloadProfile `sql:",has_one,table=profile,fk=user_id"`
+	Orders  []preloadOrder  `sql:",has_many,table=orders,fk=user_id"`
+}
+
+type preloadProfile struct {
+	UserId int64
+	Email  string
+}
+
+type preloadOrder struct {
+	Id     int64
+	UserId int64
+	Item   string
+	User   *preloadUser `sql:",belongs_to,table=user"`
+}
+
+func TestPreload(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id int, name varchar(32))")
+		dbt.mustExec("CREATE TABLE profile (user_id int, email varchar(32))")
+		dbt.mustExec("CREATE TABLE orders (id int, user_id int, item varchar(32))")
+		dbt.mustExec("INSERT INTO user VALUES (1, 'tom'), (2, 'jerry'), (3, 'bob')")
+		dbt.mustExec("INSERT INTO profile VALUES (1, 'tom@example.com')")
+		dbt.mustExec("INSERT INTO orders VALUES (1, 1, 'a'), (2, 1, 'b'), (3, 2, 'c')")
+
+		var users []preloadUser
+		assert.NoError(t, NewQuery(dbt.db).Table("user").OrderBy("id").
+			Preload("Orders", "Profile").Rows(&users))
+		assert.Equal(t, 3, len(users))
+
+		assert.Equal(t, "tom@example.com", users[0].Profile.Email)
+		assert.Equal(t, 2, len(users[0].Orders))
+		assert.Equal(t, "b", users[0].Orders[1].Item)
+
+		assert.Nil(t, users[1].Profile)
+		assert.Equal(t, 1, len(users[1].Orders))
+		assert.Equal(t, 0, len(users[2].Orders))
+
+		var order preloadOrder
+		assert.NoError(t, NewQuery(dbt.db).Table("orders").Where("id = ?", 3).
+			Preload("User").Row(&order))
+		assert.Equal(t, "jerry", order.User.Name)
+
+		assert.Error(t, NewQuery(dbt.db).Table("user").Preload("Foo").Rows(&users))
+
+		// the relation fields are not the columns
+		sql, _, err := GenInsertSql("user", preloadUser{Id: 4, Name: "alice", Orders: []preloadOrder{}})
+		assert.NoError(t, err)
+		assert.Equal(t, "insert into user (`id`, `name`) values (?, ?)", sql)
+	})
+}
+
+func TestOptimisticLock(t *testing.T) {
+	type user struct {
+		Id      int64 `sql:",where"`
+		Name    string
+		Version int64 `sql:",version"`
+	}
+
+	sql, args, err := GenUpdateSql("user", user{1, "tom", 2})
+	assert.NoError(t, err)
+	assert.Equal(t, "update user set name=?, version=? where id=? and version=?", sql)
+	assert.Equal(t, []interface{}{"tom", int64(3), int64(1), int64(2)}, args)
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id int, name varchar(32), version int)")
+		dbt.mustExec("INSERT INTO user VALUES (1, 'tom', 0)")
+
+		var a, b user
+		assert.NoError(t, dbt.db.Query("select * from user where id = ?", 1).Row(&a))
+		assert.NoError(t, dbt.db.Query("select * from user where id = ?", 1).Row(&b))
+
+		a.Name = "jerry"
+		assert.NoError(t, dbt.db.Update("user", &a))
+		assert.Equal(t, int64(1), a.Version)
+
+		b.Name = "bob"
+		err := dbt.db.Update("user", &b)
+		assert.Equal(t, ErrStaleObject, err)
+		assert.True(t, errors.IsConflict(err))
+
+		var got user
+		assert.NoError(t, dbt.db.Query("select * from user where id = ?", 1).Row(&got))
+		assert.Equal(t, user{1, "jerry", 1}, got)
+	})
+}
+
+func TestForEach(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (id int, name varchar(32))")
+		for i := 0; i < 10; i++ {
+			dbt.mustExec("INSERT INTO test VALUES (?, ?)", i, fmt.Sprintf("name-%d", i))
+		}
+
+		var row struct {
+			Id   int
+			Name string
+		}
+		names := []string{}
+		assert.NoError(t, NewQuery(dbt.db).Table("test").OrderBy("id").ForEach(&row, func() error {
+			names = append(names, row.Name)
+			return nil
+		}))
+		assert.Equal(t, 10, len(names))
+		assert.Equal(t, "name-9", names[9])
+
+		// stop on error
+		var id, n int
+		err := dbt.db.Query("select id from test order by id").ForEach(&id, func() error {
+			if n++; id == 2 {
+				return fmt.Errorf("stop")
+			}
+			return nil
+		})
+		assert.EqualError(t, err, "stop")
+		assert.Equal(t, 3, n)
+	})
+}
+
+func TestLogger(t *testing.T) {
+	if !available {
+		t.Skipf("SQL server not running on %s", dsn)
+	}
+
+	var entries []*LogEntry
+	db, err := DbOpen(driver, dsn,
+		WithLogger(LoggerFunc(func(ctx context.Context, e *LogEntry) {
+			entries = append(entries, e)
+		})),
+		WithSlowThreshold(time.Nanosecond))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE test (value int)")
+	assert.NoError(t, err)
+	defer db.Exec("DROP TABLE IF EXISTS test")
+
+	_, err = db.Exec("INSERT INTO test VALUES (?), (?)", 1, 2)
+	assert.NoError(t, err)
+
+	var n int
+	assert.NoError(t, db.Query("select count(*) from test").Row(&n))
+	assert.Error(t, db.Query("select * from foo").Row(&n))
+
+	assert.Equal(t, 4, len(entries))
+	assert.Equal(t, "INSERT INTO test VALUES (?), (?)", entries[1].SQL)
+	assert.Equal(t, []interface{}{1, 2}, entries[1].Args)
+	assert.Equal(t, int64(2), entries[1].Rows)
+	assert.True(t, entries[1].Slow)
+	assert.Equal(t, int64(-1), entries[2].Rows)
+	assert.Error(t, entries[3].Err)
+
+	assert.Equal(t, "select `1`, `a.b`", formatSql("select ?, ?", []interface{}{1, []byte("a\nb")}))
+}
+
+func TestMetrics(t *testing.T) {
+	if !available {
+		t.Skipf("SQL server not running on %s", dsn)
+	}
+
+	scope := tally.NewTestScope("", nil)
+	db, err := DbOpen(driver, dsn, WithMetrics(scope))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE test (value int)")
+	assert.NoError(t, err)
+	defer db.Exec("DROP TABLE IF EXISTS test")
+
+	var n int
+	assert.NoError(t, db.Query("select count(*) from test").Row(&n))
+	assert.Error(t, db.Query("select * from foo").Row(&n))
+
+	counters := scope.Snapshot().Counters()
+	assert.Equal(t, int64(1), counters["orm_queries_total+driver="+driver+",type=exec"].Value())
+	assert.Equal(t, int64(2), counters["orm_queries_total+driver="+driver+",type=query"].Value())
+	assert.Equal(t, int64(1), counters["orm_errors_total+driver="+driver+",type=query"].Value())
+
+	histograms := scope.Snapshot().Histograms()
+	assert.NotNil(t, histograms["orm_duration_seconds+driver="+driver+",type=query"])
+}
+
+func TestHealthCheck(t *testing.T) {
+	if !available {
+		t.Skipf("SQL server not running on %s", dsn)
+	}
+
+	changes := make(chan bool, 1)
+	db, err := DbOpen(driver, dsn, WithHealthCheck(10*time.Millisecond, func(healthy bool, err error) {
+		changes <- healthy
+	}))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	assert.True(t, db.Healthy())
+
+	// e.g. the server is gone
+	db.DB.Close()
+	select {
+	case healthy := <-changes:
+		assert.False(t, healthy)
+	case <-time.After(time.Second):
+		t.Fatal("the health is not changed")
+	}
+	assert.False(t, db.Healthy())
+}
+
+func TestTracing(t *testing.T) {
+	if !available {
+		t.Skipf("SQL server not running on %s", dsn)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	db, err := DbOpen(driver, dsn, WithTracing(tp))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE test (value int, name varchar(8))")
+	assert.NoError(t, err)
+	defer db.Exec("DROP TABLE IF EXISTS test")
+
+	assert.NoError(t, db.Transaction(context.Background(), func(tx *DB) error {
+		_, err := tx.Exec("INSERT INTO test VALUES (?, 'tom'),\n (?, ?)", 1, 2, "jerry")
+		return err
+	}))
+
+	var n int
+	assert.NoError(t, db.Query("select count(*) from test").Row(&n))
+
+	spans := recorder.Ended()
+	assert.Equal(t, 4, len(spans))
+
+	insert := spans[1]
+	assert.Equal(t, "orm.exec", insert.Name())
+	assert.Equal(t, spans[2].SpanContext().SpanID(), insert.Parent().SpanID())
+	assert.Contains(t, insert.Attributes(), attribute.String("db.system", "sqlite"))
+	assert.Contains(t, insert.Attributes(), attribute.String("db.statement", "INSERT INTO test VALUES (?, '?'), (?, ?)"))
+	assert.Contains(t, insert.Attributes(), attribute.Int64("db.rows_affected", 2))
+
+	assert.Equal(t, "orm.transaction", spans[2].Name())
+	assert.Equal(t, "orm.query", spans[3].Name())
+}
+
+func TestReplicas(t *testing.T) {
+	if driver != "sqlite3" {
+		t.Skip("the replicas are tested with sqlite3")
+	}
+
+	replicas := []string{
+		"file:replica1.db?cache=shared&mode=memory",
+		"file:replica2.db?cache=shared&mode=memory",
+	}
+
+	db, err := DbOpen(driver, "file:primary.db?cache=shared&mode=memory", WithReplicas(RoundRobin, replicas...))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	for i, dsn := range append([]string{"file:primary.db?cache=shared&mode=memory"}, replicas...) {
+		// keep the in-memory database alive
+		conn, err := DbOpen(driver, dsn)
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Exec("CREATE TABLE test (name varchar(32))")
+		assert.NoError(t, err)
+		_, err = conn.Exec("INSERT INTO test VALUES (?)", fmt.Sprintf("db-%d", i))
+		assert.NoError(t, err)
+	}
+
+	names := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		var name string
+		assert.NoError(t, db.Query("select name from test").Row(&name))
+		names[name] = true
+	}
+	assert.Equal(t, map[string]bool{"db-1": true, "db-2": true}, names)
+
+	var name string
+	assert.NoError(t, db.Primary().Query("select name from test").Row(&name))
+	assert.Equal(t, "db-0", name)
+
+	assert.NoError(t, db.Transaction(context.Background(), func(tx *DB) error {
+		return tx.Query("select name from test").Row(&name)
+	}))
+	assert.Equal(t, "db-0", name)
+
+	// evict the broken replica
+	db.replicas.replicas[0].db.Close()
+	db.replicas.check()
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, db.Query("select name from test").Row(&name))
+		assert.Equal(t, "db-2", name)
+	}
+
+	assert.True(t, isReadOnly(" SELECT * FROM test"))
+	assert.False(t, isReadOnly("select * from test for update"))
+	assert.False(t, isReadOnly("insert into test values (1)"))
+}
+
+func TestInsertPrepared(t *testing.T) {
+	type user struct {
+		Id   int64
+		Name string
+	}
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id int, name varchar(32))")
+
+		// the inserts of clickhouse are sent by the prepared statement
+		db := *dbt.db
+		db.driver = "clickhouse"
+
+		assert.NoError(t, db.Insert("user", &user{1, "tom"}))
+		assert.NoError(t, db.InsertBatch("user", []user{{2, "jerry"}, {3, "bob"}}, WithBatchSize(1)))
+
+		var n int
+		assert.NoError(t, db.Query("select count(*) from user").Row(&n))
+		assert.Equal(t, 3, n)
+	})
+}
+
+func TestIndexes(t *testing.T) {
+	type user struct {
+		Name  string `index:""`
+		Email string `index:"uk_user_email,unique"`
+		Dept  string `index:"idx_user_org,composite:org_id"`
+		OrgId int64
+		Title string `index:"idx_user_org"`
+	}
+
+	indexes, err := Indexes("user", &user{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Index{
+		{Name: "idx_user_name", Columns: []string{"name"}},
+		{Name: "uk_user_email", Unique: true, Columns: []string{"email"}},
+		{Name: "idx_user_org", Columns: []string{"dept", "org_id", "title"}},
+	}, indexes)
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (name varchar(32), email varchar(32), dept varchar(32), org_id int, title varchar(32))")
+
+		stmts, err := GenCreateIndexSql("user", user{})
+		assert.NoError(t, err)
+		assert.Equal(t, "create unique index uk_user_email on user (email)", stmts[1])
+		for _, stmt := range stmts {
+			dbt.mustExec(stmt)
+		}
+
+		assert.NoError(t, dbt.db.Insert("user", user{Email: "a"}))
+		assert.Error(t, dbt.db.Insert("user", user{Email: "a"}))
+	})
+}
+
+func TestForeignKeys(t *testing.T) {
+	type order struct {
+		Id     int64
+		UserId int64 `references:"user(id),ondelete:cascade"`
+	}
+
+	stmts, err := GenForeignKeySql("mysql", "orders", order{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alter table orders add constraint fk_orders_user_id" +
+		" foreign key (user_id) references user (id) on delete cascade"}, stmts)
+
+	_, err = GenForeignKeySql("sqlite3", "orders", order{})
+	assert.Error(t, err)
+
+	type bad struct {
+		UserId int64 `references:"user(id),ondelete:drop"`
+	}
+	_, err = ForeignKeys("orders", bad{})
+	assert.Error(t, err)
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		constraints, err := ForeignKeyConstraints("orders", order{})
+		assert.NoError(t, err)
+
+		// the pragma is set per connection
+		dbt.db.SetConns(1, 1)
+		dbt.mustExec("PRAGMA foreign_keys = ON")
+		dbt.mustExec("CREATE TABLE user (id int primary key)")
+		dbt.mustExec("CREATE TABLE orders (id int, user_id int, " + strings.Join(constraints, ", ") + ")")
+		dbt.mustExec("INSERT INTO user VALUES (1)")
+		dbt.mustExec("INSERT INTO orders VALUES (1, 1)")
+
+		assert.Error(t, dbt.db.Insert("orders", order{2, 2}))
+
+		dbt.mustExec("DELETE FROM user WHERE id = 1")
+		var n int
+		assert.NoError(t, dbt.db.Query("select count(*) from orders").Row(&n))
+		assert.Equal(t, 0, n)
+	})
+}
+
+func TestListPage(t *testing.T) {
+	type user struct {
+		Id   int64
+		Name string
+	}
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id int, name varchar(32))")
+		for i := 1; i <= 5; i++ {
+			dbt.mustExec("INSERT INTO user VALUES (?, ?)", i, fmt.Sprintf("user-%d", i))
+		}
+
+		q := NewQuery(dbt.db).Table("user").Where("id > ?", 0)
+
+		var users []user
+		page, err := q.OrderBy("id").ListPage(&users, PageOptions{Limit: 2, Offset: 2, WithTotal: true})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), page.Total)
+		assert.Equal(t, []user{{3, "user-3"}, {4, "user-4"}}, users)
+		assert.Equal(t, "", page.NextCursor)
+
+		// keyset
+		q = NewQuery(dbt.db).Table("user").Where("id > ?", 0)
+		ids := []int64{}
+		opts := PageOptions{Limit: 2, CursorKey: "id", Desc: true}
+		for i := 0; i < 5; i++ {
+			users = nil
+			page, err := q.ListPage(&users, opts)
+			assert.NoError(t, err)
+			for _, u := range users {
+				ids = append(ids, u.Id)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []int64{5, 4, 3, 2, 1}, ids)
+
+		_, err = q.ListPage(&users, PageOptions{Limit: 2, CursorKey: "id", Cursor: "!"})
+		assert.Error(t, err)
+	})
+}
+
+func TestNullScan(t *testing.T) {
+	type user struct {
+		Id      int64 `sql:",where"`
+		Name    string
+		Age     *int64
+		Email   sql.NullString
+		Phone   *sql.NullString
+		Created *time.Time
+		Note    *string `sql:",nullable"`
+	}
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id int, name varchar(32), age int, email varchar(32), phone varchar(32), created int, note varchar(32))")
+		dbt.mustExec("INSERT INTO user (id) VALUES (1)")
+
+		var got user
+		assert.NoError(t, dbt.db.Query("select * from user where id = ?", 1).Row(&got))
+		assert.Equal(t, user{Id: 1, Phone: nil}, got)
+
+		note := "note"
+		now := time.Unix(time.Now().Unix(), 0)
+		assert.NoError(t, dbt.db.Update("user", &user{
+			Id:      1,
+			Name:    "tom",
+			Age:     util.Int64(10),
+			Email:   sql.NullString{String: "tom@example.com", Valid: true},
+			Phone:   &sql.NullString{String: "123", Valid: true},
+			Created: &now,
+			Note:    &note,
+		}))
+
+		assert.NoError(t, dbt.db.Query("select * from user where id = ?", 1).Row(&got))
+		assert.Equal(t, int64(10), *got.Age)
+		assert.Equal(t, "tom@example.com", got.Email.String)
+		assert.Equal(t, "123", got.Phone.String)
+		assert.Equal(t, now, *got.Created)
+		assert.Equal(t, "note", *got.Note)
+
+		// the nil nullable field is written as NULL
+		assert.NoError(t, dbt.db.Update("user", &user{Id: 1, Name: "tom", Email: sql.NullString{}}))
+		var n int
+		assert.NoError(t, dbt.db.Query("select count(*) from user where note is null and email is null and age = 10").Row(&n))
+		assert.Equal(t, 1, n)
+	})
+}
+
+func TestSerializer(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	type user struct {
+		Id     int64                 `sql:",where"`
+		Tags   []string              `serializer:"csv"`
+		Point  point                 `serializer:"gob"`
+		Nick   *wrappers.StringValue `serializer:"proto"`
+		Labels map[string]string
+	}
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id int, tags varchar(64), point blob, nick blob, labels varchar(64))")
+
+		assert.NoError(t, dbt.db.Insert("user", &user{
+			Id:     1,
+			Tags:   []string{"a", "b,c"},
+			Point:  point{1, 2},
+			Nick:   &wrappers.StringValue{Value: "tom"},
+			Labels: map[string]string{"k": "v"},
+		}))
+
+		var tags string
+		assert.NoError(t, dbt.db.Query("select tags from user where id = ?", 1).Row(&tags))
+		assert.Equal(t, `a,"b,c"`, tags)
+
+		var got user
+		assert.NoError(t, dbt.db.Query("select * from user where id = ?", 1).Row(&got))
+		assert.Equal(t, []string{"a", "b,c"}, got.Tags)
+		assert.Equal(t, point{1, 2}, got.Point)
+		assert.Equal(t, "tom", got.Nick.GetValue())
+		assert.Equal(t, map[string]string{"k": "v"}, got.Labels)
+
+		// the error of the serializer is returned
+		dbt.mustExec("UPDATE user SET point = 'x' WHERE id = 1")
+		assert.Error(t, dbt.db.Query("select * from user where id = ?", 1).Row(&got))
+
+		type bad struct {
+			Id   int64    `sql:",where"`
+			Tags []string `serializer:"unknown"`
+		}
+		assert.Error(t, dbt.db.Insert("user", &bad{Id: 2, Tags: []string{"a"}}))
+	})
+}
+
+func TestTimeFormat(t *testing.T) {
+	if !available {
+		t.Skipf("SQL server not running on %s", dsn)
+	}
+
+	type user struct {
+		Id      int64 `sql:",where"`
+		Created time.Time
+		Updated time.Time  `sql:",time=unixmilli"`
+		Expired *time.Time `sql:",time=datetime"`
+	}
+
+	db, err := DbOpen(driver, dsn, WithTimeFormat(TimeRFC3339))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE user (id int, created varchar(64), updated bigint, expired datetime(6))")
+	assert.NoError(t, err)
+	defer db.Exec("DROP TABLE IF EXISTS user")
+
+	now := time.Unix(1600000000, 123456789)
+	assert.NoError(t, db.Insert("user", &user{Id: 1, Created: now, Updated: now, Expired: &now}))
+
+	var created string
+	var updated int64
+	assert.NoError(t, db.Query("select created, updated from user where id = ?", 1).Row(&created, &updated))
+	assert.Equal(t, "2020-09-13T12:26:40.123456789Z", created)
+	assert.Equal(t, int64(1600000000123), updated)
+
+	var got user
+	assert.NoError(t, db.Query("select * from user where id = ?", 1).Row(&got))
+	assert.True(t, now.Equal(got.Created))
+	assert.True(t, now.Truncate(time.Millisecond).Equal(got.Updated))
+	assert.True(t, now.Truncate(time.Microsecond).Equal(*got.Expired))
+
+	// the default format of GenInsertSql is the unix seconds
+	_, args, err := GenInsertSql("user", &user{Id: 2, Created: now})
+	assert.NoError(t, err)
+	v, err := args[1].(sqldriver.Valuer).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1600000000), v)
+}
+
+func TestEmbeddedPrefix(t *testing.T) {
+	type address struct {
+		City   string `index:""`
+		Street string
+	}
+	type money struct {
+		Amount   int64
+		Currency string
+	}
+	type user struct {
+		Id      int64    `sql:",where"`
+		Home    address  `sql:",embedded,prefix=home_"`
+		Work    *address `sql:",embedded,prefix=work_"`
+		Balance money    `sql:",embedded"`
+	}
+
+	indexes, err := Indexes("user", &user{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Index{
+		{Name: "idx_user_home_city", Columns: []string{"home_city"}},
+		{Name: "idx_user_work_city", Columns: []string{"work_city"}},
+	}, indexes)
+
+	assert.Panics(t, func() {
+		type dup struct {
+			Home address `sql:",embedded"`
+			Work address `sql:",embedded"`
+		}
+		cachedTypeFields(reflect.TypeOf(dup{}))
+	})
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id int, home_city varchar(32), home_street varchar(32), " +
+			"work_city varchar(32), work_street varchar(32), amount int, currency varchar(8))")
+
+		u := user{
+			Id:      1,
+			Home:    address{"a", "b"},
+			Work:    &address{"c", "d"},
+			Balance: money{100, "usd"},
+		}
+		assert.NoError(t, dbt.db.Insert("user", &u))
+
+		var city string
+		assert.NoError(t, dbt.db.Query("select work_city from user where id = ?", 1).Row(&city))
+		assert.Equal(t, "c", city)
+
+		var got user
+		assert.NoError(t, dbt.db.Query("select * from user where id = ?", 1).Row(&got))
+		assert.Equal(t, u, got)
+
+		u.Home.City = "e"
+		assert.NoError(t, dbt.db.Update("user", &u))
+		assert.NoError(t, dbt.db.Query("select home_city from user where id = ?", 1).Row(&city))
+		assert.Equal(t, "e", city)
+	})
+}
+
+func TestSqlitePragmas(t *testing.T) {
+	if driver != "sqlite3" {
+		t.Skipf("driver %s is not sqlite3", driver)
+	}
+
+	// the params in the dsn take precedence
+	assert.Equal(t, "file:test.db?_synchronous=FULL&_busy_timeout=100&_journal_mode=WAL",
+		sqliteDSN("file:test.db?_synchronous=FULL", &SqliteOptions{
+			JournalMode: "WAL",
+			BusyTimeout: 100 * time.Millisecond,
+			Synchronous: "NORMAL",
+		}))
+	assert.Equal(t, "test.db", sqliteDSN("test.db", &SqliteOptions{}))
+
+	db, err := DbOpen(driver, t.TempDir()+"/test.db", WithSqlite(SqliteOptions{
+		JournalMode: "WAL",
+		BusyTimeout: 5 * time.Second,
+		ForeignKeys: true,
+		Synchronous: "NORMAL",
+	}))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	var mode string
+	var timeout, fk, sync int
+	assert.NoError(t, db.Query("PRAGMA journal_mode").Row(&mode))
+	assert.NoError(t, db.Query("PRAGMA busy_timeout").Row(&timeout))
+	assert.NoError(t, db.Query("PRAGMA foreign_keys").Row(&fk))
+	assert.NoError(t, db.Query("PRAGMA synchronous").Row(&sync))
+	assert.Equal(t, "wal", mode)
+	assert.Equal(t, 5000, timeout)
+	assert.Equal(t, 1, fk)
+	assert.Equal(t, 1, sync)
+}
+
+func TestValidate(t *testing.T) {
+	type Valid struct {
+		Id        int64      `sql:",where"`
+		Name      string     `sql:"user_name"`
+		Tags      []string   `serializer:"csv"`
+		CreatedAt time.Time  `sql:",time=unixmilli"`
+		DeletedAt *time.Time `sql:",softdelete"`
+	}
+	assert.NoError(t, Validate(&Valid{}))
+
+	type Dup struct {
+		Name  string
+		Name2 string `sql:"name"`
+	}
+
+	type Invalid struct {
+		Order     int
+		Name      string    `sql:",wehre"`
+		Age       int       `sql:",nullable"`
+		CreatedAt int64     `sql:",time=unix"`
+		UpdatedAt time.Time `sql:",time=unixnano"`
+		Data      []byte    `serializer:"xml"`
+		DeletedAt int64     `sql:",softdelete"`
+	}
+
+	assert.Error(t, Validate(Dup{}))
+	assert.Error(t, Validate(1))
+
+	err := Validate(Invalid{})
+	assert.Error(t, err)
+	for _, s := range []string{
+		`Invalid.Order: column "order" is a reserved word`,
+		`Invalid.Name: unknown sql tag option "wehre"`,
+		`Invalid.Age: nullable field`,
+		`Invalid.CreatedAt: time format "unix" on the non-time field`,
+		`Invalid.UpdatedAt: unsupported time format "unixnano"`,
+		`Invalid.Data: `,
+		`Invalid.DeletedAt: softdelete field`,
+	} {
+		assert.Contains(t, err.Error(), s)
+	}
+}
+
+func TestView(t *testing.T) {
+	if !available {
+		t.Skipf("sqlite3 is not available")
+	}
+
+	type UserStat struct {
+		Name  string
+		Count int
+	}
+
+	dsn := t.TempDir() + "/test.db"
+	db, err := DbOpen(driver, dsn)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	assert.NoError(t, db.ExecErr("create table user (id integer primary key, name text)"))
+	assert.NoError(t, db.ExecErr("create table event (user_id int)"))
+	assert.NoError(t, db.ExecErr("insert into user values (1, 'tom'), (2, 'jerry')"))
+	assert.NoError(t, db.ExecErr("insert into event values (1), (1), (2)"))
+
+	query := "select u.name, count(*) as count from user u join event e on e.user_id = u.id group by u.name"
+	db2, err := DbOpen(driver, dsn, WithView("user_stat", query))
+	assert.NoError(t, err)
+	defer db2.Close()
+
+	var stats []UserStat
+	assert.NoError(t, db2.Query("select * from user_stat order by name").Rows(&stats))
+	assert.Equal(t, []UserStat{{"jerry", 1}, {"tom", 2}}, stats)
+
+	// replace the existing view
+	assert.NoError(t, db.CreateView("user_stat", query+" having count(*) > 1"))
+	stats = nil
+	assert.NoError(t, db.Query("select * from user_stat").Rows(&stats))
+	assert.Equal(t, []UserStat{{"tom", 2}}, stats)
+
+	ok, err := db.HasView("user_stat")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	tables, err := db.GetTables()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"event", "user"}, tables)
+
+	tables, err = db.GetTablesWithViews()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"event", "user", "user_stat"}, tables)
+
+	assert.NoError(t, db.DropView("user_stat"))
+	ok, err = db.HasView("user_stat")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestEncrypted(t *testing.T) {
+	type Profile struct {
+		Address string
+	}
+	type User struct {
+		Id      int64    `sql:",where"`
+		Phone   string   `sql:",encrypted"`
+		Secret  []byte   `sql:",encrypted"`
+		Profile *Profile `sql:",encrypted" serializer:"json"`
+	}
+
+	keys := map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}
+	SetEncryptor(NewAESEncryptor(NewStaticKMS("k1", keys)))
+	defer SetEncryptor(nil)
+
+	assert.NoError(t, Validate(User{}))
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE user (id integer primary key, phone text, secret blob, profile text)")
+
+		user := User{Id: 1, Phone: "123456", Secret: []byte("s"), Profile: &Profile{"earth"}}
+		assert.NoError(t, dbt.db.Insert("user", &user))
+
+		var phone string
+		assert.NoError(t, dbt.db.Query("select phone from user where id = 1").Row(&phone))
+		assert.True(t, strings.HasPrefix(phone, "k1:"))
+		assert.NotContains(t, phone, "123456")
+
+		// rotate the key, the old rows are still readable
+		keys["k2"] = bytes.Repeat([]byte{2}, 32)
+		SetEncryptor(NewAESEncryptor(NewStaticKMS("k2", keys)))
+		assert.NoError(t, dbt.db.Insert("user", &User{Id: 2, Phone: "654321"}))
+
+		var users []User
+		assert.NoError(t, dbt.db.Query("select * from user order by id").Rows(&users))
+		assert.Equal(t, []User{user, {Id: 2, Phone: "654321"}}, users)
+
+		delete(keys, "k1")
+		assert.Error(t, dbt.db.Query("select * from user where id = 1").Row(&user))
+	})
+}
+
+func TestBulkWhere(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE job (id integer primary key, status text)")
+		for i := 0; i < 10; i++ {
+			status := "pending"
+			if i%2 == 1 {
+				status = "running"
+			}
+			dbt.mustExec("INSERT INTO job (status) VALUES (?)", status)
+		}
+
+		count := func(status string) (n int) {
+			assert.NoError(t, dbt.db.Query("select count(*) from job where status = ?", status).Row(&n))
+			return
+		}
+
+		// the empty selector is refused by default
+		_, err := dbt.db.UpdateWhere("job", labels.Everything(), map[string]interface{}{"status": "done"})
+		assert.Error(t, err)
+		_, err = dbt.db.DeleteWhere("job", nil)
+		assert.Error(t, err)
+
+		selector, err := labels.Parse("status=pending")
+		assert.NoError(t, err)
+		n, err := dbt.db.UpdateWhere("job", selector, map[string]interface{}{"status": "timeout"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), n)
+		assert.Equal(t, 5, count("timeout"))
+
+		// chunked by the primary key
+		selector, err = labels.Parse("status in (timeout, running)")
+		assert.NoError(t, err)
+		n, err = dbt.db.UpdateWhere("job", selector, map[string]interface{}{"status": "done"}, WithChunk("id", 3))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), n)
+		assert.Equal(t, 10, count("done"))
+
+		n, err = dbt.db.DeleteWhere("job", nil, WithAllowAll(), WithChunk("id", 4))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), n)
+		assert.Equal(t, 0, count("done"))
+	})
+}
+
+func TestNestedBegin(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (value int)")
+
+		count := func(db *DB) (n int) {
+			assert.NoError(t, db.Query("select count(*) from test").Row(&n))
+			return
+		}
+
+		tx, err := dbt.db.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, tx.ExecErr("INSERT INTO test VALUES (1)"))
+
+		// rollback to the savepoint
+		sp, err := tx.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, sp.ExecErr("INSERT INTO test VALUES (2)"))
+		assert.Equal(t, 2, count(sp))
+		assert.NoError(t, sp.Rollback())
+		assert.Equal(t, 1, count(tx))
+
+		// release the savepoint, with a nested savepoint
+		sp, err = tx.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, sp.ExecErr("INSERT INTO test VALUES (3)"))
+		sp2, err := sp.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, sp2.ExecErr("INSERT INTO test VALUES (4)"))
+		assert.NoError(t, sp2.Rollback())
+		assert.NoError(t, sp.Commit())
+		assert.Equal(t, 2, count(tx))
+
+		assert.NoError(t, tx.Commit())
+		assert.Equal(t, 2, count(dbt.db))
+	})
+}
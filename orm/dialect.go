@@ -0,0 +1,30 @@
+package orm
+
+// dialect is the syntax of the statements which differs between the drivers
+type dialect struct {
+	greatest  string // the function returns the largest of the args
+	quote     string // the quote of the identifiers
+	dollar    bool   // use $1, $2... as the placeholder, e.g. postgres
+	returning bool   // the inserted id is got by `returning`, LastInsertId is not supported
+	noLimit   string // the limit of the offset without limit, empty if the limit can be omitted
+}
+
+// mysqlDialect is the default dialect, the identifiers are quoted with
+// the backticks which are also accepted by sqlite and clickhouse
+var mysqlDialect = dialect{greatest: "greatest", quote: "`", noLimit: "18446744073709551615"}
+
+func newDialect(driver string) dialect {
+	switch driver {
+	case "sqlite3":
+		return dialect{greatest: "max", quote: `"`, noLimit: "-1"}
+	case "postgres", "pgx":
+		return dialect{greatest: "greatest", quote: `"`, dollar: true, returning: true}
+	default:
+		return mysqlDialect
+	}
+}
+
+// quoteIdent quotes the column name, e.g. `name` for mysql, "name" for postgres
+func (p dialect) quoteIdent(name string) string {
+	return p.quote + name + p.quote
+}
@@ -0,0 +1,192 @@
+package orm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Encryptor encrypts the fields tagged with `sql:",encrypted"` on write
+// and decrypts them on scan, e.g.
+//
+//	type User struct {
+//		Name    string
+//		Phone   string   `sql:",encrypted"`
+//		Profile *Profile `sql:",encrypted" serializer:"json"`
+//	}
+//
+//	orm.SetEncryptor(orm.NewAESEncryptor(orm.NewStaticKMS("k2", map[string][]byte{
+//		"k1": oldKey,
+//		"k2": newKey,
+//	})))
+//
+// the field must be a string, a []byte, or serialized by the serializer tag
+type Encryptor interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// KMS provides the data keys of the Encryptor. The ciphertext contains the
+// id of the key, so the keys can be rotated by adding a new current key,
+// the rows encrypted by the old keys are still readable until rewritten.
+type KMS interface {
+	// CurrentKey returns the key to encrypt the new values
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key of the id to decrypt the values
+	Key(id string) ([]byte, error)
+}
+
+var (
+	encryptorMu sync.RWMutex
+	encryptor   Encryptor
+)
+
+// SetEncryptor sets the Encryptor of the encrypted fields
+func SetEncryptor(e Encryptor) {
+	encryptorMu.Lock()
+	defer encryptorMu.Unlock()
+
+	encryptor = e
+}
+
+func getEncryptor() (Encryptor, error) {
+	encryptorMu.RLock()
+	defer encryptorMu.RUnlock()
+
+	if encryptor == nil {
+		return nil, fmt.Errorf("the encryptor of the encrypted fields is not set")
+	}
+	return encryptor, nil
+}
+
+// encryptValue encrypts the column value of the encrypted field
+func encryptValue(v interface{}) (interface{}, error) {
+	var data []byte
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		data = t
+	case string:
+		data = []byte(t)
+	default:
+		return nil, fmt.Errorf("the encrypted field must be a string, []byte or serialized, got %T", v)
+	}
+
+	e, err := getEncryptor()
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := e.Encrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	return string(ret), nil
+}
+
+func decryptValue(data []byte) ([]byte, error) {
+	e, err := getEncryptor()
+	if err != nil {
+		return nil, err
+	}
+	return e.Decrypt(data)
+}
+
+type staticKMS struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKMS returns the KMS of the keys in memory, the current is
+// the id of the key to encrypt, the ids can't contain ':'
+func NewStaticKMS(current string, keys map[string][]byte) KMS {
+	return &staticKMS{current: current, keys: keys}
+}
+
+func (p *staticKMS) CurrentKey() (string, []byte, error) {
+	key, err := p.Key(p.current)
+	return p.current, key, err
+}
+
+func (p *staticKMS) Key(id string) ([]byte, error) {
+	if key, ok := p.keys[id]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key %q is not found", id)
+}
+
+type aesEncryptor struct {
+	kms KMS
+}
+
+// NewAESEncryptor returns the AES-GCM Encryptor of the keys of the kms,
+// the keys must be 16, 24 or 32 bytes. The ciphertext is
+// "<key id>:<base64 of the nonce and the sealed data>".
+func NewAESEncryptor(kms KMS) Encryptor {
+	return &aesEncryptor{kms: kms}
+}
+
+func (p *aesEncryptor) Encrypt(plaintext []byte) ([]byte, error) {
+	id, key, err := p.kms.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(id))
+	return []byte(id + ":" + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+func (p *aesEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	s := string(ciphertext)
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return nil, fmt.Errorf("invalid ciphertext, the key id is not found")
+	}
+	id := s[:i]
+
+	sealed, err := base64.StdEncoding.DecodeString(s[i+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %s", err)
+	}
+
+	key, err := p.kms.Key(id)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid ciphertext, too short")
+	}
+	nonce, sealed := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, sealed, []byte(id))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
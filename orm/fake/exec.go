@@ -0,0 +1,632 @@
+package fake
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type statement interface{}
+
+type createStmt struct {
+	table         string
+	columns       []string
+	autoIncrement string
+	ifNotExists   bool
+}
+
+type dropStmt struct {
+	table    string
+	ifExists bool
+}
+
+type insertStmt struct {
+	table   string
+	columns []string
+	values  [][]expr
+}
+
+type selectField struct {
+	name  string
+	star  bool
+	count bool
+	expr  expr
+}
+
+type order struct {
+	column string
+	desc   bool
+}
+
+type selectStmt struct {
+	fields  []selectField
+	table   string
+	sub     *selectStmt
+	where   expr
+	orderBy []order
+	limit   expr
+	offset  expr
+}
+
+type assign struct {
+	column string
+	value  expr
+}
+
+type updateStmt struct {
+	table string
+	sets  []assign
+	where expr
+}
+
+type deleteStmt struct {
+	table string
+	where expr
+}
+
+// savepointStmt is "savepoint", "release" or "rollback" to the savepoint
+type savepointStmt struct {
+	verb string
+	name string
+}
+
+// row is the values of the columns
+type row struct {
+	columns []string
+	values  []driver.Value
+}
+
+func (r row) get(column string) (driver.Value, error) {
+	for i, c := range r.columns {
+		if c == column {
+			return r.values[i], nil
+		}
+	}
+	return nil, fmt.Errorf("fake: no such column: %s", column)
+}
+
+type expr interface {
+	eval(r row, args []driver.Value) (driver.Value, error)
+}
+
+type colRef struct{ name string }
+
+func (e colRef) eval(r row, args []driver.Value) (driver.Value, error) {
+	return r.get(e.name)
+}
+
+type param struct{ index int }
+
+func (e param) eval(r row, args []driver.Value) (driver.Value, error) {
+	if e.index >= len(args) {
+		return nil, fmt.Errorf("fake: missing arg %d", e.index+1)
+	}
+	return args[e.index], nil
+}
+
+type literal struct{ v driver.Value }
+
+func (e literal) eval(r row, args []driver.Value) (driver.Value, error) {
+	return e.v, nil
+}
+
+type logical struct {
+	op   string
+	l, r expr
+}
+
+func (e logical) eval(r row, args []driver.Value) (driver.Value, error) {
+	l, err := evalBool(e.l, r, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "and" && !l {
+		return false, nil
+	}
+	if e.op == "or" && l {
+		return true, nil
+	}
+	return evalBool(e.r, r, args)
+}
+
+type not struct{ e expr }
+
+func (e not) eval(r row, args []driver.Value) (driver.Value, error) {
+	v, err := evalBool(e.e, r, args)
+	return !v, err
+}
+
+type isNull struct {
+	e   expr
+	not bool
+}
+
+func (e isNull) eval(r row, args []driver.Value) (driver.Value, error) {
+	v, err := e.e.eval(r, args)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != e.not, nil
+}
+
+type in struct {
+	e    expr
+	list []expr
+	not  bool
+}
+
+func (e in) eval(r row, args []driver.Value) (driver.Value, error) {
+	v, err := e.e.eval(r, args)
+	if err != nil || v == nil {
+		return false, err
+	}
+	for _, item := range e.list {
+		w, err := item.eval(r, args)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := compareValues(v, w); ok && c == 0 {
+			return !e.not, nil
+		}
+	}
+	return e.not, nil
+}
+
+type like struct {
+	e, pattern expr
+	escape     byte
+	not        bool
+}
+
+func (e like) eval(r row, args []driver.Value) (driver.Value, error) {
+	v, err := e.e.eval(r, args)
+	if err != nil || v == nil {
+		return false, err
+	}
+	pattern, err := e.pattern.eval(r, args)
+	if err != nil || pattern == nil {
+		return false, err
+	}
+
+	re, err := likeRegexp(toString(pattern), e.escape)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString(toString(v)) != e.not, nil
+}
+
+// likeRegexp converts the like pattern, % and _ are the wildcards
+func likeRegexp(pattern string, escape byte) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case escape != 0 && c == escape && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case c == '%':
+			b.WriteString(".*")
+		case c == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+type compare struct {
+	op   string
+	l, r expr
+}
+
+func (e compare) eval(r row, args []driver.Value) (driver.Value, error) {
+	l, err := e.l.eval(r, args)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := e.r.eval(r, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// the comparison with NULL is false
+	c, ok := compareValues(l, rv)
+	if !ok {
+		return false, nil
+	}
+
+	switch e.op {
+	case "=":
+		return c == 0, nil
+	case "!=", "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+func evalBool(e expr, r row, args []driver.Value) (bool, error) {
+	v, err := e.eval(r, args)
+	if err != nil {
+		return false, err
+	}
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case int64:
+		return v != 0, nil
+	}
+	return false, nil
+}
+
+// compareValues compares the numbers, the strings and the times,
+// the string is converted to the number if the other is a number
+func compareValues(a, b driver.Value) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1, true
+			case fa > fb:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			switch {
+			case ta.Before(tb):
+				return -1, true
+			case ta.After(tb):
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	return strings.Compare(toString(a), toString(b)), true
+}
+
+func toFloat(v driver.Value) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	case []byte:
+		f, err := strconv.ParseFloat(string(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(v driver.Value) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+func toInt(e expr, args []driver.Value) (int, error) {
+	v, err := e.eval(row{}, args)
+	if err != nil {
+		return 0, err
+	}
+	f, ok := toFloat(v)
+	if !ok {
+		return 0, fmt.Errorf("fake: invalid number %v", v)
+	}
+	return int(f), nil
+}
+
+// filter returns the rows of the table matched by the where condition
+func filter(t *table, where expr, args []driver.Value) ([]int, error) {
+	var ret []int
+	for i, values := range t.rows {
+		if where != nil {
+			ok, err := evalBool(where, row{t.columns, values}, args)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		ret = append(ret, i)
+	}
+	return ret, nil
+}
+
+func (s *createStmt) exec(db *database, args []driver.Value) (driver.Result, error) {
+	if _, ok := db.tables[s.table]; ok {
+		if s.ifNotExists {
+			return driver.RowsAffected(0), nil
+		}
+		return nil, fmt.Errorf("fake: table %s already exists", s.table)
+	}
+
+	db.tables[s.table] = &table{columns: s.columns, autoIncrement: s.autoIncrement}
+	return driver.RowsAffected(0), nil
+}
+
+func (s *dropStmt) exec(db *database, args []driver.Value) (driver.Result, error) {
+	if _, ok := db.tables[s.table]; !ok && !s.ifExists {
+		return nil, fmt.Errorf("fake: no such table: %s", s.table)
+	}
+
+	delete(db.tables, s.table)
+	return driver.RowsAffected(0), nil
+}
+
+func (s *insertStmt) exec(db *database, args []driver.Value) (driver.Result, error) {
+	t, err := db.table(s.table)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := s.columns
+	if len(columns) == 0 {
+		columns = t.columns
+	}
+
+	var id int64
+	for _, exprs := range s.values {
+		if len(exprs) != len(columns) {
+			return nil, fmt.Errorf("fake: %d values for %d columns", len(exprs), len(columns))
+		}
+
+		values := make([]driver.Value, len(t.columns))
+		for i, col := range columns {
+			j := t.index(col)
+			if j < 0 {
+				return nil, fmt.Errorf("fake: table %s has no column named %s", s.table, col)
+			}
+			if values[j], err = exprs[i].eval(row{}, args); err != nil {
+				return nil, err
+			}
+		}
+
+		t.seq++
+		id = t.seq
+		if j := t.index(t.autoIncrement); j >= 0 {
+			// like mysql, 0 generates the next value
+			if n, ok := values[j].(int64); values[j] == nil || (ok && n == 0) {
+				values[j] = t.seq
+			} else if n, ok := values[j].(int64); ok && n > t.seq {
+				t.seq = n
+			}
+			id, _ = values[j].(int64)
+		}
+		t.rows = append(t.rows, values)
+	}
+
+	return result{lastInsertId: id, rowsAffected: int64(len(s.values))}, nil
+}
+
+// rowsInsert appends the rows inserted by the transaction, with the ids
+// generated in the transaction
+type rowsInsert struct {
+	table string
+	rows  [][]driver.Value
+}
+
+func (s *rowsInsert) exec(db *database, args []driver.Value) (driver.Result, error) {
+	t, err := db.table(s.table)
+	if err != nil {
+		return nil, err
+	}
+
+	j := t.index(t.autoIncrement)
+	for _, values := range s.rows {
+		t.seq++
+		if j >= 0 {
+			if n, ok := values[j].(int64); ok && n > t.seq {
+				t.seq = n
+			}
+		}
+		t.rows = append(t.rows, values)
+	}
+	return result{rowsAffected: int64(len(s.rows))}, nil
+}
+
+func (s *updateStmt) exec(db *database, args []driver.Value) (driver.Result, error) {
+	t, err := db.table(s.table)
+	if err != nil {
+		return nil, err
+	}
+
+	matched, err := filter(t, s.where, args)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range matched {
+		values := append([]driver.Value{}, t.rows[i]...)
+		for _, set := range s.sets {
+			j := t.index(set.column)
+			if j < 0 {
+				return nil, fmt.Errorf("fake: no such column: %s", set.column)
+			}
+			if values[j], err = set.value.eval(row{t.columns, t.rows[i]}, args); err != nil {
+				return nil, err
+			}
+		}
+		t.rows[i] = values
+	}
+
+	return result{rowsAffected: int64(len(matched))}, nil
+}
+
+func (s *deleteStmt) exec(db *database, args []driver.Value) (driver.Result, error) {
+	t, err := db.table(s.table)
+	if err != nil {
+		return nil, err
+	}
+
+	matched, err := filter(t, s.where, args)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := map[int]bool{}
+	for _, i := range matched {
+		deleted[i] = true
+	}
+	rows := t.rows[:0:0]
+	for i, values := range t.rows {
+		if !deleted[i] {
+			rows = append(rows, values)
+		}
+	}
+	t.rows = rows
+
+	return result{rowsAffected: int64(len(matched))}, nil
+}
+
+// query returns the columns and the rows of the select
+func (s *selectStmt) query(db *database, args []driver.Value) ([]string, [][]driver.Value, error) {
+	var src *table
+	if s.sub != nil {
+		columns, rows, err := s.sub.query(db, args)
+		if err != nil {
+			return nil, nil, err
+		}
+		src = &table{columns: columns, rows: rows}
+	} else {
+		var err error
+		if src, err = db.table(s.table); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	matched, err := filter(src, s.where, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, o := range s.orderBy {
+		if src.index(o.column) < 0 {
+			return nil, nil, fmt.Errorf("fake: no such column: %s", o.column)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := src.rows[matched[i]], src.rows[matched[j]]
+		for _, o := range s.orderBy {
+			k := src.index(o.column)
+			c, _ := compareValues(a[k], b[k])
+			if a[k] == nil || b[k] == nil {
+				// NULL first
+				c = boolToInt(b[k] == nil) - boolToInt(a[k] == nil)
+			}
+			if c != 0 {
+				return (c < 0) != o.desc
+			}
+		}
+		return false
+	})
+
+	if s.offset != nil {
+		n, err := toInt(s.offset, args)
+		if err != nil {
+			return nil, nil, err
+		}
+		if n > len(matched) {
+			n = len(matched)
+		}
+		matched = matched[n:]
+	}
+	if s.limit != nil {
+		n, err := toInt(s.limit, args)
+		if err != nil {
+			return nil, nil, err
+		}
+		if n < len(matched) {
+			matched = matched[:n]
+		}
+	}
+
+	// count(*) is the only aggregate
+	if len(s.fields) == 1 && s.fields[0].count {
+		return []string{s.fields[0].name}, [][]driver.Value{{int64(len(matched))}}, nil
+	}
+
+	var columns []string
+	for _, f := range s.fields {
+		switch {
+		case f.star:
+			columns = append(columns, src.columns...)
+		case f.count:
+			return nil, nil, fmt.Errorf("fake: count(*) with the other fields is not supported")
+		default:
+			columns = append(columns, f.name)
+		}
+	}
+
+	rows := make([][]driver.Value, 0, len(matched))
+	for _, i := range matched {
+		r := row{src.columns, src.rows[i]}
+		values := make([]driver.Value, 0, len(columns))
+		for _, f := range s.fields {
+			if f.star {
+				values = append(values, r.values...)
+				continue
+			}
+			v, err := f.expr.eval(r, args)
+			if err != nil {
+				return nil, nil, err
+			}
+			values = append(values, v)
+		}
+		rows = append(rows, values)
+	}
+
+	return columns, rows, nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type result struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) { return r.lastInsertId, nil }
+
+func (r result) RowsAffected() (int64, error) { return r.rowsAffected, nil }
@@ -0,0 +1,335 @@
+// Package fake is an in-memory database/sql driver for the unit tests
+// of the orm consumers, without the sqlite file or the cgo, e.g.
+//
+//	db, err := fake.Open()
+//	db.Exec("create table user (id integer primary key, name varchar(32))")
+//	db.Insert("user", &User{Name: "tom"})
+//
+// The orm passes the statements to database/sql as the SQL strings, there
+// is no interface under the DB to be backed by the maps, so the fake is a
+// driver which parses the subset of the SQL generated by the orm:
+// create/drop table, insert, update, delete and the single table select
+// with the where conditions (=, !=, <, >, in, like, is null, and, or),
+// order by, limit, offset, count(*) and the subquery of the from clause.
+// The primary key or auto_increment column is generated if it's NULL or 0.
+// The joins, the group by and the upserts are not supported.
+//
+// The transaction works on a copy of the tables, its writes are logged
+// and applied to the tables on commit, so the writes committed by the
+// other connections meanwhile are kept. The savepoints are supported,
+// e.g. the nested Transaction.
+package fake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yubo/golib/orm"
+)
+
+const DriverName = "fake"
+
+func init() {
+	sql.Register(DriverName, &fakeDriver{})
+}
+
+var (
+	mu        sync.Mutex
+	databases = map[string]*database{}
+	seq       int64
+)
+
+// Open returns a DB of a new empty database
+func Open(opts ...orm.DBOption) (*orm.DB, error) {
+	name := fmt.Sprintf("fake-%d", atomic.AddInt64(&seq, 1))
+	return orm.DbOpen(DriverName, name, opts...)
+}
+
+type table struct {
+	columns       []string
+	autoIncrement string // the auto increment column, or empty
+	seq           int64
+	rows          [][]driver.Value
+}
+
+func (t *table) index(column string) int {
+	for i, c := range t.columns {
+		if c == column {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *table) clone() *table {
+	ret := *t
+	ret.rows = make([][]driver.Value, len(t.rows))
+	copy(ret.rows, t.rows)
+	return &ret
+}
+
+// database is shared by the connections of the same dsn
+type database struct {
+	sync.Mutex
+	tables map[string]*table
+}
+
+func (db *database) table(name string) (*table, error) {
+	if t, ok := db.tables[name]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("fake: no such table: %s", name)
+}
+
+func (db *database) clone() *database {
+	ret := &database{tables: make(map[string]*table, len(db.tables))}
+	for k, v := range db.tables {
+		ret.tables[k] = v.clone()
+	}
+	return ret
+}
+
+type fakeDriver struct{}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	db, ok := databases[name]
+	if !ok {
+		db = &database{tables: map[string]*table{}}
+		databases[name] = db
+	}
+	return &conn{db: db}, nil
+}
+
+type conn struct {
+	db *database
+	tx *txState // nil if not in a transaction
+}
+
+// txState is the transaction of the connection, the statements run on
+// a copy of the tables, and are logged to be applied to the db on commit,
+// like the statement-based replication, the inserted rows are logged
+// with the generated ids
+type txState struct {
+	db         *database
+	log        []write
+	savepoints []savepoint
+}
+
+type write struct {
+	stmt execer
+	args []driver.Value
+}
+
+type savepoint struct {
+	name string
+	db   *database // the copy of the tables
+	log  int       // the length of the log
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeStmt{conn: c, stmt: stmt}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, fmt.Errorf("fake: already in a transaction")
+	}
+
+	c.db.Lock()
+	c.tx = &txState{db: c.db.clone()}
+	c.db.Unlock()
+
+	return &tx{conn: c}, nil
+}
+
+// exec runs the write statement on the db of the connection, or the
+// copy of the transaction, where it's logged
+func (c *conn) exec(stmt execer, args []driver.Value) (driver.Result, error) {
+	if c.tx == nil {
+		c.db.Lock()
+		defer c.db.Unlock()
+		return stmt.exec(c.db, args)
+	}
+
+	ret, err := stmt.exec(c.tx.db, args)
+	if err != nil {
+		return nil, err
+	}
+
+	w := write{stmt: stmt, args: args}
+	if s, ok := stmt.(*insertStmt); ok {
+		// log the rows with the generated ids
+		n, _ := ret.RowsAffected()
+		t, _ := c.tx.db.table(s.table)
+		rows := append([][]driver.Value{}, t.rows[len(t.rows)-int(n):]...)
+		w = write{stmt: &rowsInsert{table: s.table, rows: rows}}
+	}
+	c.tx.log = append(c.tx.log, w)
+	return ret, nil
+}
+
+// query runs the select on the db of the connection or the transaction
+func (c *conn) query(stmt *selectStmt, args []driver.Value) ([]string, [][]driver.Value, error) {
+	if c.tx != nil {
+		return stmt.query(c.tx.db, args)
+	}
+
+	c.db.Lock()
+	defer c.db.Unlock()
+	return stmt.query(c.db, args)
+}
+
+func (c *conn) savepoint(stmt *savepointStmt) error {
+	if c.tx == nil {
+		return fmt.Errorf("fake: %s %s is not in a transaction", stmt.verb, stmt.name)
+	}
+
+	if stmt.verb == "savepoint" {
+		c.tx.savepoints = append(c.tx.savepoints, savepoint{name: stmt.name, db: c.tx.db.clone(), log: len(c.tx.log)})
+		return nil
+	}
+
+	i := len(c.tx.savepoints) - 1
+	for ; i >= 0 && c.tx.savepoints[i].name != stmt.name; i-- {
+	}
+	if i < 0 {
+		return fmt.Errorf("fake: no such savepoint: %s", stmt.name)
+	}
+
+	if stmt.verb == "release" {
+		c.tx.savepoints = c.tx.savepoints[:i]
+		return nil
+	}
+
+	// rollback to the savepoint, which is kept
+	sp := c.tx.savepoints[i]
+	c.tx.db = sp.db.clone()
+	c.tx.log = c.tx.log[:sp.log]
+	c.tx.savepoints = c.tx.savepoints[:i+1]
+	return nil
+}
+
+type tx struct {
+	conn *conn
+}
+
+// Commit applies the log of the transaction to the db, the writes
+// committed by the other connections during the transaction are kept,
+// the conflicts are not detected, e.g. the same generated ids
+func (t *tx) Commit() error {
+	c := t.conn
+	log := c.tx.log
+	c.tx = nil
+
+	c.db.Lock()
+	defer c.db.Unlock()
+
+	db := c.db.clone()
+	for _, w := range log {
+		if _, err := w.stmt.exec(db, w.args); err != nil {
+			return err
+		}
+	}
+	c.db.tables = db.tables
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	t.conn.tx = nil
+	return nil
+}
+
+type fakeStmt struct {
+	conn *conn
+	stmt statement
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1, the number of the args is checked on executing
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+type execer interface {
+	exec(db *database, args []driver.Value) (driver.Result, error)
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch stmt := s.stmt.(type) {
+	case execer:
+		return s.conn.exec(stmt, args)
+	case *savepointStmt:
+		if err := s.conn.savepoint(stmt); err != nil {
+			return nil, err
+		}
+		return driver.RowsAffected(0), nil
+	case *selectStmt:
+		if _, err := s.Query(args); err != nil {
+			return nil, err
+		}
+		return driver.RowsAffected(0), nil
+	}
+	return nil, fmt.Errorf("fake: unsupported statement %T", s.stmt)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	stmt, ok := s.stmt.(*selectStmt)
+	if !ok {
+		if _, err := s.Exec(args); err != nil {
+			return nil, err
+		}
+		return &rows{}, nil
+	}
+
+	columns, values, err := s.conn.query(stmt, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: columns, rows: values}, nil
+}
+
+type rows struct {
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
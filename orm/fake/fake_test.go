@@ -0,0 +1,158 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yubo/golib/api/errors"
+	"github.com/yubo/golib/labels"
+	"github.com/yubo/golib/orm"
+)
+
+type User struct {
+	Id        int64 `sql:",where"`
+	Name      string
+	Age       int
+	Tags      []string
+	DeletedAt *int64 `sql:",softdelete"`
+}
+
+func TestFake(t *testing.T) {
+	db, err := Open()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE user (id integer primary key, name varchar(32), age int, tags text, deleted_at int)")
+	assert.NoError(t, err)
+
+	id, err := db.InsertLastId("user", &User{Name: "tom", Age: 10, Tags: []string{"a"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	assert.NoError(t, db.Insert("user", &User{Name: "jerry", Age: 20}))
+	assert.NoError(t, db.Insert("user", &User{Name: "bob", Age: 30}))
+
+	var user User
+	assert.NoError(t, db.Query("select * from user where id = ?", 1).Row(&user))
+	assert.Equal(t, User{Id: 1, Name: "tom", Age: 10, Tags: []string{"a"}}, user)
+	assert.True(t, errors.IsNotFound(db.Query("select * from user where id = ?", 10).Row(&user)))
+
+	user.Age = 11
+	assert.NoError(t, db.Update("user", &user))
+	assert.NoError(t, db.Delete("user", &User{Id: 3}))
+
+	selector, err := labels.Parse("age>10,(name~t%;name in (jerry))")
+	assert.NoError(t, err)
+	q := orm.NewQuery(db).Table("user").WithSelector(selector).OrderBy("age desc")
+
+	var users []User
+	assert.NoError(t, q.Rows(&users))
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, "jerry", users[0].Name)
+	assert.Equal(t, 11, users[1].Age)
+
+	// the soft-deleted row is filtered out
+	n, err := q.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	n, err = orm.NewQuery(db).Table("user").WithDeleted().Count()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	_, err = db.Exec("select * from foo")
+	assert.Error(t, err)
+}
+
+func TestFakeTransaction(t *testing.T) {
+	db, err := Open()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE test (value int)")
+	assert.NoError(t, err)
+
+	count := func() (n int) {
+		assert.NoError(t, db.Query("select count(*) from test").Row(&n))
+		return
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, db.Transaction(ctx, func(tx *orm.DB) error {
+		return tx.ExecErr("INSERT INTO test VALUES (?), (?)", 1, 2)
+	}))
+	assert.Equal(t, 2, count())
+
+	assert.Error(t, db.Transaction(ctx, func(tx *orm.DB) error {
+		tx.ExecErr("DELETE FROM test")
+		return fmt.Errorf("rollback")
+	}))
+	assert.Equal(t, 2, count())
+
+	// the writes of the other connections during the transaction are kept
+	assert.NoError(t, db.Transaction(ctx, func(tx *orm.DB) error {
+		assert.NoError(t, db.ExecErr("INSERT INTO test VALUES (?)", 3))
+		return tx.ExecErr("DELETE FROM test WHERE value = ?", 1)
+	}))
+	assert.Equal(t, 2, count())
+	var values []int
+	assert.NoError(t, db.Query("select value from test order by value").Rows(&values))
+	assert.Equal(t, []int{2, 3}, values)
+
+	// nested, only the savepoint is rolled back
+	assert.NoError(t, db.Transaction(ctx, func(tx *orm.DB) error {
+		assert.NoError(t, tx.ExecErr("INSERT INTO test VALUES (?)", 4))
+		err := tx.Transaction(ctx, func(tx *orm.DB) error {
+			tx.ExecErr("INSERT INTO test VALUES (?)", 5)
+			return fmt.Errorf("rollback")
+		})
+		assert.Error(t, err)
+		return tx.Transaction(ctx, func(tx *orm.DB) error {
+			return tx.ExecErr("INSERT INTO test VALUES (?)", 6)
+		})
+	}))
+	values = nil
+	assert.NoError(t, db.Query("select value from test order by value").Rows(&values))
+	assert.Equal(t, []int{2, 3, 4, 6}, values)
+
+	_, err = db.Exec("RELEASE SAVEPOINT sp_1")
+	assert.Error(t, err)
+}
+
+func TestFakeTransactionLastId(t *testing.T) {
+	db, err := Open()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE user (id integer primary key, name varchar(32), age int, tags text, deleted_at int)")
+	assert.NoError(t, err)
+
+	// the ids generated in the transaction are committed
+	var id int64
+	assert.NoError(t, db.Transaction(context.Background(), func(tx *orm.DB) error {
+		id, err = tx.InsertLastId("user", &User{Name: "tom"})
+		return err
+	}))
+	assert.Equal(t, int64(1), id)
+
+	var user User
+	assert.NoError(t, db.Query("select * from user where id = ?", id).Row(&user))
+	assert.Equal(t, "tom", user.Name)
+
+	id, err = db.InsertLastId("user", &User{Name: "jerry"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), id)
+}
+
+func TestParseError(t *testing.T) {
+	for _, query := range []string{
+		"select * from a join b on a.id = b.id",
+		"insert into t (a) values (?) on conflict do nothing",
+		"select name, count(*) from t group by name",
+		"update t set",
+		"select * from t where a = 'x",
+	} {
+		_, err := parse(query)
+		assert.Error(t, err, query)
+	}
+}
@@ -0,0 +1,676 @@
+package fake
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokKeyword
+	tokNumber
+	tokString
+	tokParam
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	s    string
+}
+
+var keywords = map[string]bool{
+	"select": true, "from": true, "where": true, "and": true, "or": true, "not": true,
+	"insert": true, "into": true, "values": true, "update": true, "set": true,
+	"delete": true, "create": true, "drop": true, "table": true, "if": true,
+	"exists": true, "is": true, "null": true, "in": true, "like": true, "escape": true,
+	"order": true, "by": true, "asc": true, "desc": true, "limit": true, "offset": true,
+	"as": true, "count": true, "join": true, "group": true, "having": true,
+	"on": true, "true": true, "false": true,
+}
+
+// tokenize splits the statement, the identifiers and the keywords are lowercased
+func tokenize(s string) ([]token, error) {
+	var ret []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ';':
+			i++
+		case c == '?':
+			ret = append(ret, token{tokParam, "?"})
+			i++
+		case c == '\'':
+			j := i + 1
+			var b strings.Builder
+			for ; j < len(s); j++ {
+				if s[j] == '\'' {
+					if j+1 < len(s) && s[j+1] == '\'' {
+						b.WriteByte('\'')
+						j++
+						continue
+					}
+					break
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unclosed string at %d", i)
+			}
+			ret = append(ret, token{tokString, b.String()})
+			i = j + 1
+		case c == '`' || c == '"':
+			j := strings.IndexByte(s[i+1:], c)
+			if j < 0 {
+				return nil, fmt.Errorf("unclosed identifier at %d", i)
+			}
+			ret = append(ret, token{tokIdent, strings.ToLower(s[i+1 : i+1+j])})
+			i += j + 2
+		case c >= '0' && c <= '9' || (c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9'):
+			j := i + 1
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			ret = append(ret, token{tokNumber, s[i:j]})
+			i = j
+		case isIdentChar(c):
+			j := i
+			for j < len(s) && (isIdentChar(s[j]) || s[j] == '.') {
+				j++
+			}
+			word := strings.ToLower(s[i:j])
+			if keywords[word] {
+				ret = append(ret, token{tokKeyword, word})
+			} else {
+				ret = append(ret, token{tokIdent, word})
+			}
+			i = j
+		default:
+			if i+1 < len(s) {
+				switch two := s[i : i+2]; two {
+				case "!=", "<>", "<=", ">=":
+					ret = append(ret, token{tokSymbol, two})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("(),=<>*", rune(c)) {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			ret = append(ret, token{tokSymbol, string(c)})
+			i++
+		}
+	}
+	return append(ret, token{kind: tokEOF}), nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	params int
+}
+
+func parse(query string) (statement, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	stmt, err := p.parseStatement()
+	if err != nil {
+		return nil, fmt.Errorf("fake: %s in %q", err, query)
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("fake: unsupported %q in %q", t.s, query)
+	}
+	return stmt, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the keyword or the symbol if it's the next
+func (p *parser) accept(s string) bool {
+	if t := p.peek(); (t.kind == tokKeyword || t.kind == tokSymbol) && t.s == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return fmt.Errorf("expected %q, got %q", s, p.peek().s)
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return "", fmt.Errorf("expected identifier, got %q", t.s)
+	}
+	return t.s, nil
+}
+
+func (p *parser) parseStatement() (statement, error) {
+	switch t := p.next(); t.s {
+	case "create":
+		return p.parseCreate()
+	case "drop":
+		return p.parseDrop()
+	case "insert":
+		return p.parseInsert()
+	case "select":
+		return p.parseSelect()
+	case "update":
+		return p.parseUpdate()
+	case "delete":
+		return p.parseDelete()
+	case "savepoint", "release", "rollback":
+		return p.parseSavepoint(t.s)
+	default:
+		return nil, fmt.Errorf("unsupported statement %q", t.s)
+	}
+}
+
+// acceptIdent consumes the identifier if it's the next, e.g. "savepoint",
+// which is not a keyword to be used as the column name
+func (p *parser) acceptIdent(s string) bool {
+	if t := p.peek(); t.kind == tokIdent && t.s == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseSavepoint parses "savepoint sp", "release [savepoint] sp"
+// and "rollback to [savepoint] sp"
+func (p *parser) parseSavepoint(verb string) (statement, error) {
+	if verb == "rollback" && !p.acceptIdent("to") {
+		return nil, fmt.Errorf("expected \"to\", got %q", p.peek().s)
+	}
+	if verb != "savepoint" {
+		p.acceptIdent("savepoint")
+	}
+
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	return &savepointStmt{verb: verb, name: name}, nil
+}
+
+// parseCreate parses "create table [if not exists] t (col type ..., ...)",
+// the column is auto increment if it's the primary key or auto_increment
+func (p *parser) parseCreate() (statement, error) {
+	if err := p.expect("table"); err != nil {
+		return nil, err
+	}
+	stmt := &createStmt{}
+	if p.accept("if") {
+		if err := p.expect("not"); err != nil {
+			return nil, err
+		}
+		if err := p.expect("exists"); err != nil {
+			return nil, err
+		}
+		stmt.ifNotExists = true
+	}
+
+	var err error
+	if stmt.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	for {
+		col, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		stmt.columns = append(stmt.columns, col)
+
+		// the type and the constraints
+		def := []string{}
+		depth := 0
+		for {
+			t := p.peek()
+			if t.kind == tokEOF {
+				return nil, fmt.Errorf("unclosed column list")
+			}
+			if depth == 0 && (t.s == "," || t.s == ")") && t.kind == tokSymbol {
+				break
+			}
+			if t.s == "(" {
+				depth++
+			} else if t.s == ")" {
+				depth--
+			}
+			def = append(def, t.s)
+			p.next()
+		}
+		d := strings.Join(def, " ")
+		if strings.Contains(d, "primary key") || strings.Contains(d, "auto_increment") ||
+			strings.Contains(d, "autoincrement") {
+			stmt.autoIncrement = col
+		}
+
+		if p.accept(")") {
+			return stmt, nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parseDrop() (statement, error) {
+	if err := p.expect("table"); err != nil {
+		return nil, err
+	}
+	stmt := &dropStmt{}
+	if p.accept("if") {
+		if err := p.expect("exists"); err != nil {
+			return nil, err
+		}
+		stmt.ifExists = true
+	}
+	var err error
+	stmt.table, err = p.ident()
+	return stmt, err
+}
+
+func (p *parser) parseInsert() (statement, error) {
+	if err := p.expect("into"); err != nil {
+		return nil, err
+	}
+
+	stmt := &insertStmt{}
+	var err error
+	if stmt.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+
+	if p.accept("(") {
+		for {
+			col, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			stmt.columns = append(stmt.columns, col)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := p.expect("values"); err != nil {
+		return nil, err
+	}
+
+	for {
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		stmt.values = append(stmt.values, list)
+		if !p.accept(",") {
+			return stmt, nil
+		}
+	}
+}
+
+// parseList parses "(expr, expr, ...)"
+func (p *parser) parseList() ([]expr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var ret []expr
+	for {
+		e, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, e)
+		if p.accept(")") {
+			return ret, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseSelect() (statement, error) {
+	stmt := &selectStmt{}
+
+	for {
+		switch {
+		case p.accept("*"):
+			stmt.fields = append(stmt.fields, selectField{star: true})
+		case p.accept("count"):
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			if err := p.expect("*"); err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			stmt.fields = append(stmt.fields, selectField{count: true, name: "count(*)"})
+		default:
+			e, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			f := selectField{expr: e}
+			if c, ok := e.(colRef); ok {
+				f.name = c.name
+			}
+			stmt.fields = append(stmt.fields, f)
+		}
+
+		if p.accept("as") {
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			stmt.fields[len(stmt.fields)-1].name = name
+		}
+
+		if !p.accept(",") {
+			break
+		}
+	}
+
+	if err := p.expect("from"); err != nil {
+		return nil, err
+	}
+
+	if p.accept("(") {
+		if err := p.expect("select"); err != nil {
+			return nil, err
+		}
+		sub, err := p.parseSelect()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		stmt.sub = sub.(*selectStmt)
+	} else {
+		var err error
+		if stmt.table, err = p.ident(); err != nil {
+			return nil, err
+		}
+	}
+	// the alias
+	if p.peek().kind == tokIdent {
+		p.next()
+	}
+
+	if p.accept("where") {
+		var err error
+		if stmt.where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.accept("order") {
+		if err := p.expect("by"); err != nil {
+			return nil, err
+		}
+		for {
+			col, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			o := order{column: col}
+			if p.accept("desc") {
+				o.desc = true
+			} else {
+				p.accept("asc")
+			}
+			stmt.orderBy = append(stmt.orderBy, o)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+
+	if p.accept("limit") {
+		var err error
+		if stmt.limit, err = p.parseValue(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.accept("offset") {
+		var err error
+		if stmt.offset, err = p.parseValue(); err != nil {
+			return nil, err
+		}
+	}
+
+	return stmt, nil
+}
+
+func (p *parser) parseUpdate() (statement, error) {
+	stmt := &updateStmt{}
+	var err error
+	if stmt.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("set"); err != nil {
+		return nil, err
+	}
+
+	for {
+		col, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		e, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		stmt.sets = append(stmt.sets, assign{col, e})
+		if !p.accept(",") {
+			break
+		}
+	}
+
+	if p.accept("where") {
+		if stmt.where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseDelete() (statement, error) {
+	if err := p.expect("from"); err != nil {
+		return nil, err
+	}
+
+	stmt := &deleteStmt{}
+	var err error
+	if stmt.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+
+	if p.accept("where") {
+		if stmt.where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// parseExpr parses the conditions, "or" has the lower precedence than "and"
+func (p *parser) parseExpr() (expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = logical{op: "or", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = logical{op: "and", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.accept("not") {
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return not{e}, nil
+	}
+	return p.parseCondition()
+}
+
+func (p *parser) parseCondition() (expr, error) {
+	if p.accept("(") {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+
+	l, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.accept("is") {
+		isNot := p.accept("not")
+		if err := p.expect("null"); err != nil {
+			return nil, err
+		}
+		return isNull{e: l, not: isNot}, nil
+	}
+
+	isNot := p.accept("not")
+	switch {
+	case p.accept("in"):
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return in{e: l, list: list, not: isNot}, nil
+	case p.accept("like"):
+		r, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		e := like{e: l, pattern: r, not: isNot}
+		if p.accept("escape") {
+			t := p.next()
+			if t.kind != tokString || len(t.s) != 1 {
+				return nil, fmt.Errorf("invalid escape %q", t.s)
+			}
+			e.escape = t.s[0]
+		}
+		return e, nil
+	case isNot:
+		return nil, fmt.Errorf("unexpected 'not'")
+	}
+
+	t := p.next()
+	switch t.s {
+	case "=", "!=", "<>", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", t.s)
+	}
+	r, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return compare{op: t.s, l: l, r: r}, nil
+}
+
+// parseValue parses a column, a placeholder or a literal
+func (p *parser) parseValue() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokIdent:
+		// strip the table alias, e.g. u.name
+		if i := strings.LastIndexByte(t.s, '.'); i >= 0 {
+			return colRef{t.s[i+1:]}, nil
+		}
+		return colRef{t.s}, nil
+	case tokParam:
+		p.params++
+		return param{p.params - 1}, nil
+	case tokString:
+		return literal{t.s}, nil
+	case tokNumber:
+		if n, err := strconv.ParseInt(t.s, 10, 64); err == nil {
+			return literal{n}, nil
+		}
+		f, err := strconv.ParseFloat(t.s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return literal{f}, nil
+	case tokKeyword:
+		switch t.s {
+		case "null":
+			return literal{nil}, nil
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q", t.s)
+}
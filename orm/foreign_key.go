@@ -0,0 +1,144 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ForeignKey is declared by the `references` tag of the struct field, e.g.
+//
+//	type Order struct {
+//		UserId int64 `references:"user(id),ondelete:cascade"`
+//		ShopId int64 `references:"shop(id),name:fk_shop,ondelete:set null,onupdate:cascade"`
+//	}
+//
+// the name is default "fk_<table>_<column>", the actions of ondelete
+// and onupdate are cascade, restrict, set null, set default and no action.
+type ForeignKey struct {
+	Name      string
+	Column    string
+	RefTable  string
+	RefColumn string
+	OnDelete  string
+	OnUpdate  string
+}
+
+var foreignKeyActions = map[string]bool{
+	"cascade":     true,
+	"restrict":    true,
+	"set null":    true,
+	"set default": true,
+	"no action":   true,
+}
+
+// ForeignKeys returns the foreign keys declared by the sample struct of the table
+func ForeignKeys(table string, sample interface{}) ([]ForeignKey, error) {
+	rt := indirectType(reflect.TypeOf(sample))
+	if rt.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ForeignKeys() needs a struct, got %s", rt)
+	}
+
+	ret := []ForeignKey{}
+	for _, f := range cachedTypeFields(rt).list {
+		if f.references == "" {
+			continue
+		}
+
+		fk, err := parseForeignKey(table, f.key, f.references)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, *fk)
+	}
+
+	return ret, nil
+}
+
+func parseForeignKey(table, column, tag string) (*ForeignKey, error) {
+	ref, opts := parseTag(tag)
+
+	i := strings.Index(ref, "(")
+	if i <= 0 || !strings.HasSuffix(ref, ")") {
+		return nil, fmt.Errorf("invalid references %q of %s.%s, expected table(column)", tag, table, column)
+	}
+
+	fk := &ForeignKey{
+		Name:      fmt.Sprintf("fk_%s_%s", table, column),
+		Column:    column,
+		RefTable:  ref[:i],
+		RefColumn: ref[i+1 : len(ref)-1],
+	}
+
+	for _, opt := range strings.Split(string(opts), ",") {
+		kv := strings.SplitN(opt, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch k, v := kv[0], strings.ToLower(strings.TrimSpace(kv[1])); k {
+		case "name":
+			fk.Name = v
+		case "ondelete", "onupdate":
+			if !foreignKeyActions[v] {
+				return nil, fmt.Errorf("invalid %s action %q of %s.%s", k, v, table, column)
+			}
+			if k == "ondelete" {
+				fk.OnDelete = v
+			} else {
+				fk.OnUpdate = v
+			}
+		}
+	}
+
+	return fk, nil
+}
+
+// constraint returns the constraint clause of the create table statement
+func (p ForeignKey) constraint() string {
+	s := fmt.Sprintf("constraint %s foreign key (%s) references %s (%s)",
+		p.Name, p.Column, p.RefTable, p.RefColumn)
+	if p.OnDelete != "" {
+		s += " on delete " + p.OnDelete
+	}
+	if p.OnUpdate != "" {
+		s += " on update " + p.OnUpdate
+	}
+	return s
+}
+
+// GenForeignKeySql generates the statements to add the foreign keys declared
+// by the sample to the existing table, e.g. for the migrations. It's not
+// supported by sqlite, which only accepts the constraints in the create table
+// statement, see ForeignKeyConstraints.
+func GenForeignKeySql(driver, table string, sample interface{}) ([]string, error) {
+	if driver == "sqlite3" {
+		return nil, fmt.Errorf("sqlite3 can't add the foreign key to the existing table")
+	}
+
+	fks, err := ForeignKeys(table, sample)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]string, 0, len(fks))
+	for _, fk := range fks {
+		ret = append(ret, fmt.Sprintf("alter table %s add %s", table, fk.constraint()))
+	}
+	return ret, nil
+}
+
+// ForeignKeyConstraints returns the constraint clauses of the foreign keys
+// declared by the sample, to be appended to the create table statement
+func ForeignKeyConstraints(table string, sample interface{}) ([]string, error) {
+	fks, err := ForeignKeys(table, sample)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]string, 0, len(fks))
+	for _, fk := range fks {
+		ret = append(ret, fk.constraint())
+	}
+	return ret, nil
+}
@@ -0,0 +1,87 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yubo/golib/util"
+	"k8s.io/klog/v2"
+)
+
+// WithHealthCheck pings the primary every interval in the background, and
+// calls onChange if the health is changed, e.g. the mysql is restarted,
+// err is nil if it's healthy. The broken connections are dropped by the
+// failed pings, so that the pool is reconnected once the server is back.
+// onChange can be nil.
+func WithHealthCheck(interval time.Duration, onChange func(healthy bool, err error)) DBOption {
+	return func(o *dbOptions) {
+		o.healthInterval = interval
+		o.healthChange = onChange
+	}
+}
+
+type healthChecker struct {
+	db       *sql.DB
+	interval time.Duration
+	onChange func(healthy bool, err error)
+	healthy  int32
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+func newHealthChecker(db *sql.DB, interval time.Duration, onChange func(bool, error)) *healthChecker {
+	p := &healthChecker{
+		db:       db,
+		interval: interval,
+		onChange: onChange,
+		healthy:  1,
+		stopCh:   make(chan struct{}),
+	}
+
+	util.Until(p.check, interval, p.stopCh)
+
+	return p
+}
+
+// check pings the db with the timeout of the interval
+func (p *healthChecker) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+	defer cancel()
+
+	err := p.db.PingContext(ctx)
+
+	healthy := int32(0)
+	if err == nil {
+		healthy = 1
+	}
+	if atomic.SwapInt32(&p.healthy, healthy) == healthy {
+		return
+	}
+
+	if err == nil {
+		klog.Infof("db is back")
+	} else {
+		klog.Warningf("db is unhealthy: %s", err)
+	}
+
+	if p.onChange != nil {
+		p.onChange(err == nil, err)
+	}
+}
+
+func (p *healthChecker) stop() {
+	p.stopOnce.Do(func() { close(p.stopCh) })
+}
+
+// Healthy returns the health of the last ping of WithHealthCheck,
+// or pings the primary if the health check is disabled,
+// e.g. for the healthz handler
+func (p *DB) Healthy() bool {
+	if p.health != nil {
+		return atomic.LoadInt32(&p.health.healthy) == 1
+	}
+	return p.DB.PingContext(p.context()) == nil
+}
@@ -0,0 +1,62 @@
+package orm
+
+// The hooks are implemented by the samples of Insert, Update and Delete,
+// e.g. to maintain the audit columns. The sample should be passed by pointer
+// if the hook modifies it. The db is the session of the statement, it's the
+// transaction if the statement is run in a transaction. The statement is not
+// executed if the Before hook returns an error.
+
+type BeforeInserter interface {
+	BeforeInsert(db *DB) error
+}
+
+type AfterInserter interface {
+	AfterInsert(db *DB) error
+}
+
+type BeforeUpdater interface {
+	BeforeUpdate(db *DB) error
+}
+
+type AfterUpdater interface {
+	AfterUpdate(db *DB) error
+}
+
+type BeforeDeleter interface {
+	BeforeDelete(db *DB) error
+}
+
+func beforeInsert(db *DB, sample interface{}) error {
+	if h, ok := sample.(BeforeInserter); ok {
+		return h.BeforeInsert(db)
+	}
+	return nil
+}
+
+func afterInsert(db *DB, sample interface{}) error {
+	if h, ok := sample.(AfterInserter); ok {
+		return h.AfterInsert(db)
+	}
+	return nil
+}
+
+func beforeUpdate(db *DB, sample interface{}) error {
+	if h, ok := sample.(BeforeUpdater); ok {
+		return h.BeforeUpdate(db)
+	}
+	return nil
+}
+
+func afterUpdate(db *DB, sample interface{}) error {
+	if h, ok := sample.(AfterUpdater); ok {
+		return h.AfterUpdate(db)
+	}
+	return nil
+}
+
+func beforeDelete(db *DB, sample interface{}) error {
+	if h, ok := sample.(BeforeDeleter); ok {
+		return h.BeforeDelete(db)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Index is declared by the `index` tag of the struct fields, e.g.
+//
+//	type User struct {
+//		Name  string `index:""`                               // idx_user_name (name)
+//		Email string `index:"uk_user_email,unique"`           // unique uk_user_email (email)
+//		Dept  string `index:"idx_user_org,composite:org_id"` // idx_user_org (dept, org_id)
+//		OrgId int64
+//	}
+//
+// the options are separated by ',', the name is default "idx_<table>_<column>",
+// the "composite:" appends the columns separated by '|' to the index,
+// the fields with the same index name are merged in the field order.
+type Index struct {
+	Name    string
+	Unique  bool
+	Columns []string
+}
+
+// Indexes returns the indexes declared by the sample struct of the table
+func Indexes(table string, sample interface{}) ([]Index, error) {
+	rt := indirectType(reflect.TypeOf(sample))
+	if rt.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Indexes() needs a struct, got %s", rt)
+	}
+
+	indexes := []Index{}
+	byName := map[string]int{}
+
+	for _, f := range cachedTypeFields(rt).list {
+		if !f.indexed {
+			continue
+		}
+
+		name, opts := parseTag(f.indexTag)
+		if name == "" {
+			name = fmt.Sprintf("idx_%s_%s", table, f.key)
+		}
+
+		columns := []string{f.key}
+		for _, opt := range strings.Split(string(opts), ",") {
+			if strings.HasPrefix(opt, "composite:") {
+				for _, c := range strings.Split(opt[len("composite:"):], "|") {
+					if c = strings.TrimSpace(c); c != "" {
+						columns = append(columns, c)
+					}
+				}
+			}
+		}
+
+		if i, ok := byName[name]; ok {
+			indexes[i].Columns = append(indexes[i].Columns, columns...)
+			indexes[i].Unique = indexes[i].Unique || opts.Contains("unique")
+			continue
+		}
+
+		byName[name] = len(indexes)
+		indexes = append(indexes, Index{
+			Name:    name,
+			Unique:  opts.Contains("unique"),
+			Columns: columns,
+		})
+	}
+
+	return indexes, nil
+}
+
+// GenCreateIndexSql generates the create index statements of the indexes
+// declared by the sample, e.g. for the migrations
+func GenCreateIndexSql(table string, sample interface{}) ([]string, error) {
+	indexes, err := Indexes(table, sample)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]string, 0, len(indexes))
+	for _, idx := range indexes {
+		unique := ""
+		if idx.Unique {
+			unique = "unique "
+		}
+		ret = append(ret, fmt.Sprintf("create %sindex %s on %s (%s)",
+			unique, idx.Name, table, strings.Join(idx.Columns, ", ")))
+	}
+	return ret, nil
+}
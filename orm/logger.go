@@ -0,0 +1,113 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// LogEntry is the trace of a statement
+type LogEntry struct {
+	SQL      string
+	Args     []interface{}
+	Duration time.Duration
+	// Rows is the number of the rows affected by the exec, -1 for the query
+	Rows int64
+	Err  error
+	// Slow is true if the duration exceeds the slow threshold
+	Slow bool
+}
+
+// Logger is called after each statement is executed
+type Logger interface {
+	Log(ctx context.Context, e *LogEntry)
+}
+
+type LoggerFunc func(ctx context.Context, e *LogEntry)
+
+func (f LoggerFunc) Log(ctx context.Context, e *LogEntry) {
+	f(ctx, e)
+}
+
+// WithLogger sets the logger of the statements, default the klog logger,
+// which logs the statements with -v=3 and the slow statements as warnings
+func WithLogger(logger Logger) DBOption {
+	return func(o *dbOptions) {
+		o.logger = logger
+	}
+}
+
+// WithSlowThreshold marks the statements longer than d as slow, 0 to disable
+func WithSlowThreshold(d time.Duration) DBOption {
+	return func(o *dbOptions) {
+		o.slowThreshold = d
+	}
+}
+
+type klogLogger struct{}
+
+func (klogLogger) Log(ctx context.Context, e *LogEntry) {
+	switch {
+	case e.Slow:
+		klog.Warningf("slow sql %s, rows %d, err %v\n\t%s", e.Duration, e.Rows, e.Err, formatSql(e.SQL, e.Args))
+	case klog.V(3).Enabled():
+		klog.Infof("sql %s, rows %d, err %v\n\t%s", e.Duration, e.Rows, e.Err, formatSql(e.SQL, e.Args))
+	}
+}
+
+// formatSql replaces the placeholders with the args for printing
+func formatSql(query string, args []interface{}) string {
+	args2 := make([]interface{}, len(args))
+
+	for i := 0; i < len(args2); i++ {
+		rv := reflect.Indirect(reflect.ValueOf(args[i]))
+		if rv.IsValid() && rv.CanInterface() {
+			if b, ok := rv.Interface().([]byte); ok {
+				args2[i] = printString(b)
+			} else {
+				args2[i] = rv.Interface()
+			}
+		}
+	}
+	return fmt.Sprintf(strings.Replace(query, "?", "`%v`", -1), args2...)
+}
+
+func printString(b []byte) string {
+	s := make([]byte, len(b))
+
+	for i := 0; i < len(b); i++ {
+		if strconv.IsPrint(rune(b[i])) {
+			s[i] = b[i]
+		} else {
+			s[i] = '.'
+		}
+	}
+	return string(s)
+}
+
+// log reports the statement to the logger and the metrics, typ is exec or query
+func (p *DB) log(typ, query string, args []interface{}, start time.Time, rows int64, err error) {
+	d := time.Since(start)
+
+	if p.metrics != nil {
+		p.metrics.observe(typ, d, err)
+	}
+
+	if p.logger == nil {
+		return
+	}
+
+	p.logger.Log(p.context(), &LogEntry{
+		SQL:      query,
+		Args:     args,
+		Duration: d,
+		Rows:     rows,
+		Err:      err,
+		Slow:     p.slow > 0 && d >= p.slow,
+	})
+}
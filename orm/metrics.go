@@ -0,0 +1,73 @@
+package orm
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally"
+	"github.com/yubo/golib/util"
+	"github.com/yubo/golib/util/metric"
+)
+
+// metricsInterval is the interval of reporting the connection stats
+const metricsInterval = 10 * time.Second
+
+// WithMetrics reports the metrics of the statements and the connection
+// pool to the scope, e.g. the prometheus reporter of the tally:
+//
+//	orm_queries_total{driver,type}    the statements executed, type is exec or query
+//	orm_errors_total{driver,type}     the statements failed
+//	orm_duration_seconds{driver,type} the histogram of the duration
+//	orm_connections{driver,state}     the connections, state is open, in_use or idle
+//	orm_wait_total{driver}            the connections waited for
+func WithMetrics(scope tally.Scope) DBOption {
+	return func(o *dbOptions) {
+		o.metrics = scope
+	}
+}
+
+type dbMetrics struct {
+	driver      string
+	queries     *metric.CounterVec
+	errors      *metric.CounterVec
+	duration    *metric.HistogramVec
+	connections *metric.GaugeVec
+	wait        *metric.GaugeVec
+	stopCh      chan struct{}
+	stopOnce    sync.Once
+}
+
+func newDBMetrics(scope tally.Scope, driver string) *dbMetrics {
+	return &dbMetrics{
+		driver:      driver,
+		queries:     metric.NewCounterVec(scope, "orm_queries_total", []string{"driver", "type"}),
+		errors:      metric.NewCounterVec(scope, "orm_errors_total", []string{"driver", "type"}),
+		duration:    metric.NewHistogramVec(scope, "orm_duration_seconds", metric.DefBuckets, []string{"driver", "type"}),
+		connections: metric.NewGaugeVec(scope, "orm_connections", []string{"driver", "state"}),
+		wait:        metric.NewGaugeVec(scope, "orm_wait_total", []string{"driver"}),
+		stopCh:      make(chan struct{}),
+	}
+}
+
+func (p *dbMetrics) observe(typ string, d time.Duration, err error) {
+	p.queries.WithLabelValues(p.driver, typ).Inc(1)
+	if err != nil {
+		p.errors.WithLabelValues(p.driver, typ).Inc(1)
+	}
+	p.duration.WithLabelValues(p.driver, typ).RecordValue(d.Seconds())
+}
+
+// run reports the stats of the connection pool until stop
+func (p *dbMetrics) run(db *DB) {
+	util.Until(func() {
+		stats := db.DB.Stats()
+		p.connections.WithLabelValues(p.driver, "open").Update(float64(stats.OpenConnections))
+		p.connections.WithLabelValues(p.driver, "in_use").Update(float64(stats.InUse))
+		p.connections.WithLabelValues(p.driver, "idle").Update(float64(stats.Idle))
+		p.wait.WithLabelValues(p.driver).Update(float64(stats.WaitCount))
+	}, metricsInterval, p.stopCh)
+}
+
+func (p *dbMetrics) stop() {
+	p.stopOnce.Do(func() { close(p.stopCh) })
+}
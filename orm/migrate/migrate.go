@@ -0,0 +1,319 @@
+// Package migrate applies the versioned migrations to the database, the
+// applied versions are recorded in the schema_migrations table.
+//
+//	m := migrate.New(db,
+//		migrate.Migration{
+//			Version: 1,
+//			Name:    "create user",
+//			Up:      "CREATE TABLE user (id int, name varchar(32));",
+//			Down:    "DROP TABLE user;",
+//		},
+//	)
+//	proc.RegisterHooks([]proc.HookOps{m.HookOps("my-app")})
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yubo/golib/orm"
+	"github.com/yubo/golib/proc"
+	"k8s.io/klog/v2"
+)
+
+const DefaultTable = "schema_migrations"
+
+// Migration is a versioned step, the Up/Down are the sql statements
+// terminated by ';' at the end of the line, the UpFn/DownFn are run
+// after the statements if not nil. The step is run in a transaction,
+// notice that the DDL is committed implicitly by mysql.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	UpFn    func(tx *orm.DB) error
+	DownFn  func(tx *orm.DB) error
+}
+
+// Status is the state of a registered migration
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Plan is a pending migration with the statements to be executed by Up
+type Plan struct {
+	Version    int64
+	Name       string
+	Statements []string
+	// HasFn is true if the UpFn will be run, it can't be planned
+	HasFn bool
+	// Destructive is true if any statement drops or truncates the data,
+	// e.g. drop table, alter table ... drop column
+	Destructive bool
+}
+
+type Migrator struct {
+	sync.Mutex
+	db         *orm.DB
+	table      string
+	migrations []Migration
+}
+
+// New returns a migrator with the migrations recorded in DefaultTable
+func New(db *orm.DB, migrations ...Migration) *Migrator {
+	p := &Migrator{db: db, table: DefaultTable}
+	for _, m := range migrations {
+		if err := p.Register(m); err != nil {
+			panic(err)
+		}
+	}
+	return p
+}
+
+// WithTable sets the table of the applied versions
+func (p *Migrator) WithTable(table string) *Migrator {
+	p.table = table
+	return p
+}
+
+// Register adds a migration, the version must be positive and unique
+func (p *Migrator) Register(m Migration) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if m.Version <= 0 {
+		return fmt.Errorf("migration %q: version must be positive", m.Name)
+	}
+	for _, v := range p.migrations {
+		if v.Version == m.Version {
+			return fmt.Errorf("migration %d: duplicate version, %q and %q", m.Version, v.Name, m.Name)
+		}
+	}
+
+	p.migrations = append(p.migrations, m)
+	sort.Slice(p.migrations, func(i, j int) bool {
+		return p.migrations[i].Version < p.migrations[j].Version
+	})
+	return nil
+}
+
+// Up applies all the pending migrations in the order of the version
+func (p *Migrator) Up(ctx context.Context) error {
+	p.Lock()
+	defer p.Unlock()
+
+	applied, err := p.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range p.migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		klog.V(1).Infof("migrate up %d %s", m.Version, m.Name)
+		if err := p.db.Transaction(ctx, func(tx *orm.DB) error {
+			if err := run(tx, m.Up, m.UpFn); err != nil {
+				return err
+			}
+			_, err := tx.Exec("insert into "+p.table+" (version, name, applied_at) values (?, ?, ?)",
+				m.Version, m.Name, time.Now().Unix())
+			return err
+		}); err != nil {
+			return fmt.Errorf("migrate up %d %q: %s", m.Version, m.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Down reverts the latest applied migration, it's a no-op if nothing is applied
+func (p *Migrator) Down(ctx context.Context) error {
+	p.Lock()
+	defer p.Unlock()
+
+	applied, err := p.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := len(p.migrations) - 1; i >= 0; i-- {
+		m := p.migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		klog.V(1).Infof("migrate down %d %s", m.Version, m.Name)
+		if err := p.db.Transaction(ctx, func(tx *orm.DB) error {
+			if err := run(tx, m.Down, m.DownFn); err != nil {
+				return err
+			}
+			_, err := tx.Exec("delete from "+p.table+" where version = ?", m.Version)
+			return err
+		}); err != nil {
+			return fmt.Errorf("migrate down %d %q: %s", m.Version, m.Name, err)
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// Status returns the state of the registered migrations in the order of the version
+func (p *Migrator) Status(ctx context.Context) ([]Status, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	applied, err := p.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]Status, len(p.migrations))
+	for i, m := range p.migrations {
+		ret[i] = Status{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			ret[i].Applied = true
+			ret[i].AppliedAt = time.Unix(at, 0)
+		}
+	}
+	return ret, nil
+}
+
+// Plan returns the pending migrations that Up would apply, in the order of
+// the version, without running them, so that the destructive changes can be
+// reviewed before the deployment. Only the table of the applied versions
+// is created if it doesn't exist.
+func (p *Migrator) Plan(ctx context.Context) ([]Plan, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	applied, err := p.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Plan
+	for _, m := range p.migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		plan := Plan{
+			Version:    m.Version,
+			Name:       m.Name,
+			Statements: splitStatements(m.Up),
+			HasFn:      m.UpFn != nil,
+		}
+		for _, stmt := range plan.Statements {
+			if isDestructive(stmt) {
+				plan.Destructive = true
+			}
+		}
+		ret = append(ret, plan)
+	}
+	return ret, nil
+}
+
+// HookOps returns the proc hook to apply the pending migrations at
+// ACTION_START, before the modules are started
+func (p *Migrator) HookOps(owner string) proc.HookOps {
+	return proc.HookOps{
+		Hook:     p.Up,
+		Owner:    owner,
+		HookNum:  proc.ACTION_START,
+		Priority: proc.PRI_SYS_PRESTART,
+	}
+}
+
+// applied returns the applied versions and the unix time of the applying
+func (p *Migrator) applied(ctx context.Context) (map[int64]int64, error) {
+	db := p.db.WithContext(ctx)
+
+	if _, err := db.Exec("create table if not exists " + p.table +
+		" (version bigint not null primary key, name varchar(255) not null, applied_at bigint not null)"); err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Version   int64
+		AppliedAt int64
+	}
+	if err := db.Query("select version, applied_at from "+p.table).Rows(&rows, math.MaxInt32); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[int64]int64, len(rows))
+	for _, v := range rows {
+		ret[v.Version] = v.AppliedAt
+	}
+	return ret, nil
+}
+
+func run(tx *orm.DB, statements string, fn func(tx *orm.DB) error) error {
+	for _, stmt := range splitStatements(statements) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if fn != nil {
+		return fn(tx)
+	}
+	return nil
+}
+
+var destructiveKeywords = []string{"drop ", "truncate ", "delete ", "rename "}
+
+// isDestructive reports whether the statement may lose the data
+func isDestructive(stmt string) bool {
+	stmt = strings.ToLower(stmt)
+	for _, k := range destructiveKeywords {
+		if strings.HasPrefix(stmt, k) {
+			return true
+		}
+	}
+
+	// e.g. alter table user drop column name, alter table user modify name varchar(16)
+	if strings.HasPrefix(stmt, "alter ") {
+		for _, k := range []string{" drop ", " modify ", " change ", " rename "} {
+			if strings.Contains(stmt, k) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitStatements splits the sql by the ';' at the end of the line,
+// the empty lines and the comments starting with "--" are skipped
+func splitStatements(s string) (ret []string) {
+	var buf []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+
+		buf = append(buf, line)
+		if strings.HasSuffix(line, ";") {
+			ret = append(ret, strings.Join(buf, " "))
+			buf = buf[:0]
+		}
+	}
+
+	if len(buf) > 0 {
+		ret = append(ret, strings.Join(buf, " "))
+	}
+	return
+}
@@ -0,0 +1,124 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yubo/golib/orm"
+	_ "github.com/yubo/golib/orm/sqlite"
+)
+
+func TestSplitStatements(t *testing.T) {
+	assert.Equal(t, []string{
+		"CREATE TABLE user ( id int, name varchar(32) );",
+		"INSERT INTO user VALUES (1, 'tom');",
+	}, splitStatements(`
+-- user
+CREATE TABLE user (
+	id int,
+	name varchar(32)
+);
+
+INSERT INTO user VALUES (1, 'tom');
+`))
+}
+
+func TestIsDestructive(t *testing.T) {
+	for stmt, want := range map[string]bool{
+		"CREATE TABLE user (id int);":             false,
+		"ALTER TABLE user ADD COLUMN age int;":    false,
+		"CREATE INDEX idx_name ON user (name);":   false,
+		"DROP TABLE user;":                        true,
+		"ALTER TABLE user DROP COLUMN age;":       true,
+		"alter table user modify name varchar(8)": true,
+		"TRUNCATE TABLE user;":                    true,
+	} {
+		assert.Equal(t, want, isDestructive(stmt), stmt)
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := orm.DbOpen("sqlite3", "file:migrate.db?cache=shared&mode=memory")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	m := New(db, Migration{
+		Version: 2,
+		Name:    "add user",
+		UpFn: func(tx *orm.DB) error {
+			return tx.Insert("user", struct {
+				Id   int
+				Name string
+			}{1, "tom"})
+		},
+		DownFn: func(tx *orm.DB) error {
+			_, err := tx.Exec("DELETE FROM user WHERE id = ?", 1)
+			return err
+		},
+	}, Migration{
+		Version: 1,
+		Name:    "create user",
+		Up:      "CREATE TABLE user (id int, name varchar(32));",
+		Down:    "DROP TABLE user;",
+	})
+
+	assert.Error(t, m.Register(Migration{Version: 1, Name: "dup"}))
+
+	plans, err := m.Plan(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []Plan{
+		{Version: 1, Name: "create user", Statements: []string{"CREATE TABLE user (id int, name varchar(32));"}},
+		{Version: 2, Name: "add user", HasFn: true},
+	}, plans)
+	// nothing is applied by the plan
+	assert.Error(t, db.Query("select count(*) from user").Row(new(int)))
+
+	assert.NoError(t, m.Up(ctx))
+	// idempotent
+	assert.NoError(t, m.Up(ctx))
+
+	var n int
+	assert.NoError(t, db.Query("select count(*) from user").Row(&n))
+	assert.Equal(t, 1, n)
+
+	status, err := m.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(status))
+	assert.Equal(t, int64(1), status[0].Version)
+	assert.True(t, status[0].Applied)
+	assert.True(t, status[1].Applied)
+
+	plans, err = m.Plan(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(plans))
+
+	assert.NoError(t, m.Down(ctx))
+	assert.NoError(t, db.Query("select count(*) from user").Row(&n))
+	assert.Equal(t, 0, n)
+
+	status, err = m.Status(ctx)
+	assert.NoError(t, err)
+	assert.True(t, status[0].Applied)
+	assert.False(t, status[1].Applied)
+
+	assert.NoError(t, m.Down(ctx))
+	assert.Error(t, db.Query("select count(*) from user").Row(&n))
+
+	// the failed step is rolled back
+	assert.NoError(t, m.Register(Migration{
+		Version: 3,
+		Name:    "bad",
+		UpFn: func(tx *orm.DB) error {
+			return fmt.Errorf("bad")
+		},
+	}))
+	assert.Error(t, m.Up(ctx))
+
+	status, err = m.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, true, false}, []bool{status[0].Applied, status[1].Applied, status[2].Applied})
+}
@@ -0,0 +1,90 @@
+package orm
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/yubo/golib/util"
+)
+
+// NamingStrategy maps the go names to the names of the database, it's
+// used by the columns of the struct fields, the default tables of the
+// stores and the relations, and the foreign keys of the relations
+type NamingStrategy interface {
+	// TableName returns the table of the struct type name, e.g. User -> user
+	TableName(name string) string
+	// ColumnName returns the column of the struct field name if the sql tag is not set
+	ColumnName(name string) string
+}
+
+// Naming is the default NamingStrategy, e.g.
+//
+//	orm.SetNamingStrategy(orm.Naming{TablePrefix: "t_", Plural: true})
+//
+// maps the struct UserGroup to the table t_user_groups
+type Naming struct {
+	// TablePrefix is prepended to the tables, e.g. "t_"
+	TablePrefix string
+	// Plural uses the plural tables, e.g. user -> users
+	Plural bool
+	// NameMapper maps the names, default is the snake cased name
+	NameMapper util.NameMapper
+}
+
+func (p Naming) mapName(name string) string {
+	if p.NameMapper != nil {
+		return p.NameMapper.Map(name)
+	}
+	return snakeCasedName(name)
+}
+
+func (p Naming) TableName(name string) string {
+	name = p.mapName(name)
+	if p.Plural {
+		name = pluralize(name)
+	}
+	return p.TablePrefix + name
+}
+
+func (p Naming) ColumnName(name string) string {
+	return p.mapName(name)
+}
+
+var naming NamingStrategy = Naming{}
+
+// SetNamingStrategy sets the naming strategy, it should be called before any query
+func SetNamingStrategy(s NamingStrategy) {
+	naming = s
+	fieldCache.Range(func(k, _ interface{}) bool {
+		fieldCache.Delete(k)
+		return true
+	})
+}
+
+// SetNameMapper sets the naming strategy of the tables and the columns,
+// e.g. util.NewNameMapper(util.SnakeCase), it should be called before any query
+func SetNameMapper(m util.NameMapper) {
+	SetNamingStrategy(Naming{NameMapper: m})
+}
+
+// TableName returns the table of the sample by the naming strategy
+func TableName(sample interface{}) string {
+	return naming.TableName(indirectType(reflect.TypeOf(sample)).Name())
+}
+
+// pluralize returns the plural of the english noun, e.g.
+// user -> users, class -> classes, policy -> policies
+func pluralize(name string) string {
+	switch {
+	case name == "":
+		return name
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"),
+		strings.HasSuffix(name, "z"), strings.HasSuffix(name, "ch"),
+		strings.HasSuffix(name, "sh"):
+		return name + "es"
+	case strings.HasSuffix(name, "y") && len(name) > 1 &&
+		!strings.ContainsRune("aeiou", rune(name[len(name)-2])):
+		return name[:len(name)-1] + "ies"
+	}
+	return name + "s"
+}
@@ -0,0 +1,141 @@
+package orm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// PageOptions is the options of ListPage, the rows are paged by the
+// offset, or by the cursor (keyset) if the CursorKey is set
+type PageOptions struct {
+	Limit  int64 `json:"limit" description:"the number of the items of the page"`
+	Offset int64 `json:"offset" description:"the offset of the offset paging"`
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string `json:"cursor" description:"the cursor of the keyset paging"`
+	// CursorKey is the unique and ordered column of the keyset paging, e.g. id
+	CursorKey string `json:"-"`
+	// Desc pages the rows in the descending order of the CursorKey
+	Desc bool `json:"-"`
+	// WithTotal counts the total number of the rows
+	WithTotal bool `json:"-"`
+}
+
+type Page struct {
+	Total      int64       `json:"total,omitempty"`
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+// ListPage scans a page of the rows into dst, a pointer to a slice,
+// the total is counted without the paging if opts.WithTotal is set.
+// The NextCursor is set for the keyset paging if there are more rows,
+// the query should not be ordered by other columns in that case.
+func (p *Query) ListPage(dst interface{}, opts PageOptions) (*Page, error) {
+	if opts.Limit <= 0 {
+		return nil, fmt.Errorf("page limit must be positive")
+	}
+
+	page := &Page{Items: dst}
+
+	if opts.WithTotal {
+		n, err := p.Count()
+		if err != nil {
+			return nil, err
+		}
+		page.Total = n
+	}
+
+	if opts.CursorKey == "" {
+		q := *p
+		if err := q.Limit(opts.Limit).Offset(opts.Offset).Rows(dst); err != nil {
+			return nil, err
+		}
+		return page, nil
+	}
+
+	// don't append to the where of p
+	q := *p
+	q.where = q.where[:len(q.where):len(q.where)]
+	q.whereArgs = q.whereArgs[:len(q.whereArgs):len(q.whereArgs)]
+
+	op, order := ">", opts.CursorKey
+	if opts.Desc {
+		op, order = "<", opts.CursorKey+" desc"
+	}
+
+	if opts.Cursor != "" {
+		v, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		q.Where(fmt.Sprintf("%s %s ?", opts.CursorKey, op), v)
+	}
+
+	// fetch one more row to know if there is a next page
+	q.orderBy = []string{order}
+	q.limit, q.offset = opts.Limit+1, 0
+	if err := q.Rows(dst, int(opts.Limit+1)); err != nil {
+		return nil, err
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(dst))
+	if int64(rv.Len()) <= opts.Limit {
+		return page, nil
+	}
+	rv.Set(rv.Slice(0, int(opts.Limit)))
+
+	v, ok, err := columnValue(rv.Index(rv.Len()-1), cursorColumn(opts.CursorKey))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("the cursor %s of the last row is null", opts.CursorKey)
+	}
+	if page.NextCursor, err = encodeCursor(v); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// cursorColumn returns the column of the key, without the table alias, e.g. u.id -> id
+func cursorColumn(key string) string {
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		return key[i+1:]
+	}
+	return key
+}
+
+func encodeCursor(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(cursor string) (interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor %q: %s", cursor, err)
+	}
+
+	d := json.NewDecoder(strings.NewReader(string(b)))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid cursor %q: %s", cursor, err)
+	}
+
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		return n.Float64()
+	}
+	return v, nil
+}
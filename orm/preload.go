@@ -0,0 +1,211 @@
+package orm
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+const (
+	hasOne    = "has_one"
+	hasMany   = "has_many"
+	belongsTo = "belongs_to"
+)
+
+// relation is declared by the sql tag of the struct field, e.g.
+//
+//	type User struct {
+//		Id      int64
+//		Profile *Profile `sql:",has_one"`                          // profile.user_id = user.id
+//		Orders  []Order  `sql:",has_many,table=orders,fk=owner_id"` // orders.owner_id = user.id
+//	}
+//
+//	type Order struct {
+//		Id      int64
+//		OwnerId int64
+//		Owner   *User `sql:",belongs_to,table=user,fk=owner_id"` // user.id = order.owner_id
+//	}
+//
+// the table is default the snake cased name of the related struct, the fk
+// is default the name of the owner struct with "_id" suffix, and the refs
+// is default "id", the column referenced by the fk.
+type relation struct {
+	kind  string
+	table string
+	fk    string
+	refs  string
+}
+
+// Preload loads the relation fields of the rows after scanning, with a
+// secondary query per relation, e.g. Preload("Orders", "Profile")
+func (p *Query) Preload(fields ...string) *Query {
+	p.preloads = append(p.preloads, fields...)
+	return p
+}
+
+// preload populates the relation fields of the parents, a slice of struct or *struct
+func preload(db *DB, parents reflect.Value, names []string) error {
+	if parents.Len() == 0 {
+		return nil
+	}
+
+	parentType := indirectType(parents.Type().Elem())
+	parentFields := cachedTypeFields(parentType)
+
+	for _, name := range names {
+		f, ok := parentFields.relations[name]
+		if !ok {
+			return fmt.Errorf("preload: %s has no relation %s", parentType, name)
+		}
+
+		if err := preloadRelation(db, parents, parentType, name, f); err != nil {
+			return fmt.Errorf("preload %s.%s: %s", parentType.Name(), name, err)
+		}
+	}
+
+	return nil
+}
+
+func preloadRelation(db *DB, parents reflect.Value, parentType reflect.Type, name string, f field) error {
+	rel := f.relation
+	childType := indirectType(f.typ)
+	if childType.Kind() == reflect.Slice {
+		childType = indirectType(childType.Elem())
+	}
+	if childType.Kind() != reflect.Struct {
+		return fmt.Errorf("relation must be a struct, got %s", f.typ)
+	}
+
+	table := rel.table
+	if table == "" {
+		table = naming.TableName(childType.Name())
+	}
+
+	// the column of the parent and the child to join
+	var parentKey, childKey string
+	switch rel.kind {
+	case hasOne, hasMany:
+		parentKey, childKey = rel.refs, rel.fk
+		if parentKey == "" {
+			parentKey = "id"
+		}
+		if childKey == "" {
+			childKey = naming.ColumnName(parentType.Name()) + "_id"
+		}
+	case belongsTo:
+		parentKey, childKey = rel.fk, rel.refs
+		if parentKey == "" {
+			parentKey = naming.ColumnName(name) + "_id"
+		}
+		if childKey == "" {
+			childKey = "id"
+		}
+	}
+
+	childFields := cachedTypeFields(childType)
+	if _, ok := childFields.nameIndex[childKey]; !ok {
+		return fmt.Errorf("%s has no column %s", childType, childKey)
+	}
+
+	keys := make([]string, parents.Len())
+	args := []interface{}{}
+	seen := map[string]bool{}
+	for i := 0; i < parents.Len(); i++ {
+		v, ok, err := columnValue(parents.Index(i), parentKey)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		keys[i] = fmt.Sprint(v)
+		if !seen[keys[i]] {
+			seen[keys[i]] = true
+			args = append(args, v)
+		}
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	children := reflect.New(reflect.SliceOf(reflect.PtrTo(childType)))
+	if err := NewQuery(db).Table(table).
+		Where(fmt.Sprintf("%s in (%s)", childKey, strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")), args...).
+		Rows(children.Interface(), math.MaxInt32); err != nil {
+		return err
+	}
+
+	groups := map[string][]reflect.Value{}
+	children = children.Elem()
+	for i := 0; i < children.Len(); i++ {
+		child := children.Index(i)
+		v, ok, err := columnValue(child, childKey)
+		if err != nil {
+			return err
+		}
+		if ok {
+			k := fmt.Sprint(v)
+			groups[k] = append(groups[k], child)
+		}
+	}
+
+	for i := 0; i < parents.Len(); i++ {
+		matches := groups[keys[i]]
+		if keys[i] == "" || len(matches) == 0 {
+			continue
+		}
+
+		fv, err := getSubv(reflect.Indirect(parents.Index(i)), f.index, true)
+		if err != nil {
+			return err
+		}
+
+		if fv.Kind() == reflect.Slice {
+			s := reflect.MakeSlice(fv.Type(), 0, len(matches))
+			for _, child := range matches {
+				if fv.Type().Elem().Kind() != reflect.Ptr {
+					child = child.Elem()
+				}
+				s = reflect.Append(s, child)
+			}
+			fv.Set(s)
+			continue
+		}
+
+		if fv.Kind() == reflect.Ptr {
+			fv.Set(matches[0])
+		} else {
+			fv.Set(matches[0].Elem())
+		}
+	}
+
+	return nil
+}
+
+// columnValue returns the value of the column field of the struct or *struct,
+// ok is false if the value is nil
+func columnValue(rv reflect.Value, key string) (v interface{}, ok bool, err error) {
+	rv = reflect.Indirect(rv)
+	fields := cachedTypeFields(rv.Type())
+	i, found := fields.nameIndex[key]
+	if !found {
+		return nil, false, fmt.Errorf("%s has no column %s", rv.Type(), key)
+	}
+
+	fv, err := getSubv(rv, fields.list[i].index, false)
+	if err != nil || isNil(fv) {
+		return nil, false, nil
+	}
+
+	return reflect.Indirect(fv).Interface(), true, nil
+}
+
+func indirectType(rt reflect.Type) reflect.Type {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt
+}
@@ -0,0 +1,303 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/labels"
+)
+
+// Query is a builder of the select statement, e.g.
+//
+//	NewQuery(db).Table("user u").
+//		Select("u.name", "count(*) as n").
+//		Join("left join post p on p.user_id = u.id").
+//		Where("u.status = ?", "active").
+//		GroupBy("u.name").
+//		Having("count(*) > ?", 10).
+//		OrderBy("n desc").
+//		Limit(10).
+//		Rows(&rows)
+//
+// the placeholders are rebound by the DB for the driver, e.g. $1 for postgres.
+// If the struct of the rows has a field tagged with `sql:",softdelete"`,
+// the soft-deleted rows are filtered out unless WithDeleted is called
+type Query struct {
+	db         *DB
+	table      string
+	fields     []string
+	joins      []string
+	joinArgs   []interface{}
+	where      []string
+	whereArgs  []interface{}
+	groupBy    []string
+	having     []string
+	havingArgs []interface{}
+	orderBy    []string
+	limit      int64
+	offset     int64
+
+	softDelete  string
+	withDeleted bool
+	preloads    []string
+
+	err error // the error of the builder, returned by the execution
+}
+
+func NewQuery(db *DB) *Query {
+	return &Query{db: db}
+}
+
+// Table sets the table of the from clause, with an optional alias, e.g. "user u"
+func (p *Query) Table(table string) *Query {
+	p.table = table
+	return p
+}
+
+// Select sets the select fields, default "*"
+func (p *Query) Select(fields ...string) *Query {
+	p.fields = append(p.fields, fields...)
+	return p
+}
+
+// Join appends a join clause, e.g. "left join post p on p.user_id = u.id and p.status = ?"
+func (p *Query) Join(clause string, args ...interface{}) *Query {
+	p.joins = append(p.joins, clause)
+	p.joinArgs = append(p.joinArgs, args...)
+	return p
+}
+
+// Where appends a condition, the conditions are ANDed
+func (p *Query) Where(query string, args ...interface{}) *Query {
+	p.where = append(p.where, query)
+	p.whereArgs = append(p.whereArgs, args...)
+	return p
+}
+
+// WithSelector appends the requirements of the selector as the where conditions
+func (p *Query) WithSelector(selector labels.Selector) *Query {
+	if selector == nil || selector.Empty() {
+		return p
+	}
+
+	reqs, selectable := selector.Requirements()
+	if !selectable {
+		// select nothing
+		return p.Where("1 = 0")
+	}
+
+	if query, args := reqs.SQL(); query != "" {
+		p.Where(query, args...)
+	}
+	return p
+}
+
+// WithPagination applies the offset, limit, sort and query of the list input,
+// the error of the invalid sort or query is returned by the execution
+func (p *Query) WithPagination(pg api.Pagination) *Query {
+	orderBy, err := pg.OrderBy()
+	if err != nil {
+		p.err = err
+		return p
+	}
+
+	selector, err := pg.Selector()
+	if err != nil {
+		p.err = err
+		return p
+	}
+
+	return p.WithSelector(selector).OrderBy(orderBy...).Limit(pg.Limit).Offset(pg.Offset)
+}
+
+// Model sets the struct of the rows, it's needed by Count and SQL to filter
+// out the soft-deleted rows, Row and Rows set it from the dst
+func (p *Query) Model(sample interface{}) *Query {
+	p.softDelete = softDeleteKey(reflect.TypeOf(sample))
+	return p
+}
+
+// WithDeleted includes the soft-deleted rows
+func (p *Query) WithDeleted() *Query {
+	p.withDeleted = true
+	return p
+}
+
+func (p *Query) GroupBy(fields ...string) *Query {
+	p.groupBy = append(p.groupBy, fields...)
+	return p
+}
+
+// Having appends a condition of the group by, the conditions are ANDed
+func (p *Query) Having(query string, args ...interface{}) *Query {
+	p.having = append(p.having, query)
+	p.havingArgs = append(p.havingArgs, args...)
+	return p
+}
+
+// OrderBy appends the order fields, e.g. "name", "id desc"
+func (p *Query) OrderBy(fields ...string) *Query {
+	p.orderBy = append(p.orderBy, fields...)
+	return p
+}
+
+func (p *Query) Limit(limit int64) *Query {
+	p.limit = limit
+	return p
+}
+
+func (p *Query) Offset(offset int64) *Query {
+	p.offset = offset
+	return p
+}
+
+// SQL returns the select statement and the args
+func (p *Query) SQL() (string, []interface{}, error) {
+	return p.sql(p.fields, true)
+}
+
+func (p *Query) sql(fields []string, paging bool) (string, []interface{}, error) {
+	if p.err != nil {
+		return "", nil, p.err
+	}
+
+	if p.table == "" {
+		return "", nil, fmt.Errorf("query table is empty")
+	}
+
+	buf := &strings.Builder{}
+	args := []interface{}{}
+
+	if len(fields) == 0 {
+		fields = []string{"*"}
+	}
+	fmt.Fprintf(buf, "select %s from %s", strings.Join(fields, ", "), p.table)
+
+	for _, join := range p.joins {
+		buf.WriteString(" " + join)
+	}
+	args = append(args, p.joinArgs...)
+
+	where := p.where
+	if p.softDelete != "" && !p.withDeleted {
+		where = append(where[:len(where):len(where)], p.qualify(p.softDelete)+" is null")
+	}
+
+	if len(where) > 0 {
+		buf.WriteString(" where " + joinConditions(where))
+		args = append(args, p.whereArgs...)
+	}
+
+	if len(p.groupBy) > 0 {
+		buf.WriteString(" group by " + strings.Join(p.groupBy, ", "))
+	}
+
+	if len(p.having) > 0 {
+		buf.WriteString(" having " + joinConditions(p.having))
+		args = append(args, p.havingArgs...)
+	}
+
+	if !paging {
+		return buf.String(), args, nil
+	}
+
+	if len(p.orderBy) > 0 {
+		buf.WriteString(" order by " + strings.Join(p.orderBy, ", "))
+	}
+
+	if p.limit > 0 {
+		fmt.Fprintf(buf, " limit %d", p.limit)
+	} else if p.offset > 0 && p.db.dialect.noLimit != "" {
+		// mysql and sqlite don't accept the offset without the limit
+		buf.WriteString(" limit " + p.db.dialect.noLimit)
+	}
+
+	if p.offset > 0 {
+		fmt.Fprintf(buf, " offset %d", p.offset)
+	}
+
+	return buf.String(), args, nil
+}
+
+// qualify prefixes the column with the alias or the name of the table
+// if there are joins, to avoid the ambiguous column, e.g. "u.deleted_at"
+func (p *Query) qualify(column string) string {
+	if len(p.joins) == 0 || strings.Contains(column, ".") {
+		return column
+	}
+
+	fields := strings.Fields(p.table)
+	return fields[len(fields)-1] + "." + column
+}
+
+// joinConditions ANDs the conditions, the condition is parenthesized
+// if there are more than one, to keep the precedence of the "or" in it
+func joinConditions(conds []string) string {
+	if len(conds) == 1 {
+		return conds[0]
+	}
+	return "(" + strings.Join(conds, ") and (") + ")"
+}
+
+// Query executes the select statement
+func (p *Query) Query() *Rows {
+	sql, args, err := p.SQL()
+	if err != nil {
+		return &Rows{err: err}
+	}
+	return p.db.Query(sql, args...)
+}
+
+// Row scans the first row into dst, see Rows.Row
+func (p *Query) Row(dst ...interface{}) error {
+	if len(dst) == 1 && p.softDelete == "" {
+		p.Model(dst[0])
+	}
+	if err := p.Query().Row(dst...); err != nil {
+		return err
+	}
+
+	if len(p.preloads) == 0 || len(dst) != 1 {
+		return nil
+	}
+	parents := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(dst[0])), 1, 1)
+	parents.Index(0).Set(reflect.ValueOf(dst[0]))
+	return preload(p.db, parents, p.preloads)
+}
+
+// Rows scans the rows into dst, see Rows.Rows
+func (p *Query) Rows(dst interface{}, opts ...int) error {
+	if p.softDelete == "" {
+		p.Model(dst)
+	}
+	if err := p.Query().Rows(dst, opts...); err != nil {
+		return err
+	}
+
+	if len(p.preloads) == 0 {
+		return nil
+	}
+	return preload(p.db, reflect.Indirect(reflect.ValueOf(dst)), p.preloads)
+}
+
+// ForEach scans the rows one by one into dst, see Rows.ForEach,
+// the relations are not preloaded
+func (p *Query) ForEach(dst interface{}, fn func() error) error {
+	if p.softDelete == "" {
+		p.Model(dst)
+	}
+	return p.Query().ForEach(dst, fn)
+}
+
+// Count returns the number of the rows without the order, limit and offset
+func (p *Query) Count() (n int64, err error) {
+	sql, args, err := p.sql(p.fields, false)
+	if err != nil {
+		return 0, err
+	}
+
+	err = p.db.Query("select count(*) from ("+sql+") t", args...).Row(&n)
+	return
+}
@@ -0,0 +1,158 @@
+package orm
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yubo/golib/util"
+	"k8s.io/klog/v2"
+)
+
+// replicaCheckInterval is the interval of the health checking of the replicas
+const replicaCheckInterval = 10 * time.Second
+
+type ReplicaPolicy string
+
+const (
+	RoundRobin ReplicaPolicy = "round-robin"
+	// LeastConn picks the replica with the fewest connections in use
+	LeastConn ReplicaPolicy = "least-conn"
+)
+
+// WithReplicas opens the replicas with the same driver, the select
+// statements out of the transactions are routed to the healthy replicas,
+// the others are run on the primary. The unhealthy replica is evicted until
+// it can be pinged again, the primary is used if no replica is healthy.
+func WithReplicas(policy ReplicaPolicy, dsns ...string) DBOption {
+	return func(o *dbOptions) {
+		o.replicaPolicy = policy
+		o.replicas = dsns
+	}
+}
+
+type replica struct {
+	dsn     string
+	db      *sql.DB
+	healthy bool
+}
+
+type replicaPool struct {
+	sync.RWMutex
+	policy   ReplicaPolicy
+	replicas []*replica
+	next     uint32
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+func newReplicaPool(driver string, policy ReplicaPolicy, dsns []string) (*replicaPool, error) {
+	switch policy {
+	case "":
+		policy = RoundRobin
+	case RoundRobin, LeastConn:
+	default:
+		return nil, fmt.Errorf("unsupported replica policy %q", policy)
+	}
+
+	p := &replicaPool{policy: policy, stopCh: make(chan struct{})}
+	for _, dsn := range dsns {
+		db, err := sql.Open(driver, dsn)
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		p.replicas = append(p.replicas, &replica{dsn: dsn, db: db, healthy: true})
+	}
+
+	util.Until(p.check, replicaCheckInterval, p.stopCh)
+
+	return p, nil
+}
+
+// check pings the replicas, and updates the health of them
+func (p *replicaPool) check() {
+	for _, r := range p.replicas {
+		err := r.db.Ping()
+
+		p.Lock()
+		if healthy := err == nil; healthy != r.healthy {
+			r.healthy = healthy
+			if healthy {
+				klog.Infof("replica %s is back", util.RedactDSN(r.dsn))
+			} else {
+				klog.Warningf("replica %s is evicted: %s", util.RedactDSN(r.dsn), err)
+			}
+		}
+		p.Unlock()
+	}
+}
+
+// get returns a healthy replica, or nil
+func (p *replicaPool) get() *sql.DB {
+	p.RLock()
+	defer p.RUnlock()
+
+	healthy := make([]*replica, 0, len(p.replicas))
+	for _, r := range p.replicas {
+		if r.healthy {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	if p.policy == LeastConn {
+		ret := healthy[0].db
+		inUse := ret.Stats().InUse
+		for _, r := range healthy[1:] {
+			if n := r.db.Stats().InUse; n < inUse {
+				ret, inUse = r.db, n
+			}
+		}
+		return ret
+	}
+
+	n := atomic.AddUint32(&p.next, 1)
+	return healthy[int(n-1)%len(healthy)].db
+}
+
+func (p *replicaPool) close() {
+	p.stopOnce.Do(func() { close(p.stopCh) })
+	for _, r := range p.replicas {
+		r.db.Close()
+	}
+}
+
+// Primary returns a shallow copy of the DB, the statements of which are
+// run on the primary, e.g. to read the rows just written
+func (p *DB) Primary() *DB {
+	db := *p
+	db.replicas = nil
+	return &db
+}
+
+// readSession returns the session of a replica for the select statement
+func (p *DB) readSession(query string) session {
+	if p.replicas == nil || p.tx != nil || !isReadOnly(query) {
+		return p.session
+	}
+
+	if db := p.replicas.get(); db != nil {
+		return db
+	}
+	return p.session
+}
+
+// isReadOnly reports whether the statement is a select without locking
+func isReadOnly(query string) bool {
+	q := strings.ToLower(strings.TrimSpace(query))
+	return strings.HasPrefix(q, "select") &&
+		!strings.Contains(q, " for update") &&
+		!strings.Contains(q, " for share") &&
+		!strings.Contains(q, " lock in share mode")
+}
@@ -0,0 +1,142 @@
+package orm
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Serializer encodes the field into the column and decodes it back,
+// it's selected per field by the `serializer` tag, e.g.
+//
+//	type User struct {
+//		Name    string
+//		Profile *pb.Profile `serializer:"proto"`
+//		Tags    []string    `serializer:"csv"`
+//	}
+//
+// the struct, map and slice fields without the tag are serialized as json
+type Serializer interface {
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes data into v, v is the pointer to the field
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	serializersMu sync.RWMutex
+	serializers   = map[string]Serializer{
+		"json":  jsonSerializer{},
+		"gob":   gobSerializer{},
+		"proto": protoSerializer{},
+		"csv":   csvSerializer{},
+	}
+)
+
+// RegisterSerializer makes the serializer available by the name in the `serializer` tag
+func RegisterSerializer(name string, s Serializer) {
+	serializersMu.Lock()
+	defer serializersMu.Unlock()
+
+	serializers[name] = s
+}
+
+func getSerializer(name string) (Serializer, error) {
+	serializersMu.RLock()
+	defer serializersMu.RUnlock()
+
+	if s, ok := serializers[name]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("serializer %q is not registered", name)
+}
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonSerializer) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type gobSerializer struct{}
+
+func (gobSerializer) Marshal(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobSerializer) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// protoSerializer supports the fields of the proto.Message, e.g. *pb.Profile
+type protoSerializer struct{}
+
+func (protoSerializer) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		// the pointer field is dereferenced before marshaling
+		rv := reflect.New(reflect.TypeOf(v))
+		rv.Elem().Set(reflect.ValueOf(v))
+		m, ok = rv.Interface().(proto.Message)
+	}
+	if !ok {
+		return nil, fmt.Errorf("proto: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protoSerializer) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("proto: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// csvSerializer supports the []string fields, e.g. "a,b,c"
+type csvSerializer struct{}
+
+func (csvSerializer) Marshal(v interface{}) ([]byte, error) {
+	list, ok := v.([]string)
+	if !ok {
+		return nil, fmt.Errorf("csv: %T is not a []string", v)
+	}
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if err := w.Write(list); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\r\n"), nil
+}
+
+func (csvSerializer) Unmarshal(data []byte, v interface{}) error {
+	list, ok := v.(*[]string)
+	if !ok {
+		return fmt.Errorf("csv: %T is not a *[]string", v)
+	}
+
+	if len(data) == 0 {
+		*list = []string{}
+		return nil
+	}
+
+	record, err := csv.NewReader(bytes.NewReader(data)).Read()
+	if err != nil {
+		return err
+	}
+	*list = record
+	return nil
+}
@@ -0,0 +1,72 @@
+package orm
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SqliteOptions are the pragmas of the sqlite3 connections, which are
+// set by the params of the dsn, the params in the dsn take precedence
+type SqliteOptions struct {
+	// JournalMode e.g. WAL, to allow the readers while writing
+	JournalMode string
+	// BusyTimeout is the time to wait for the lock before "database is locked"
+	BusyTimeout time.Duration
+	// ForeignKeys enforces the foreign key constraints
+	ForeignKeys bool
+	// Synchronous e.g. NORMAL, which is safe with WAL
+	Synchronous string
+}
+
+// WithSqlite sets the pragmas of the sqlite3 connections, it's ignored by the other drivers
+func WithSqlite(opts SqliteOptions) DBOption {
+	return func(o *dbOptions) {
+		o.sqlite = &opts
+	}
+}
+
+// params returns the dsn params of the go-sqlite3 driver
+func (p *SqliteOptions) params() url.Values {
+	v := url.Values{}
+	if p.JournalMode != "" {
+		v.Set("_journal_mode", p.JournalMode)
+	}
+	if p.BusyTimeout > 0 {
+		v.Set("_busy_timeout", strconv.FormatInt(int64(p.BusyTimeout/time.Millisecond), 10))
+	}
+	if p.ForeignKeys {
+		v.Set("_foreign_keys", "1")
+	}
+	if p.Synchronous != "" {
+		v.Set("_synchronous", p.Synchronous)
+	}
+	return v
+}
+
+// sqliteDSN appends the params of the options to the dsn,
+// e.g. "file:test.db?cache=shared&_journal_mode=WAL"
+func sqliteDSN(dsn string, opts *SqliteOptions) string {
+	query := ""
+	if i := strings.IndexByte(dsn, '?'); i >= 0 {
+		query = dsn[i+1:]
+	}
+	exists, _ := url.ParseQuery(query)
+
+	params := opts.params()
+	for k := range params {
+		if _, ok := exists[k]; ok {
+			params.Del(k)
+		}
+	}
+	if len(params) == 0 {
+		return dsn
+	}
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + params.Encode()
+}
@@ -0,0 +1,184 @@
+package orm
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// WithStmtCache caches at most size prepared statements keyed by the sql,
+// the least recently used statement is closed when the cache is full
+func WithStmtCache(size int) DBOption {
+	return func(o *dbOptions) {
+		o.stmtCacheSize = size
+	}
+}
+
+// StmtCacheStats is the statistics of the prepared statement cache
+type StmtCacheStats struct {
+	Size      int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// HitRate returns the ratio of the hits, 0 if there is no request
+func (p StmtCacheStats) HitRate() float64 {
+	if total := p.Hits + p.Misses; total > 0 {
+		return float64(p.Hits) / float64(total)
+	}
+	return 0
+}
+
+type stmtEntry struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+// stmtCache is a LRU cache of the prepared statements, the statement in use
+// is closed after it's released if it has been evicted
+type stmtCache struct {
+	sync.Mutex
+	db    *sql.DB
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	stats StmtCacheStats
+}
+
+func newStmtCache(db *sql.DB, size int) *stmtCache {
+	return &stmtCache{
+		db:    db,
+		size:  size,
+		ll:    list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+func (p *stmtCache) get(ctx context.Context, query string) (*stmtEntry, error) {
+	p.Lock()
+	if e, ok := p.items[query]; ok {
+		p.ll.MoveToFront(e)
+		entry := e.Value.(*stmtEntry)
+		entry.refs++
+		p.stats.Hits++
+		p.Unlock()
+		return entry, nil
+	}
+	p.stats.Misses++
+	p.Unlock()
+
+	stmt, err := p.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	// prepared by another goroutine in the meantime
+	if e, ok := p.items[query]; ok {
+		stmt.Close()
+		entry := e.Value.(*stmtEntry)
+		entry.refs++
+		return entry, nil
+	}
+
+	entry := &stmtEntry{query: query, stmt: stmt, refs: 1}
+	p.items[query] = p.ll.PushFront(entry)
+
+	for p.ll.Len() > p.size {
+		e := p.ll.Back()
+		evicted := e.Value.(*stmtEntry)
+		p.ll.Remove(e)
+		delete(p.items, evicted.query)
+		p.stats.Evictions++
+
+		evicted.evicted = true
+		if evicted.refs == 0 {
+			evicted.stmt.Close()
+		}
+	}
+
+	return entry, nil
+}
+
+func (p *stmtCache) release(entry *stmtEntry) {
+	p.Lock()
+	defer p.Unlock()
+
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+func (p *stmtCache) getStats() StmtCacheStats {
+	p.Lock()
+	defer p.Unlock()
+
+	stats := p.stats
+	stats.Size = p.ll.Len()
+	return stats
+}
+
+func (p *stmtCache) close() {
+	p.Lock()
+	defer p.Unlock()
+
+	for e := p.ll.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*stmtEntry)
+		entry.evicted = true
+		if entry.refs == 0 {
+			entry.stmt.Close()
+		}
+	}
+	p.ll.Init()
+	p.items = map[string]*list.Element{}
+}
+
+// stmtSession runs the statements with the cached prepared statements
+type stmtSession struct {
+	cache *stmtCache
+	tx    *sql.Tx
+}
+
+func (p *stmtSession) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	entry, err := p.cache.get(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer p.cache.release(entry)
+
+	if p.tx != nil {
+		return p.tx.StmtContext(ctx, entry.stmt).ExecContext(ctx, args...)
+	}
+	return entry.stmt.ExecContext(ctx, args...)
+}
+
+// QueryContext releases the statement before the rows are closed,
+// the rows keep the statement alive until then
+func (p *stmtSession) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	entry, err := p.cache.get(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer p.cache.release(entry)
+
+	if p.tx != nil {
+		return p.tx.StmtContext(ctx, entry.stmt).QueryContext(ctx, args...)
+	}
+	return entry.stmt.QueryContext(ctx, args...)
+}
+
+// StmtCacheStats returns the statistics of the prepared statement cache,
+// it's zero if the cache is not enabled
+func (p *DB) StmtCacheStats() StmtCacheStats {
+	if p.stmts == nil {
+		return StmtCacheStats{}
+	}
+	return p.stmts.getStats()
+}
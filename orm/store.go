@@ -0,0 +1,146 @@
+package orm
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// Store is a typed repository of the table, the rows of which are mapped
+// to T as Insert, Update, Delete and Rows do, e.g.
+//
+//	users := orm.NewStore[User](db)
+//	user, err := users.Get("id = ?", 1)
+//	list, err := users.List("status = ?", "active")
+//
+// the table can be resolved per statement, e.g. the monthly shards
+//
+//	logs := orm.NewStore[Log](db).
+//		WithTableResolver(func(ctx context.Context, sample interface{}) string {
+//			return "log_" + time.Now().Format("200601")
+//		}).
+//		WithTableCreator(func(db *orm.DB, table string) error {
+//			_, err := db.Exec("create table if not exists " + table + " (...)")
+//			return err
+//		})
+type Store[T any] struct {
+	db       *DB
+	table    string
+	resolver TableResolver
+	creator  TableCreator
+	created  *sync.Map // the tables created by the creator
+}
+
+// TableResolver returns the table of the statement, the sample is the
+// row of Create, Update and Delete, nil for the select statements
+type TableResolver func(ctx context.Context, sample interface{}) string
+
+// TableCreator creates the table if it doesn't exist, it's called
+// once for each table resolved by Create
+type TableCreator func(db *DB, table string) error
+
+// NewStore returns the store of T, the table is default the name of T by the NamingStrategy, e.g. user
+func NewStore[T any](db *DB) *Store[T] {
+	return &Store[T]{
+		db:      db,
+		table:   naming.TableName(indirectType(reflect.TypeOf((*T)(nil))).Name()),
+		created: &sync.Map{},
+	}
+}
+
+// Table returns a copy of the store with the table, the resolver is dropped
+func (p *Store[T]) Table(table string) *Store[T] {
+	s := *p
+	s.table = table
+	s.resolver = nil
+	return &s
+}
+
+// WithDB returns a copy of the store with the db, e.g. the transaction
+func (p *Store[T]) WithDB(db *DB) *Store[T] {
+	s := *p
+	s.db = db
+	return &s
+}
+
+// WithTableResolver returns a copy of the store with the table resolved
+// by fn with the context of the db, e.g. the sharded tables
+func (p *Store[T]) WithTableResolver(fn TableResolver) *Store[T] {
+	s := *p
+	s.resolver = fn
+	return &s
+}
+
+// WithTableCreator returns a copy of the store creating the table
+// resolved by Create on demand
+func (p *Store[T]) WithTableCreator(fn TableCreator) *Store[T] {
+	s := *p
+	s.creator = fn
+	return &s
+}
+
+// Query returns a select builder of the table
+func (p *Store[T]) Query() *Query {
+	return NewQuery(p.db).Table(p.resolve(nil))
+}
+
+// resolve returns the table of the sample
+func (p *Store[T]) resolve(sample interface{}) string {
+	if p.resolver == nil {
+		return p.table
+	}
+	return p.resolver(p.db.context(), sample)
+}
+
+// Get returns the first row matched by the where condition
+func (p *Store[T]) Get(where string, args ...interface{}) (*T, error) {
+	ret := new(T)
+	if err := p.query(where, args...).Row(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// List returns the rows matched by the where condition, at most MAX_ROWS,
+// the condition can be empty, use Query for the paging
+func (p *Store[T]) List(where string, args ...interface{}) ([]T, error) {
+	ret := []T{}
+	if err := p.query(where, args...).Rows(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (p *Store[T]) query(where string, args ...interface{}) *Query {
+	q := p.Query()
+	if where != "" {
+		q.Where(where, args...)
+	}
+	return q
+}
+
+// Create inserts v, the table is created first if the creator is set
+func (p *Store[T]) Create(v *T) error {
+	table := p.resolve(v)
+
+	if p.creator != nil {
+		if _, ok := p.created.Load(table); !ok {
+			if err := p.creator(p.db, table); err != nil {
+				return err
+			}
+			p.created.Store(table, struct{}{})
+		}
+	}
+
+	return p.db.Insert(table, v)
+}
+
+// Update updates the row matched by the `where` fields of v
+func (p *Store[T]) Update(v *T) error {
+	return p.db.Update(p.resolve(v), v)
+}
+
+// Delete deletes the row matched by the `where` fields of v
+func (p *Store[T]) Delete(v *T) error {
+	return p.db.Delete(p.resolve(v), v)
+}
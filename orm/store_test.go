@@ -0,0 +1,80 @@
+package orm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type StoreUser struct {
+	Id   int64 `sql:",where"`
+	Name string
+}
+
+func TestStore(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE store_user (id int, name varchar(32))")
+
+		users := NewStore[StoreUser](dbt.db)
+		assert.NoError(t, users.Create(&StoreUser{1, "tom"}))
+		assert.NoError(t, users.Create(&StoreUser{2, "jerry"}))
+
+		user, err := users.Get("id = ?", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "tom", user.Name)
+
+		user.Name = "bob"
+		assert.NoError(t, users.Update(user))
+
+		list, err := users.List("")
+		assert.NoError(t, err)
+		assert.Equal(t, []StoreUser{{1, "bob"}, {2, "jerry"}}, list)
+
+		assert.NoError(t, users.Delete(&StoreUser{Id: 2}))
+		n, err := users.Query().Count()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		_, err = users.Table("foo").Get("")
+		assert.Error(t, err)
+	})
+}
+
+type StoreLog struct {
+	Id      int64 `sql:",where"`
+	Created time.Time
+}
+
+func TestStoreTableResolver(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		var created []string
+		logs := NewStore[StoreLog](dbt.db).
+			WithTableResolver(func(ctx context.Context, sample interface{}) string {
+				if v, ok := sample.(*StoreLog); ok {
+					return "log_" + v.Created.Format("200601")
+				}
+				return "log_" + ctx.Value("month").(string)
+			}).
+			WithTableCreator(func(db *DB, table string) error {
+				created = append(created, table)
+				_, err := db.Exec("CREATE TABLE IF NOT EXISTS " + table + " (id int, created int)")
+				return err
+			})
+		defer dbt.db.Exec("DROP TABLE IF EXISTS log_202101")
+		defer dbt.db.Exec("DROP TABLE IF EXISTS log_202102")
+
+		jan := time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local)
+		feb := time.Date(2021, 2, 1, 0, 0, 0, 0, time.Local)
+		assert.NoError(t, logs.Create(&StoreLog{1, jan}))
+		assert.NoError(t, logs.Create(&StoreLog{2, jan}))
+		assert.NoError(t, logs.Create(&StoreLog{3, feb}))
+		assert.Equal(t, []string{"log_202101", "log_202102"}, created)
+
+		ctx := context.WithValue(context.Background(), "month", "202101")
+		list, err := logs.WithDB(dbt.db.WithContext(ctx)).List("")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(list))
+	})
+}
@@ -23,20 +23,39 @@ func (p field) String() string {
 }
 
 type tagOpt struct {
-	name  string
-	key   string
-	where bool
-	skip  bool
+	name       string
+	key        string
+	where      bool
+	skip       bool
+	softDelete bool
+	version    bool
+	nullable   bool
+	indexed    bool   // the field has the `index` tag, see Indexes
+	indexTag   string // the `index` tag
+	references string // the `references` tag, see ForeignKeys
+	serializer string // the `serializer` tag, see Serializer
+	timeFormat TimeFormat
+	encrypted  bool   // `sql:",encrypted"`, see Encryptor
+	embedded   bool   // `sql:",embedded,prefix=addr_"`, the fields of the struct are the columns
+	prefix     string // the prefix of the columns of the embedded struct
+	relation   *relation
 }
 
 func (p tagOpt) String() string {
-	return fmt.Sprintf("name %s key %v skip %v where %v",
-		p.name, p.key, p.skip, p.where)
+	return fmt.Sprintf("name %s key %v skip %v where %v softdelete %v version %v",
+		p.name, p.key, p.skip, p.where, p.softDelete, p.version)
 }
 
 type structFields struct {
 	list      []field
 	nameIndex map[string]int
+	// softDelete is the index of the field tagged with `sql:",softdelete"`, -1 if not found
+	softDelete int
+	// version is the index of the field tagged with `sql:",version"`, -1 if not found
+	version int
+	// relations are the fields tagged with has_one, has_many or belongs_to,
+	// keyed by the struct field name, they are not the columns
+	relations map[string]field
 }
 
 func (p structFields) String() (ret string) {
@@ -75,6 +94,7 @@ func typeFields(t reflect.Type) structFields {
 
 	// Fields found.
 	var fields []field
+	relations := map[string]field{}
 
 	// Buffer to run HTMLEscape on field names.
 	// var nameEscBuf bytes.Buffer
@@ -84,10 +104,14 @@ func typeFields(t reflect.Type) structFields {
 		count, nextCount = nextCount, map[reflect.Type]int{}
 
 		for _, f := range current {
-			if visited[f.typ] {
-				continue
+			// the struct tagged with embedded is expanded every time, the
+			// duplicate columns are reported below
+			if !f.embedded {
+				if visited[f.typ] {
+					continue
+				}
+				visited[f.typ] = true
 			}
-			visited[f.typ] = true
 
 			// Scan f.typ for fields to include.
 			for i := 0; i < f.typ.NumField(); i++ {
@@ -123,15 +147,31 @@ func typeFields(t reflect.Type) structFields {
 					ft = ft.Elem()
 				}
 
+				if opt.embedded {
+					if ft.Kind() != reflect.Struct {
+						panicType(ft, fmt.Sprintf("embedded field %s must be a struct", sf.Name))
+					}
+					next = append(next, field{index: index, typ: ft, tagOpt: tagOpt{embedded: true, prefix: f.prefix + opt.prefix}})
+					continue
+				}
+
 				// Record found field and index sequence.
 				// if opt.name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
 				if opt.name != "" || !sf.Anonymous {
+					opt.key = f.prefix + opt.key
 					field := field{
 						tagOpt: opt,
 						index:  index,
 						typ:    ft,
 					}
 
+					if opt.relation != nil {
+						if _, ok := relations[sf.Name]; !ok {
+							relations[sf.Name] = field
+						}
+						continue
+					}
+
 					fields = append(fields, field)
 					if count[f.typ] > 1 {
 						// If there were multiple instances, add a second,
@@ -146,20 +186,64 @@ func typeFields(t reflect.Type) structFields {
 				// Record new anonymous struct to explore in next round.
 				nextCount[ft]++
 				if nextCount[ft] == 1 {
-					next = append(next, field{index: index, typ: ft})
+					next = append(next, field{index: index, typ: ft, tagOpt: tagOpt{prefix: f.prefix}})
 				}
 			}
 		}
 	}
 
 	nameIndex := make(map[string]int, len(fields))
+	softDelete, version := -1, -1
 	for i, field := range fields {
 		if _, ok := nameIndex[field.key]; ok {
 			panicType(field.typ, fmt.Sprintf("duplicate field %s", field.key))
 		}
 		nameIndex[field.key] = i
+
+		if field.softDelete {
+			if softDelete >= 0 {
+				panicType(field.typ, fmt.Sprintf("duplicate softdelete field %s", field.key))
+			}
+			softDelete = i
+		}
+
+		if field.version {
+			if version >= 0 {
+				panicType(field.typ, fmt.Sprintf("duplicate version field %s", field.key))
+			}
+			switch field.typ.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			default:
+				panicType(field.typ, fmt.Sprintf("version field %s must be an integer", field.key))
+			}
+			version = i
+		}
+	}
+	return structFields{fields, nameIndex, softDelete, version, relations}
+}
+
+// softDeleteKey returns the column of the softdelete field of the struct, or empty
+func softDeleteKey(rt reflect.Type) string {
+	if f, ok := softDeleteField(rt); ok {
+		return f.key
 	}
-	return structFields{fields, nameIndex}
+	return ""
+}
+
+// softDeleteField returns the field tagged with `sql:",softdelete"` of the struct
+func softDeleteField(rt reflect.Type) (field, bool) {
+	for rt.Kind() == reflect.Ptr || rt.Kind() == reflect.Slice {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct || rt.String() == "time.Time" {
+		return field{}, false
+	}
+
+	fields := cachedTypeFields(rt)
+	if fields.softDelete < 0 {
+		return field{}, false
+	}
+	return fields.list[fields.softDelete], true
 }
 
 func getSubv(rv reflect.Value, index []int, allowCreate bool) (reflect.Value, error) {
@@ -196,6 +280,16 @@ func parseTag(tag string) (string, tagOptions) {
 	return tag, tagOptions("")
 }
 
+// Get returns the value of the "name=value" option, or empty
+func (o tagOptions) Get(name string) string {
+	for _, s := range strings.Split(string(o), ",") {
+		if strings.HasPrefix(s, name+"=") {
+			return s[len(name)+1:]
+		}
+	}
+	return ""
+}
+
 // Contains reports whether a comma-separated list of options
 // contains a particular substr flag. substr must be surrounded by a
 // string boundary or commas.
@@ -235,13 +329,44 @@ func getTagOpt(sf reflect.StructField) (opt tagOpt) {
 		return
 	}
 
+	opt.indexTag, opt.indexed = sf.Tag.Lookup("index")
+	opt.references = sf.Tag.Get("references")
+	opt.serializer = sf.Tag.Get("serializer")
+
 	name, opts := parseTag(tag)
 	if opts.Contains("where") {
 		opt.where = true
 	}
+	if opts.Contains("softdelete") {
+		opt.softDelete = true
+	}
+	if opts.Contains("version") {
+		opt.version = true
+	}
+	if opts.Contains("nullable") {
+		opt.nullable = true
+	}
+	if opts.Contains("encrypted") {
+		opt.encrypted = true
+	}
+	if opts.Contains("embedded") {
+		opt.embedded = true
+		opt.prefix = opts.Get("prefix")
+	}
+	opt.timeFormat = TimeFormat(opts.Get("time"))
+	for _, kind := range []string{hasOne, hasMany, belongsTo} {
+		if opts.Contains(kind) {
+			opt.relation = &relation{
+				kind:  kind,
+				table: opts.Get("table"),
+				fk:    opts.Get("fk"),
+				refs:  opts.Get("refs"),
+			}
+		}
+	}
 
 	opt.name = name
-	opt.key = snakeCasedName(sf.Name)
+	opt.key = naming.ColumnName(sf.Name)
 
 	if opt.name != "" {
 		opt.key = opt.name
@@ -0,0 +1,140 @@
+package orm
+
+import (
+	sqldriver "database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// TimeFormat is how the time.Time fields are stored, it's set per DB by
+// WithTimeFormat, and per field by the tag, e.g. `sql:",time=unixmilli"`
+type TimeFormat string
+
+const (
+	// TimeUnix stores the unix seconds, e.g. int(11), the default
+	TimeUnix TimeFormat = "unix"
+	// TimeUnixMilli stores the unix milliseconds, e.g. bigint
+	TimeUnixMilli TimeFormat = "unixmilli"
+	// TimeDatetime stores the time in UTC with the microsecond precision,
+	// e.g. DATETIME(6), the value is passed to the driver as time.Time
+	TimeDatetime TimeFormat = "datetime"
+	// TimeRFC3339 stores the time in UTC as the RFC3339 string with nanoseconds
+	TimeRFC3339 TimeFormat = "rfc3339"
+)
+
+// WithTimeFormat sets the default format of the time.Time fields, default TimeUnix
+func WithTimeFormat(format TimeFormat) DBOption {
+	return func(o *dbOptions) {
+		o.timeFormat = format
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// timeValue is the arg of the time.Time field, the format is filled
+// with the default of the DB if the field has no format
+type timeValue struct {
+	t      time.Time
+	format TimeFormat
+}
+
+// Value implements the driver.Valuer, so that the args generated by
+// GenInsertSql etc. can be used without the DB
+func (p timeValue) Value() (sqldriver.Value, error) {
+	switch p.format {
+	case TimeUnix, "":
+		return p.t.Unix(), nil
+	case TimeUnixMilli:
+		return p.t.UnixNano() / int64(time.Millisecond), nil
+	case TimeDatetime:
+		return p.t.UTC().Truncate(time.Microsecond), nil
+	case TimeRFC3339:
+		return p.t.UTC().Format(time.RFC3339Nano), nil
+	default:
+		return nil, fmt.Errorf("unsupported time format %q", p.format)
+	}
+}
+
+func (p timeValue) String() string {
+	v, err := p.Value()
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprint(v)
+}
+
+// timeArgs fills the format of the time args with the default of the DB
+func (p *DB) timeArgs(args []interface{}) []interface{} {
+	var ret []interface{}
+	for i, arg := range args {
+		v, ok := arg.(timeValue)
+		if !ok || v.format != "" {
+			continue
+		}
+		if ret == nil {
+			ret = append([]interface{}{}, args...)
+		}
+		v.format = p.timeFormat
+		ret[i] = v
+	}
+
+	if ret == nil {
+		return args
+	}
+	return ret
+}
+
+// softDeleteValue returns the deletion time of the softdelete field,
+// in the time format of the field if it's a time.Time, or the unix seconds
+func softDeleteValue(f field) interface{} {
+	now := time.Now()
+	if indirectType(f.typ) == timeType {
+		return timeValue{t: now, format: f.timeFormat}
+	}
+	return now.Unix()
+}
+
+// timeLayouts are the layouts of the time scanned as the string
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// parseTime converts the column into the time in the local time zone,
+// the integer is parsed as the unix seconds or milliseconds by the format,
+// the string is parsed in UTC if it has no time zone
+func parseTime(v interface{}, format TimeFormat) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Local(), nil
+	case int64:
+		if format == TimeUnixMilli {
+			return time.Unix(0, v*int64(time.Millisecond)), nil
+		}
+		return time.Unix(v, 0), nil
+	case []byte:
+		return parseTimeString(string(v), format)
+	case string:
+		return parseTimeString(v, format)
+	}
+	return time.Time{}, fmt.Errorf("unsupported time column %T", v)
+}
+
+func parseTimeString(s string, format TimeFormat) (time.Time, error) {
+	// e.g. the integer column of the mysql text protocol
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return parseTime(i, format)
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t.Local(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time %q", s)
+}
@@ -0,0 +1,99 @@
+package orm
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/yubo/golib/orm"
+
+// WithTracing creates a span for each statement and transaction, with the
+// attributes db.system, db.statement and db.rows_affected, the statement is
+// sanitized, the args and the quoted literals are not recorded. The spans
+// are the children of the span in the context of the DB, see WithContext
+func WithTracing(tp trace.TracerProvider) DBOption {
+	return func(o *dbOptions) {
+		o.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// dbSystems are the db.system of the drivers, the driver name is used if not found
+var dbSystems = map[string]string{
+	"postgres": "postgresql",
+	"pgx":      "postgresql",
+	"sqlite3":  "sqlite",
+}
+
+func (p *DB) dbSystem() string {
+	if s, ok := dbSystems[p.driver]; ok {
+		return s
+	}
+	return p.driver
+}
+
+// startSpan returns the context of the statement, the span is nil if the tracing is disabled
+func (p *DB) startSpan(typ, query string) (context.Context, trace.Span) {
+	if p.tracer == nil {
+		return p.context(), nil
+	}
+
+	return p.tracer.Start(p.context(), "orm."+typ,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", p.dbSystem()),
+			attribute.String("db.statement", sanitizeSql(query)),
+		))
+}
+
+// endSpan ends the span with the rows affected, rows is -1 for the query
+func endSpan(span trace.Span, rows int64, err error) {
+	if span == nil {
+		return
+	}
+
+	if rows >= 0 {
+		span.SetAttributes(attribute.Int64("db.rows_affected", rows))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// sanitizeSql replaces the quoted literals with '?', and collapses the spaces
+func sanitizeSql(query string) string {
+	var b strings.Builder
+	var quote rune
+	space := false
+
+	for _, c := range query {
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"':
+			quote = c
+			b.WriteString("'?'")
+			space = false
+		case ' ', '\t', '\n', '\r':
+			if !space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = true
+		default:
+			b.WriteRune(c)
+			space = false
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,159 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/yubo/golib/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type txOptions struct {
+	sqlOptions *sql.TxOptions
+	backoff    *util.Backoff
+}
+
+type TxOption func(*txOptions)
+
+// WithTxOptions sets the isolation level and the read-only flag of the transaction
+func WithTxOptions(opts *sql.TxOptions) TxOption {
+	return func(o *txOptions) {
+		o.sqlOptions = opts
+	}
+}
+
+// WithTxRetry retries the whole transaction with the backoff
+// on the serialization failure or the deadlock
+func WithTxRetry(backoff util.Backoff) TxOption {
+	return func(o *txOptions) {
+		o.backoff = &backoff
+	}
+}
+
+// Transaction runs fn in a transaction, commits if fn returns nil,
+// otherwise rollbacks. If p is already in a transaction, fn is run
+// in a savepoint, and only the savepoint is rolled back on error.
+func (p *DB) Transaction(ctx context.Context, fn func(tx *DB) error, opts ...TxOption) error {
+	o := &txOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if p.Tx() {
+		return p.savepointTx(ctx, fn)
+	}
+
+	if o.backoff == nil {
+		return p.transaction(ctx, fn, o)
+	}
+
+	backoff := *o.backoff
+	if backoff.Retryable == nil {
+		backoff.Retryable = IsRetryableTxError
+	}
+	return util.Retry(ctx, backoff, func() error {
+		return p.transaction(ctx, fn, o)
+	})
+}
+
+func (p *DB) transaction(ctx context.Context, fn func(tx *DB) error, o *txOptions) (err error) {
+	if p.tracer != nil {
+		var span trace.Span
+		ctx, span = p.tracer.Start(ctx, "orm.transaction",
+			trace.WithAttributes(attribute.String("db.system", p.dbSystem())))
+		defer func() { endSpan(span, -1, err) }()
+	}
+
+	tx, err := p.DB.BeginTx(ctx, o.sqlOptions)
+	if err != nil {
+		return err
+	}
+
+	db := p.txDB(tx)
+	db.ctx = ctx
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = fn(db); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			return fmt.Errorf("%w, rollback err: %s", err, rerr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// savepointTx runs fn in a savepoint begun by BeginWithCtx, fn gets
+// the DB of the savepoint, so its Commit and Rollback don't touch the
+// savepoint of p
+func (p *DB) savepointTx(ctx context.Context, fn func(tx *DB) error) (err error) {
+	sp, err := p.BeginWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			sp.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = fn(sp); err != nil {
+		if rerr := sp.Rollback(); rerr != nil {
+			return fmt.Errorf("%w, rollback to savepoint err: %s", err, rerr)
+		}
+		return err
+	}
+
+	return sp.Commit()
+}
+
+// sqliteRetryableErrors are the messages of SQLITE_BUSY and SQLITE_LOCKED,
+// the errors of go-sqlite3 are matched by the message, as the driver needs cgo
+var sqliteRetryableErrors = []string{
+	"database is locked",
+	"database table is locked",
+}
+
+// IsRetryableTxError reports whether the err is a serialization failure or
+// a deadlock, the errors of the drivers are unwrapped and matched by the code:
+//
+//	postgres: 40001 serialization_failure, 40P01 deadlock_detected
+//	mysql:    1213 deadlock found, 1205 lock wait timeout exceeded
+//	sqlite:   SQLITE_BUSY, SQLITE_LOCKED
+func IsRetryableTxError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+
+	for ; err != nil; err = errors.Unwrap(err) {
+		for _, s := range sqliteRetryableErrors {
+			if err.Error() == s {
+				return true
+			}
+		}
+	}
+	return false
+}
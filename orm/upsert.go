@@ -0,0 +1,164 @@
+package orm
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type upsertOptions struct {
+	conflictColumns []string
+	updateColumns   []string
+}
+
+type UpsertOption func(*upsertOptions)
+
+// WithConflictColumns sets the columns of the unique index to detect
+// the conflict, default the `where` fields of the sample. It's ignored
+// by mysql, which detects the conflict with any unique index.
+func WithConflictColumns(columns ...string) UpsertOption {
+	return func(o *upsertOptions) {
+		o.conflictColumns = columns
+	}
+}
+
+// WithUpdateColumns sets the columns to update on the conflict,
+// default all the inserted columns except the conflict columns
+func WithUpdateColumns(columns ...string) UpsertOption {
+	return func(o *upsertOptions) {
+		o.updateColumns = columns
+	}
+}
+
+// Upsert inserts the sample, or updates the existing row on the conflict.
+// The row may be inserted or updated, so both of the BeforeInsert and the
+// BeforeUpdate hooks are called before the statement, and both of the
+// AfterInsert and the AfterUpdate hooks after it
+func (p *DB) Upsert(table string, sample interface{}, opts ...UpsertOption) error {
+	if err := beforeInsert(p, sample); err != nil {
+		return err
+	}
+	if err := beforeUpdate(p, sample); err != nil {
+		return err
+	}
+
+	sql, args, err := GenUpsertSql(p.driver, table, sample, opts...)
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.exec(sql, args...); err != nil {
+		return fmt.Errorf("Upsert() err: %w", err)
+	}
+
+	if err := afterInsert(p, sample); err != nil {
+		return err
+	}
+	return afterUpdate(p, sample)
+}
+
+// GenUpsertSql generates the upsert statement of the driver, e.g.
+//
+//	mysql:           insert into user (`id`, `name`) values (?, ?) on duplicate key update `name`=values(`name`)
+//	sqlite/postgres: insert into user ("id", "name") values (?, ?) on conflict ("id") do update set "name"=excluded."name"
+func GenUpsertSql(driver, table string, sample interface{}, opts ...UpsertOption) (string, []interface{}, error) {
+	o := &upsertOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(sample))
+
+	values := []kv{}
+	if err := genInsertValues(rv, &values); err != nil {
+		return "", nil, err
+	}
+	if len(values) == 0 {
+		return "", nil, fmt.Errorf("upsert into %s `values` is empty", table)
+	}
+
+	conflicts := o.conflictColumns
+	if len(conflicts) == 0 {
+		for _, f := range cachedTypeFields(rv.Type()).list {
+			if f.where {
+				conflicts = append(conflicts, f.key)
+			}
+		}
+	}
+
+	updates := o.updateColumns
+	if len(updates) == 0 {
+		for _, v := range values {
+			if !containsString(conflicts, v.k) {
+				updates = append(updates, v.k)
+			}
+		}
+	}
+
+	mysql := driver == "mysql"
+	quote := newDialect(driver).quoteIdent
+
+	buf := &bytes.Buffer{}
+	args := []interface{}{}
+
+	buf.WriteString("insert into " + table + " (")
+	for i, v := range values {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(quote(v.k))
+		args = append(args, v.v)
+	}
+	buf.WriteString(") values (" + strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ") + ")")
+
+	if mysql {
+		buf.WriteString(" on duplicate key update ")
+		if len(updates) == 0 {
+			// do nothing
+			buf.WriteString(quote(values[0].k) + "=" + quote(values[0].k))
+			return buf.String(), args, nil
+		}
+		for i, k := range updates {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(quote(k) + "=values(" + quote(k) + ")")
+		}
+		return buf.String(), args, nil
+	}
+
+	if len(conflicts) == 0 {
+		return "", nil, fmt.Errorf("upsert into %s conflict columns is empty", table)
+	}
+
+	quoted := make([]string, len(conflicts))
+	for i, k := range conflicts {
+		quoted[i] = quote(k)
+	}
+	buf.WriteString(" on conflict (" + strings.Join(quoted, ", ") + ")")
+
+	if len(updates) == 0 {
+		buf.WriteString(" do nothing")
+		return buf.String(), args, nil
+	}
+
+	buf.WriteString(" do update set ")
+	for i, k := range updates {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(quote(k) + "=excluded." + quote(k))
+	}
+
+	return buf.String(), args, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
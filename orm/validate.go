@@ -0,0 +1,153 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	utilerrors "github.com/yubo/golib/util/errors"
+)
+
+// sqlTagOptions are the known options of the sql tag, the options with
+// the value are in the form of "name=value", e.g. `sql:",time=unixmilli"`
+var sqlTagOptions = map[string]bool{
+	"where":      false,
+	"softdelete": false,
+	"version":    false,
+	"nullable":   false,
+	"embedded":   false,
+	"encrypted":  false,
+	hasOne:       false,
+	hasMany:      false,
+	belongsTo:    false,
+	"prefix":     true,
+	"time":       true,
+	"table":      true,
+	"fk":         true,
+	"refs":       true,
+}
+
+// reservedWords are the keywords can't be the column names without quoting
+var reservedWords = map[string]bool{
+	"select": true, "from": true, "where": true, "insert": true, "update": true,
+	"delete": true, "into": true, "values": true, "set": true, "and": true,
+	"or": true, "not": true, "null": true, "order": true, "group": true, "by": true,
+	"having": true, "limit": true, "offset": true, "join": true, "on": true,
+	"table": true, "index": true, "key": true, "primary": true, "unique": true,
+	"create": true, "drop": true, "alter": true, "in": true, "like": true,
+	"is": true, "as": true, "desc": true, "asc": true, "case": true, "when": true,
+	"then": true, "else": true, "end": true, "union": true, "distinct": true,
+	"default": true, "check": true, "references": true, "foreign": true,
+}
+
+// Validate checks the tags of the struct, e.g. before the migration, instead
+// of the malformed statements or the panics at runtime. It reports the
+// duplicate columns, the reserved words as the columns, the unknown sql tag
+// options, and the options not applicable to the type of the field.
+func Validate(sample interface{}) (err error) {
+	rt := indirectType(reflect.TypeOf(sample))
+	if rt.Kind() != reflect.Struct {
+		return fmt.Errorf("%s is not a struct", rt)
+	}
+
+	defer func() {
+		// e.g. the duplicate fields
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	fields := cachedTypeFields(rt)
+
+	var errs []error
+	list := append([]field{}, fields.list...)
+	names := make([]string, 0, len(fields.relations))
+	for name := range fields.relations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		list = append(list, fields.relations[name])
+	}
+
+	for _, f := range list {
+		sf := rt.FieldByIndex(f.index)
+		if err := validateField(sf, f); err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s: %s", rt.Name(), sf.Name, err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func validateField(sf reflect.StructField, f field) error {
+	_, opts := parseTag(sf.Tag.Get("sql"))
+	if opts != "" {
+		for _, opt := range strings.Split(string(opts), ",") {
+			name := opt
+			if i := strings.IndexByte(opt, '='); i >= 0 {
+				name = opt[:i]
+			}
+			hasValue, ok := sqlTagOptions[name]
+			if !ok {
+				return fmt.Errorf("unknown sql tag option %q", opt)
+			}
+			if hasValue != strings.Contains(opt, "=") {
+				return fmt.Errorf("invalid sql tag option %q", opt)
+			}
+		}
+	}
+
+	if f.relation != nil {
+		return nil
+	}
+
+	if reservedWords[strings.ToLower(f.key)] {
+		return fmt.Errorf("column %q is a reserved word", f.key)
+	}
+
+	ft := sf.Type
+	nillable := false
+	switch ft.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		nillable = true
+	}
+
+	if f.nullable && !nillable {
+		return fmt.Errorf("nullable field must be a pointer, map, slice or interface, got %s", ft)
+	}
+
+	if f.softDelete && !nillable && !ft.Implements(valuerType) {
+		return fmt.Errorf("softdelete field must be nullable, e.g. *int64, got %s", ft)
+	}
+
+	if f.timeFormat != "" {
+		if indirectType(ft) != timeType {
+			return fmt.Errorf("time format %q on the non-time field %s", f.timeFormat, ft)
+		}
+		switch f.timeFormat {
+		case TimeUnix, TimeUnixMilli, TimeDatetime, TimeRFC3339:
+		default:
+			return fmt.Errorf("unsupported time format %q", f.timeFormat)
+		}
+	}
+
+	if f.serializer != "" {
+		if _, err := getSerializer(f.serializer); err != nil {
+			return err
+		}
+	}
+
+	if f.encrypted {
+		if f.where || f.version {
+			return fmt.Errorf("encrypted field can't be the where or version field")
+		}
+		et := indirectType(ft)
+		if f.serializer == "" && et.Kind() != reflect.String &&
+			!(et.Kind() == reflect.Slice && et.Elem().Kind() == reflect.Uint8) {
+			return fmt.Errorf("encrypted field must be a string, []byte or serialized, got %s", ft)
+		}
+	}
+
+	return nil
+}
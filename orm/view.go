@@ -0,0 +1,109 @@
+package orm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// view is declared by WithView, created by DbOpen
+type view struct {
+	name  string
+	query string
+}
+
+// WithView declares the view of the read model, which is created or
+// replaced on DbOpen, and can be queried into the struct like a table, e.g.
+//
+//	type UserStat struct {
+//		Name  string
+//		Count int
+//	}
+//
+//	db, err := orm.DbOpen(driver, dsn, orm.WithView("user_stat",
+//		"select u.name, count(*) as count from user u join event e on e.user_id = u.id group by u.name"))
+//	err = db.Query("select * from user_stat").Rows(&stats)
+func WithView(name, query string) DBOption {
+	return func(o *dbOptions) {
+		o.views = append(o.views, view{name: name, query: query})
+	}
+}
+
+// CreateView creates the view, the existing view is replaced
+func (p *DB) CreateView(name, query string) error {
+	// sqlite doesn't support create or replace view
+	if p.driver == "sqlite3" {
+		if err := p.DropView(name); err != nil {
+			return err
+		}
+		return p.ExecErr(fmt.Sprintf("create view %s as %s", name, query))
+	}
+
+	return p.ExecErr(fmt.Sprintf("create or replace view %s as %s", name, query))
+}
+
+// DropView drops the view if it exists
+func (p *DB) DropView(name string) error {
+	return p.ExecErr("drop view if exists " + name)
+}
+
+// HasView returns true if the view exists in the current database
+func (p *DB) HasView(name string) (bool, error) {
+	views, err := p.listTables("view")
+	if err != nil {
+		return false, err
+	}
+
+	for _, v := range views {
+		if v == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetTables returns the tables of the current database, the views are excluded
+func (p *DB) GetTables() ([]string, error) {
+	return p.listTables("table")
+}
+
+// GetTablesWithViews returns the tables and the views of the current database
+func (p *DB) GetTablesWithViews() ([]string, error) {
+	return p.listTables("table", "view")
+}
+
+// listTables returns the names of the types, the "table" or "view"
+func (p *DB) listTables(types ...string) ([]string, error) {
+	var query string
+	args := []interface{}{}
+
+	switch p.driver {
+	case "sqlite3":
+		query = "select name from sqlite_master where type in (%s) and name not like 'sqlite_%%' order by name"
+		for _, t := range types {
+			args = append(args, t)
+		}
+	case "mysql":
+		query = "select table_name from information_schema.tables where table_schema = database() and table_type in (%s) order by table_name"
+	case "postgres", "pgx":
+		query = "select table_name from information_schema.tables where table_schema = current_schema() and table_type in (%s) order by table_name"
+	default:
+		return nil, fmt.Errorf("list tables is not supported by %s", p.driver)
+	}
+
+	// information_schema.tables.table_type is "BASE TABLE" or "VIEW"
+	if len(args) == 0 {
+		for _, t := range types {
+			if t == "table" {
+				args = append(args, "BASE TABLE")
+			} else {
+				args = append(args, "VIEW")
+			}
+		}
+	}
+
+	var names []string
+	if err := p.Query(fmt.Sprintf(query, strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")), args...).Rows(&names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
@@ -3,8 +3,10 @@ package proc
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -45,6 +47,7 @@ func NewRootCmd(ctx context.Context) *cobra.Command {
 	for _, f := range namedFlagSets.FlagSets {
 		fs.AddFlagSet(f)
 	}
+	registerCompletions(cmd)
 
 	usageFmt := "Usage:\n  %s\n"
 	cols, _, _ := term.GetTerminalSize(cmd.OutOrStdout())
@@ -63,6 +66,97 @@ func NewRootCmd(ctx context.Context) *cobra.Command {
 	return cmd
 }
 
+// registerCompletions registers the shell completions of the flags with
+// the candidates, and the paths of the --set values
+func registerCompletions(cmd *cobra.Command) {
+	for _, p := range configer.GlobalOptions.Params() {
+		if p.Flag == "" || len(p.Candidates) == 0 {
+			continue
+		}
+		candidates := p.Candidates
+		cmd.RegisterFlagCompletionFunc(p.Flag, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return candidates, cobra.ShellCompDirectiveNoFileComp
+		})
+	}
+
+	completeSet := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		directive := cobra.ShellCompDirectiveNoFileComp
+		if i := strings.LastIndexByte(toComplete, ','); !strings.Contains(toComplete[i+1:], "=") {
+			// the path is followed by the value
+			directive |= cobra.ShellCompDirectiveNoSpace
+		}
+		return configer.GlobalOptions.CompleteSet(toComplete), directive
+	}
+	for _, name := range []string{"set", "set-string"} {
+		if cmd.Flags().Lookup(name) != nil {
+			cmd.RegisterFlagCompletionFunc(name, completeSet)
+		}
+	}
+}
+
 func RegisterFlags(path, groupName string, sample interface{}) {
 	configer.AddConfigs(NamedFlagSets().FlagSet(groupName), path, sample)
 }
+
+// NewConfigCmd returns the command to encrypt and decrypt the values of
+// the value files, e.g.
+//
+//	$ export CONFIGER_ENCRYPTION_KEY=$(head -c 32 /dev/urandom | base64)
+//	$ app config encrypt --path db.password -i values.prod.yaml
+//	$ app config decrypt values.prod.yaml
+func NewConfigCmd() *cobra.Command {
+	var keyEnv string
+	var paths []string
+	var inPlace bool
+
+	run := func(fn func(data, key []byte) ([]byte, error)) func(cmd *cobra.Command, args []string) error {
+		return func(cmd *cobra.Command, args []string) error {
+			key, err := configer.EnvKey(keyEnv).Key()
+			if err != nil {
+				return err
+			}
+
+			data, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			if data, err = fn(data, key); err != nil {
+				return err
+			}
+
+			if inPlace {
+				return ioutil.WriteFile(args[0], data, 0600)
+			}
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		}
+	}
+
+	encrypt := &cobra.Command{
+		Use:   "encrypt FILE",
+		Short: "encrypt the values of the value file",
+		Args:  cobra.ExactArgs(1),
+		RunE: run(func(data, key []byte) ([]byte, error) {
+			return configer.EncryptValueFile(data, key, paths...)
+		}),
+	}
+	encrypt.Flags().StringArrayVar(&paths, "path", nil, "the paths of the values to encrypt, all the values if not set")
+
+	decrypt := &cobra.Command{
+		Use:   "decrypt FILE",
+		Short: "decrypt the values of the value file",
+		Args:  cobra.ExactArgs(1),
+		RunE:  run(configer.DecryptValueFile),
+	}
+
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "encrypt or decrypt the value files",
+	}
+	cmd.PersistentFlags().StringVar(&keyEnv, "key-env", configer.DefaultKeyEnv, "the env of the base64 encoded AES key")
+	cmd.PersistentFlags().BoolVarP(&inPlace, "in-place", "i", false, "write the result to the file")
+	cmd.AddCommand(encrypt, decrypt)
+
+	return cmd
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/yubo/golib/configer"
+	"github.com/yubo/golib/util"
 )
 
 // The key type is unexported to prevent collisions
@@ -18,6 +19,8 @@ const (
 	configOptsKey
 	hookOptsKey
 	attrKey // attributes
+	groupKey
+	configDiffKey
 )
 
 func NewContext() context.Context {
@@ -60,6 +63,25 @@ func WgMustFrom(ctx context.Context) *sync.WaitGroup {
 	return wg
 }
 
+// WithGroup set the group of the process, the error of the group's
+// goroutines triggers the graceful shutdown of the process
+func WithGroup(ctx context.Context, g *util.Group) {
+	AttrMustFrom(ctx)[groupKey] = g
+}
+
+func GroupFrom(ctx context.Context) (*util.Group, bool) {
+	g, ok := AttrMustFrom(ctx)[groupKey].(*util.Group)
+	return g, ok
+}
+
+func GroupMustFrom(ctx context.Context) *util.Group {
+	g, ok := AttrMustFrom(ctx)[groupKey].(*util.Group)
+	if !ok {
+		panic("unable to get group from context")
+	}
+	return g
+}
+
 func WithConfiger(ctx context.Context, cf *configer.Configer) {
 	if _, ok := ConfigerFrom(ctx); ok {
 		panic("configer has been exist")
@@ -80,6 +102,23 @@ func ConfigerMustFrom(ctx context.Context) *configer.Configer {
 	return cf
 }
 
+// WithConfigDiff sets the diff of the configer of the reload
+func WithConfigDiff(ctx context.Context, diff *configer.Diff) {
+	AttrMustFrom(ctx)[configDiffKey] = diff
+}
+
+// ConfigDiffFrom returns the diff between the old and the new configer
+// of the reload, the ACTION_RELOAD hooks can skip the reload if their
+// configs are not changed, e.g.
+//
+//	if diff, ok := proc.ConfigDiffFrom(ctx); ok && !diff.Affects("sys.http") {
+//		return nil
+//	}
+func ConfigDiffFrom(ctx context.Context) (*configer.Diff, bool) {
+	diff, ok := AttrMustFrom(ctx)[configDiffKey].(*configer.Diff)
+	return diff, ok
+}
+
 func WithConfigOps(parent context.Context, optsInput ...configer.Option) context.Context {
 	opts, ok := parent.Value(configOptsKey).(*[]configer.Option)
 	if ok {
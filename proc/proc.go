@@ -13,6 +13,8 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/yubo/golib/cli/flag"
 	"github.com/yubo/golib/configer"
+	"github.com/yubo/golib/util"
+	"github.com/yubo/golib/util/clock"
 	"k8s.io/klog/v2"
 )
 
@@ -32,7 +34,11 @@ type Process struct {
 	namedFlagSets flag.NamedFlagSets
 	initDone      bool //
 
+	clock  clock.Clock
 	wg     sync.WaitGroup
+	group  *util.Group
+	gctx   context.Context // the ctx of the start and reload hooks, canceled when the group fails
+	errCh  chan error
 	cancel context.CancelFunc
 	ctx    context.Context
 	err    error
@@ -48,6 +54,8 @@ func newProcess() *Process {
 
 	return &Process{
 		hookOps: hookOps,
+		clock:   clock.RealClock{},
+		errCh:   make(chan error, 1),
 		ctx:     ctx,
 		cancel:  cancel,
 	}
@@ -57,6 +65,11 @@ func WithContext(ctx context.Context) {
 	proc.ctx, proc.cancel = context.WithCancel(ctx)
 }
 
+// WithClock sets the clock used by the process timeouts, e.g. a fake clock for testing
+func WithClock(c clock.Clock) {
+	proc.clock = c
+}
+
 func Start() error {
 	return proc.Start()
 }
@@ -148,6 +161,14 @@ func (p *Process) init() error {
 	if _, ok := WgFrom(ctx); !ok {
 		WithWg(ctx, &p.wg)
 	}
+	if g, ok := GroupFrom(ctx); ok {
+		// the group of the caller, whose ctx is expected to be ctx
+		p.group, p.gctx = g, ctx
+		g.OnError(p.fatal)
+	} else {
+		p.group, p.gctx = util.NewGroup(ctx, p.fatal)
+		WithGroup(ctx, p.group)
+	}
 
 	p.status = STATUS_PENDING
 
@@ -167,7 +188,7 @@ func (p *Process) start() error {
 	for _, ops := range p.hookOps[ACTION_START] {
 		logOps(ops)
 
-		if err := ops.Hook(WithHookOps(p.ctx, ops)); err != nil {
+		if err := ops.Hook(WithHookOps(p.gctx, ops)); err != nil {
 			return fmt.Errorf("%s.%s() err: %s", ops.Owner, nameOfFunction(ops.Hook), err)
 		}
 	}
@@ -175,6 +196,15 @@ func (p *Process) start() error {
 	return nil
 }
 
+// fatal is called when the goroutine of the group failed,
+// the process will be stopped gracefully
+func (p *Process) fatal(err error) {
+	select {
+	case p.errCh <- err:
+	default:
+	}
+}
+
 func logOps(ops *HookOps) {
 	if klog.V(5).Enabled() {
 		klog.InfoSDepth(1, "dispatch hook",
@@ -199,6 +229,16 @@ func (p *Process) loop() error {
 		select {
 		case <-p.ctx.Done():
 			return p.err
+		case err := <-p.errCh:
+			klog.Errorf("fatal error, exiting: %s", err)
+			if shutdown {
+				continue
+			}
+			shutdown = true
+			p.err = err
+			go func() {
+				p.stop()
+			}()
 		case s := <-sigs:
 			if sigContains(s, shutdownSignals) {
 				klog.V(1).Infof("recv shutdown signal, exiting")
@@ -231,6 +271,9 @@ func (p *Process) stop() error {
 
 	go func() {
 		p.wg.Wait()
+		if p.group != nil {
+			p.group.Wait()
+		}
 		wgCh <- struct{}{}
 	}()
 
@@ -252,7 +295,7 @@ func (p *Process) stop() error {
 	select {
 	case <-wgCh:
 		klog.Info("server closed")
-	case <-time.After(closeTimeout):
+	case <-p.clock.After(closeTimeout):
 		p.err = fmt.Errorf("server closed after timeout %ds", closeTimeout/time.Second)
 
 	}
@@ -266,17 +309,23 @@ func (p *Process) reload() (err error) {
 	p.status.Set(STATUS_RELOADING)
 
 	opts, _ := ConfigOptsFrom(p.ctx)
-	configer, err := configer.New(opts...)
+	cf, err := configer.New(opts...)
 	if err != nil {
 		p.err = err
 		return err
 	}
 
-	WithConfiger(p.ctx, configer)
+	// replace the configer, WithConfiger doesn't allow it
+	if old, ok := ConfigerFrom(p.ctx); ok {
+		diff := old.Diff(cf)
+		klog.V(1).InfoS("config reload", "changed", diff.Paths())
+		WithConfigDiff(p.ctx, diff)
+	}
+	AttrMustFrom(p.ctx)[configerKey] = cf
 
 	for _, ops := range p.hookOps[ACTION_RELOAD] {
 		logOps(ops)
-		if err := ops.Hook(WithHookOps(p.ctx, ops)); err != nil {
+		if err := ops.Hook(WithHookOps(p.gctx, ops)); err != nil {
 			p.err = err
 			return err
 		}
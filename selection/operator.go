@@ -30,4 +30,13 @@ const (
 	Exists       Operator = "exists"
 	GreaterThan  Operator = "gt"
 	LessThan     Operator = "lt"
+
+	GreaterThanOrEquals Operator = "gte"
+	LessThanOrEquals    Operator = "lte"
+	HasPrefix           Operator = "prefix"
+	HasSuffix           Operator = "suffix"
+	Contains            Operator = "contains"
+	Like                Operator = "like"
+	NotLike             Operator = "notlike"
+	Or                  Operator = "or"
 )
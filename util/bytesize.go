@@ -1,7 +1,10 @@
 package util
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 type ByteSize uint64
@@ -104,6 +107,73 @@ func (b ByteSize) HumanReadable() string {
 	}
 }
 
+// ParseByteSize parses the size with the optional binary unit, e.g.
+// "1024", "512Ki", "512K", "512KB", "1.5Gi"
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+
+	unit := B
+	switch strings.ToUpper(strings.TrimSpace(s[i:])) {
+	case "", "B":
+	case "K", "KB", "KI", "KIB":
+		unit = KB
+	case "M", "MB", "MI", "MIB":
+		unit = MB
+	case "G", "GB", "GI", "GIB":
+		unit = GB
+	case "T", "TB", "TI", "TIB":
+		unit = TB
+	case "P", "PB", "PI", "PIB":
+		unit = PB
+	case "E", "EB", "EI", "EIB":
+		unit = EB
+	default:
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+
+	return ByteSize(n * float64(unit)), nil
+}
+
+// Set implements pflag.Value
+func (b *ByteSize) Set(s string) error {
+	v, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+// Type implements pflag.Value
+func (b *ByteSize) Type() string {
+	return "byteSize"
+}
+
+// UnmarshalJSON accepts the number of bytes or the string with the unit, e.g. "512Mi"
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n uint64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid byte size %s", data)
+		}
+		*b = ByteSize(n)
+		return nil
+	}
+	return b.Set(s)
+}
+
 func SizeOf(v string) uint64 {
 	n := uint64(toInt64(v[:len(v)-1]))
 
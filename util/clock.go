@@ -0,0 +1,18 @@
+package util
+
+import (
+	"time"
+
+	"github.com/yubo/golib/util/clock"
+)
+
+// Clock allows for injecting fake or real clocks into the time-dependent code
+type Clock = clock.Clock
+
+// RealClock really calls time.Now()
+var RealClock Clock = clock.RealClock{}
+
+// NewFakeClock returns a fake clock for testing, the time is changed by Step/SetTime
+func NewFakeClock(t time.Time) *clock.FakeClock {
+	return clock.NewFakeClock(t)
+}
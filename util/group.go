@@ -0,0 +1,103 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// Group is a collection of goroutines like errgroup.Group,
+// the first error (or panic) cancels the context and is reported by onError
+type Group struct {
+	wg     sync.WaitGroup
+	cancel func()
+
+	mu      sync.Mutex
+	onError []func(error)
+	err     error
+}
+
+// NewGroup returns a new Group and an associated Context derived from ctx.
+// The derived Context is canceled the first time a function passed to Go
+// returns a non-nil error or panics, or the first time Wait returns.
+// onError is called with the first error if it is not nil.
+func NewGroup(ctx context.Context, onError func(error)) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Group{cancel: cancel}
+	if onError != nil {
+		g.onError = append(g.onError, onError)
+	}
+	return g, ctx
+}
+
+// OnError registers fn to be called with the first error,
+// fn is called at once if the group has already failed
+func (g *Group) OnError(fn func(error)) {
+	g.mu.Lock()
+	err := g.err
+	if err == nil {
+		g.onError = append(g.onError, fn)
+	}
+	g.mu.Unlock()
+
+	if err != nil {
+		fn(err)
+	}
+}
+
+// Go calls the given function in a new goroutine,
+// the panic of f is recovered and returned as an error.
+func (g *Group) Go(f func() error) {
+	g.wg.Add(1)
+
+	go func() {
+		defer g.wg.Done()
+
+		if err := g.call(f); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+// fail records the first error, cancels the context and calls the onError
+func (g *Group) fail(err error) {
+	g.mu.Lock()
+	if g.err != nil {
+		g.mu.Unlock()
+		return
+	}
+	g.err = err
+	fns := g.onError
+	g.mu.Unlock()
+
+	if g.cancel != nil {
+		g.cancel()
+	}
+	for _, fn := range fns {
+		fn(err)
+	}
+}
+
+func (g *Group) call(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	return f()
+}
+
+// Wait blocks until all function calls from the Go method have returned,
+// then returns the first non-nil error (if any) from them.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel()
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
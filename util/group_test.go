@@ -0,0 +1,58 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	t.Run("error", func(t *testing.T) {
+		var reported error
+		errTest := errors.New("test")
+
+		g, ctx := NewGroup(context.Background(), func(err error) { reported = err })
+		g.Go(func() error {
+			<-ctx.Done()
+			return nil
+		})
+		g.Go(func() error { return errTest })
+
+		require.Equal(t, errTest, g.Wait())
+		require.Equal(t, errTest, reported)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		g, _ := NewGroup(context.Background(), nil)
+		g.Go(func() error { panic("boom") })
+
+		err := g.Wait()
+		require.Error(t, err)
+		require.True(t, strings.HasPrefix(err.Error(), "panic: boom"))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		g, ctx := NewGroup(context.Background(), nil)
+		g.Go(func() error { return nil })
+
+		require.NoError(t, g.Wait())
+		require.Error(t, ctx.Err())
+	})
+
+	t.Run("onError", func(t *testing.T) {
+		var reported []error
+		errTest := errors.New("test")
+
+		g, _ := NewGroup(context.Background(), func(err error) { reported = append(reported, err) })
+		g.OnError(func(err error) { reported = append(reported, err) })
+		g.Go(func() error { return errTest })
+		require.Equal(t, errTest, g.Wait())
+
+		// registered after the failure
+		g.OnError(func(err error) { reported = append(reported, err) })
+		require.Equal(t, []error{errTest, errTest, errTest}, reported)
+	})
+}
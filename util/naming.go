@@ -0,0 +1,112 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NameMapper maps the go field name to the name used by the
+// storage/config, e.g. the orm column name or the configer path
+type NameMapper interface {
+	Map(name string) string
+}
+
+// NameMapperFunc is a function adapter of NameMapper
+type NameMapperFunc func(string) string
+
+func (f NameMapperFunc) Map(name string) string {
+	return f(name)
+}
+
+type NameCase int
+
+const (
+	SnakeCase      NameCase = iota // user_id
+	KebabCase                      // user-id
+	LowerCamelCase                 // userID
+	CamelCase                      // UserID
+)
+
+// DefaultAcronyms are kept upper cased in the camel cased names
+var DefaultAcronyms = []string{
+	"API", "CPU", "DNS", "HTML", "HTTP", "HTTPS", "ID", "IP", "JSON",
+	"SQL", "SSH", "TCP", "TLS", "TTL", "UDP", "UI", "URI", "URL", "UUID", "XML",
+}
+
+type nameMapper struct {
+	nameCase NameCase
+	acronyms map[string]bool
+}
+
+// NewNameMapper returns a NameMapper converts the names to the nameCase,
+// the words in acronyms(DefaultAcronyms if empty) are treated as a whole,
+// e.g. "HTTPServerID" is "http_server_id" in SnakeCase, "httpServerID" in LowerCamelCase
+func NewNameMapper(nameCase NameCase, acronyms ...string) NameMapper {
+	if len(acronyms) == 0 {
+		acronyms = DefaultAcronyms
+	}
+
+	m := &nameMapper{
+		nameCase: nameCase,
+		acronyms: make(map[string]bool, len(acronyms)),
+	}
+	for _, v := range acronyms {
+		m.acronyms[strings.ToUpper(v)] = true
+	}
+	return m
+}
+
+func (p *nameMapper) Map(name string) string {
+	words := SplitWords(name)
+
+	switch p.nameCase {
+	case SnakeCase:
+		return strings.ToLower(strings.Join(words, "_"))
+	case KebabCase:
+		return strings.ToLower(strings.Join(words, "-"))
+	}
+
+	for i, w := range words {
+		upper := strings.ToUpper(w)
+		switch {
+		case i == 0 && p.nameCase == LowerCamelCase:
+			words[i] = strings.ToLower(w)
+		case p.acronyms[upper]:
+			words[i] = upper
+		default:
+			words[i] = strings.ToUpper(w[:1]) + strings.ToLower(w[1:])
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// SplitWords splits the name into words by the separators(_-. ) and the case changes,
+// e.g. "HTTPServerID" -> ["HTTP", "Server", "ID"], "user_id2" -> ["user", "id2"]
+func SplitWords(name string) []string {
+	words := []string{}
+	runes := []rune(name)
+	start := 0
+
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '_' || c == '-' || c == '.' || c == ' ':
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+		case unicode.IsUpper(c) && i > start:
+			prev := runes[i-1]
+			// fooBar, foo1Bar, or the last upper of an acronym: HTTPServer
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+	}
+
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
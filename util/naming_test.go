@@ -0,0 +1,38 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameMapper(t *testing.T) {
+	cases := []struct {
+		in    string
+		snake string
+		kebab string
+		lower string
+		camel string
+	}{
+		{"UserID", "user_id", "user-id", "userID", "UserID"},
+		{"HTTPServer", "http_server", "http-server", "httpServer", "HTTPServer"},
+		{"userId", "user_id", "user-id", "userID", "UserID"},
+		{"foo_bar", "foo_bar", "foo-bar", "fooBar", "FooBar"},
+		{"Ipv4Addr", "ipv4_addr", "ipv4-addr", "ipv4Addr", "Ipv4Addr"},
+		{"MaxIdleConns", "max_idle_conns", "max-idle-conns", "maxIdleConns", "MaxIdleConns"},
+	}
+
+	snake := NewNameMapper(SnakeCase)
+	kebab := NewNameMapper(KebabCase)
+	lower := NewNameMapper(LowerCamelCase)
+	camel := NewNameMapper(CamelCase)
+
+	for _, c := range cases {
+		require.Equal(t, c.snake, snake.Map(c.in), c.in)
+		require.Equal(t, c.kebab, kebab.Map(c.in), c.in)
+		require.Equal(t, c.lower, lower.Map(c.in), c.in)
+		require.Equal(t, c.camel, camel.Map(c.in), c.in)
+	}
+
+	require.Equal(t, "DbID", NewNameMapper(CamelCase, "ID").Map("DBId"))
+}
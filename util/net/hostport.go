@@ -0,0 +1,69 @@
+package net
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// HostPort is the address of "host:port", e.g. "127.0.0.1:8080", ":80",
+// "[::1]:53", it can be used as the config field and the flag
+type HostPort string
+
+// ParseHostPort returns the HostPort if s is a valid "host:port"
+func ParseHostPort(s string) (HostPort, error) {
+	_, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return "", err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return "", fmt.Errorf("invalid port of %q", s)
+	}
+	return HostPort(s), nil
+}
+
+// Host returns the host part, "" if it's invalid
+func (p HostPort) Host() string {
+	host, _, _ := net.SplitHostPort(string(p))
+	return host
+}
+
+// Port returns the port part, 0 if it's invalid
+func (p HostPort) Port() int {
+	_, port, _ := net.SplitHostPort(string(p))
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+func (p HostPort) String() string {
+	return string(p)
+}
+
+// Set implements pflag.Value
+func (p *HostPort) Set(s string) error {
+	v, err := ParseHostPort(s)
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+// Type implements pflag.Value
+func (p *HostPort) Type() string {
+	return "hostPort"
+}
+
+// UnmarshalJSON validates the address, the empty string is allowed
+func (p *HostPort) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*p = ""
+		return nil
+	}
+	return p.Set(s)
+}
@@ -0,0 +1,113 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Backoff is the retry policy of Retry
+type Backoff struct {
+	// the duration to wait before the first retry
+	InitialInterval time.Duration
+	// the cap of the wait duration between retries, 0 means no limit
+	MaxInterval time.Duration
+	// the wait duration is multiplied by Multiplier after each retry
+	Multiplier float64
+	// the wait duration is randomized in [d - Jitter*d, d + Jitter*d]
+	Jitter float64
+	// stop retrying after MaxElapsedTime since the first call, 0 means no limit
+	MaxElapsedTime time.Duration
+	// the maximum number of retries, 0 means no limit
+	MaxRetries int
+	// Retryable reports whether the error should be retried,
+	// nil means all errors are retryable except the Permanent errors
+	Retryable func(error) bool
+	// Clock is used to wait between retries, nil means RealClock
+	Clock Clock
+}
+
+// DefaultBackoff retries with 100ms, 200ms, 400ms ... 10s for at most 1 minute
+var DefaultBackoff = Backoff{
+	InitialInterval: 100 * time.Millisecond,
+	MaxInterval:     10 * time.Second,
+	Multiplier:      2,
+	Jitter:          0.2,
+	MaxElapsedTime:  time.Minute,
+}
+
+type permanentError struct {
+	err error
+}
+
+func (p *permanentError) Error() string { return p.err.Error() }
+func (p *permanentError) Unwrap() error { return p.err }
+
+// Permanent wraps the err to stop Retry immediately
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// Retry calls fn until it returns nil, a non-retryable error,
+// the backoff policy is exhausted or the ctx is done.
+// The last error of fn is returned.
+func Retry(ctx context.Context, backoff Backoff, fn func() error) error {
+	c := backoff.Clock
+	if c == nil {
+		c = RealClock
+	}
+
+	start := c.Now()
+	interval := backoff.InitialInterval
+
+	for retries := 0; ; retries++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		if backoff.Retryable != nil && !backoff.Retryable(err) {
+			return err
+		}
+		if backoff.MaxRetries > 0 && retries >= backoff.MaxRetries {
+			return err
+		}
+
+		wait := jitter(interval, backoff.Jitter)
+		if backoff.MaxElapsedTime > 0 && c.Since(start)+wait > backoff.MaxElapsedTime {
+			return err
+		}
+
+		t := c.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("%w, last error: %s", ctx.Err(), err)
+		case <-t.C():
+		}
+
+		if backoff.Multiplier > 0 {
+			interval = time.Duration(float64(interval) * backoff.Multiplier)
+		}
+		if backoff.MaxInterval > 0 && interval > backoff.MaxInterval {
+			interval = backoff.MaxInterval
+		}
+	}
+}
+
+func jitter(d time.Duration, factor float64) time.Duration {
+	if factor <= 0 {
+		return d
+	}
+	delta := factor * float64(d)
+	return time.Duration(float64(d) - delta + rand.Float64()*2*delta)
+}
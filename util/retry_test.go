@@ -0,0 +1,73 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	errTemp := errors.New("temporary")
+	errFatal := errors.New("fatal")
+	backoff := Backoff{InitialInterval: time.Millisecond, Multiplier: 2, MaxRetries: 3}
+
+	t.Run("success", func(t *testing.T) {
+		n := 0
+		err := Retry(context.Background(), backoff, func() error {
+			if n++; n < 3 {
+				return errTemp
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, n)
+	})
+
+	t.Run("max retries", func(t *testing.T) {
+		n := 0
+		err := Retry(context.Background(), backoff, func() error {
+			n++
+			return errTemp
+		})
+		require.Equal(t, errTemp, err)
+		require.Equal(t, 4, n)
+	})
+
+	t.Run("permanent", func(t *testing.T) {
+		n := 0
+		err := Retry(context.Background(), backoff, func() error {
+			n++
+			return Permanent(errFatal)
+		})
+		require.Equal(t, errFatal, err)
+		require.Equal(t, 1, n)
+	})
+
+	t.Run("retryable", func(t *testing.T) {
+		b := backoff
+		b.Retryable = func(err error) bool { return err == errTemp }
+
+		n := 0
+		err := Retry(context.Background(), b, func() error {
+			if n++; n < 2 {
+				return errTemp
+			}
+			return errFatal
+		})
+		require.Equal(t, errFatal, err)
+		require.Equal(t, 2, n)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := Retry(ctx, Backoff{InitialInterval: time.Hour}, func() error {
+			return errTemp
+		})
+		require.True(t, errors.Is(err, context.Canceled))
+	})
+}
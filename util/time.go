@@ -23,8 +23,13 @@ func FmtTs(ts int64, verbose ...bool) string {
 }
 
 func Until(f func(), interval time.Duration, stopCh <-chan struct{}) {
+	UntilWithClock(f, interval, stopCh, RealClock)
+}
+
+// UntilWithClock is the same as Until, but the ticker is created by the clock c
+func UntilWithClock(f func(), interval time.Duration, stopCh <-chan struct{}, c Clock) {
 	go func() {
-		ticker := time.NewTicker(interval)
+		ticker := c.NewTicker(interval)
 		defer ticker.Stop()
 
 		// first call
@@ -39,7 +44,7 @@ func Until(f func(), interval time.Duration, stopCh <-chan struct{}) {
 			select {
 			case <-stopCh:
 				return
-			case <-ticker.C:
+			case <-ticker.C():
 				f()
 			}
 		}
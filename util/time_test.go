@@ -0,0 +1,30 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUntilWithClock(t *testing.T) {
+	c := NewFakeClock(time.Now())
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	calls := make(chan struct{}, 10)
+	UntilWithClock(func() { calls <- struct{}{} }, time.Minute, stopCh, c)
+
+	// first call
+	<-calls
+
+	for i := 0; i < 3; i++ {
+		require.Eventually(t, c.HasWaiters, time.Second, time.Millisecond)
+		c.Step(time.Minute)
+		select {
+		case <-calls:
+		case <-time.After(time.Second):
+			t.Fatalf("f is not called after the tick %d", i)
+		}
+	}
+}